@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/modules/invitation"
+	"metalcore-api/internal/modules/organization"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/pii"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// reencrypt seals the personal data of every user and the emails of every
+// invitation with the active key of PII_ENCRYPTION_KEYS, normalizes emails and fills
+// in missing blind indexes. Run it after enabling encryption and after adding a new
+// active key; retired keys can be removed once it reports nothing left to update.
+// It is safe to interrupt and run again.
+func main() {
+	batchSize := flag.Int("batch", 500, "users or invitations updated per transaction")
+	dryRun := flag.Bool("dry-run", false, "only count the users and invitations that need re-encryption")
+	flag.Parse()
+
+	config.LoadEnv()
+	logging.Setup()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cipher, err := pii.NewCipherFromEnv()
+	if err != nil {
+		slog.Error("error while configuring personal data encryption", "error", err)
+		os.Exit(1)
+	}
+	if cipher == nil {
+		slog.Error("PII_ENCRYPTION_KEYS must be set to re-encrypt")
+		os.Exit(1)
+	}
+
+	database.ConnectDB()
+	repo := user.NewUserRepository(database.DB, cipher)
+
+	afterID, total := 0, 0
+	for {
+		lastID, updated, err := repo.ReencryptBatch(ctx, afterID, *batchSize, *dryRun)
+		if err != nil {
+			slog.Error("re-encryption stopped", "after_user_id", afterID, "updated", total, "error", err)
+			os.Exit(1)
+		}
+		if lastID == 0 {
+			break
+		}
+
+		total += updated
+		afterID = lastID
+		slog.Info("re-encrypted batch", "last_user_id", lastID, "updated", updated)
+	}
+
+	// Invitations are only visible within their organization
+	invitations := invitation.NewInvitationRepository(database.DB, cipher)
+	organizations, err := organization.NewOrganizationRepository(database.DB).IDs(ctx)
+	if err != nil {
+		slog.Error("re-encryption stopped", "updated", total, "error", err)
+		os.Exit(1)
+	}
+	for _, organizationID := range organizations {
+		var afterID int64
+		for {
+			lastID, updated, err := invitations.ReencryptBatch(ctx, organizationID, afterID, *batchSize, *dryRun)
+			if err != nil {
+				slog.Error("re-encryption stopped", "organization_id", organizationID, "after_invitation_id", afterID, "updated", total, "error", err)
+				os.Exit(1)
+			}
+			if lastID == 0 {
+				break
+			}
+
+			total += updated
+			afterID = lastID
+			slog.Info("re-encrypted batch", "organization_id", organizationID, "last_invitation_id", lastID, "updated", updated)
+		}
+	}
+
+	slog.Info("re-encryption finished", "updated", total, "dry_run", *dryRun)
+}
@@ -1,16 +1,59 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"metalcore-api/internal/background"
 	"metalcore-api/internal/config"
 	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/metrics"
 	"metalcore-api/internal/router"
+	"metalcore-api/internal/tlsconfig"
+	"metalcore-api/internal/tracing"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
 )
 
 func main() {
 	config.LoadEnv()
+	logging.Setup()
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		slog.Error("error while configuring tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	database.ConnectDB()
 
+	// Metrics are served on a separate admin port, only when one is configured
+	if metricsAddr := config.GetEnv("METRICS_ADDR", ""); metricsAddr != "" {
+		metrics.RegisterPool(database.DB)
+		go func() {
+			if err := metrics.Serve(metricsAddr); err != nil {
+				slog.Error("metrics server stopped", "error", err)
+			}
+		}()
+	}
+
+	// Outbox, webhook and job processing, unless it is left to cmd/worker
+	backgroundDone := make(chan struct{})
+	go func() {
+		background.Run(ctx, database.DB)
+		close(backgroundDone)
+	}()
+
 	r := router.SetupRouter(database.DB)
 
 	port := os.Getenv("APP_PORT")
@@ -18,5 +61,89 @@ func main() {
 		port = "8090"
 	}
 
-	r.Run(":" + port)
+	// HTTP/2 is negotiated over TLS, over plain HTTP only with prior knowledge when enabled
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(config.GetEnvBool("HTTP2_CLEARTEXT", false))
+
+	server := &http.Server{
+		Addr:      ":" + port,
+		Handler:   r,
+		Protocols: protocols,
+	}
+
+	// HTTPS when a certificate is configured, reloaded when its files change or on SIGHUP
+	var h3 *http3.Server
+	if tlsConfig := tlsconfig.ConfigFromEnv(); tlsConfig.Enabled() {
+		reloader, err := tlsconfig.NewReloader(tlsConfig)
+		if err != nil {
+			slog.Error("error while loading TLS certificate", "error", err)
+			os.Exit(1)
+		}
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		go reloader.Watch(ctx, hangup)
+		server.TLSConfig = reloader.TLSConfig()
+
+		// HTTP/3 listens on the same port over UDP and is advertised with Alt-Svc
+		if config.GetEnvBool("HTTP3_ENABLED", false) {
+			h3 = &http3.Server{
+				Addr:      server.Addr,
+				Handler:   r,
+				TLSConfig: http3.ConfigureTLSConfig(server.TLSConfig),
+			}
+			server.Handler = advertiseHTTP3(h3, r)
+
+			go func() {
+				if err := h3.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					slog.Error("HTTP/3 server stopped", "error", err)
+					os.Exit(1)
+				}
+			}()
+		}
+	}
+
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server stopped", "error", err)
+			os.Exit(1)
+		}
+	}()
+	slog.Info("server listening", "addr", server.Addr, "tls", server.TLSConfig != nil, "http3", h3 != nil)
+
+	// Stop accepting requests on SIGINT/SIGTERM and let in-flight ones finish
+	<-ctx.Done()
+	stop()
+	slog.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.GetEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second))
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error while shutting down server", "error", err)
+	}
+	if h3 != nil {
+		if err := h3.Shutdown(shutdownCtx); err != nil {
+			slog.Error("error while shutting down HTTP/3 server", "error", err)
+		}
+	}
+
+	// Background processors stopped claiming work with ctx, wait for running jobs
+	<-backgroundDone
+}
+
+// advertiseHTTP3 adds the Alt-Svc header announcing h3 to responses over HTTP/1.1 and HTTP/2
+func advertiseHTTP3(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			_ = h3.SetQUICHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"metalcore-api/internal/background"
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/metrics"
+	"metalcore-api/internal/tracing"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// The worker runs the outbox dispatcher, webhook deliveries and the job queue
+// without serving the API. Disable them in the API server with
+// OUTBOX_DISPATCHER_ENABLED, WEBHOOK_WORKER_ENABLED and JOBS_WORKER_ENABLED.
+func main() {
+	config.LoadEnv()
+	logging.Setup()
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		slog.Error("error while configuring tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	database.ConnectDB()
+
+	if metricsAddr := config.GetEnv("METRICS_ADDR", ""); metricsAddr != "" {
+		metrics.RegisterPool(database.DB)
+		go func() {
+			if err := metrics.Serve(metricsAddr); err != nil {
+				slog.Error("metrics server stopped", "error", err)
+			}
+		}()
+	}
+
+	slog.Info("worker started")
+	background.Run(ctx, database.DB)
+	slog.Info("worker stopped")
+}
@@ -0,0 +1,114 @@
+package background
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/events"
+	"metalcore-api/internal/jobs"
+	"metalcore-api/internal/mailer"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/invitation"
+	"metalcore-api/internal/modules/organization"
+	"metalcore-api/internal/modules/session"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/modules/webhook"
+	"metalcore-api/internal/pii"
+	"metalcore-api/internal/privacy"
+	"metalcore-api/internal/retention"
+	"metalcore-api/internal/scheduler"
+	"metalcore-api/internal/token"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Run starts the background processors enabled by OUTBOX_DISPATCHER_ENABLED,
+// WEBHOOK_WORKER_ENABLED, JOBS_WORKER_ENABLED and SCHEDULER_ENABLED, and blocks until ctx is
+// cancelled and every processor has stopped. It is used both by the API server
+// and by the standalone worker binary, any number of instances can run at once.
+func Run(ctx context.Context, db *pgxpool.Pool) {
+	var wg sync.WaitGroup
+	start := func(name string, run func(context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(ctx)
+		}()
+		slog.Info("background processor started", "processor", name)
+	}
+
+	// Domain events written to the outbox are delivered to the log/webhook sinks
+	// and to the in-process subscribers below
+	bus := events.NewBus()
+	bus.Subscribe(events.AllEvents, webhook.NewService(webhook.NewWebhookRepository(db)).Enqueue)
+
+	if config.GetEnvBool("OUTBOX_DISPATCHER_ENABLED", true) {
+		dispatcher := events.NewDispatcher(db, events.NewSinksFromEnv(bus), events.DispatcherConfigFromEnv())
+		start("outbox", dispatcher.Run)
+	}
+
+	if config.GetEnvBool("WEBHOOK_WORKER_ENABLED", true) {
+		start("webhooks", webhook.NewWorker(db, webhook.WorkerConfigFromEnv()).Run)
+	}
+
+	if config.GetEnvBool("JOBS_WORKER_ENABLED", true) {
+		start("jobs", jobs.NewWorker(db, Registry(db), jobs.WorkerConfigFromEnv()).Run)
+	}
+
+	if config.GetEnvBool("SCHEDULER_ENABLED", true) {
+		s := scheduler.New(db, scheduler.ConfigFromEnv())
+		if err := retention.Schedule(s); err != nil {
+			slog.Error("error while configuring scheduler", "error", err)
+		} else {
+			start("scheduler", s.Run)
+		}
+	}
+
+	wg.Wait()
+}
+
+// Registry returns the handlers of every job kind
+func Registry(db *pgxpool.Pool) *jobs.Registry {
+	registry := jobs.NewRegistry()
+
+	mail, err := mailer.NewFromEnv()
+	if err != nil {
+		slog.Error("error while configuring mailer", "error", err)
+		os.Exit(1)
+	}
+	jobs.Register(registry, mailer.SendEmailJob(mail))
+
+	cipher, err := pii.NewCipherFromEnv()
+	if err != nil {
+		slog.Error("error while configuring personal data encryption", "error", err)
+		os.Exit(1)
+	}
+
+	// Emails carrying a token are queued by reference, their handlers issue the token
+	userRepo := user.NewUserRepository(db, cipher)
+	auditService := audit.NewService(audit.NewAuditRepository(db))
+	users := user.NewService(db, userRepo, session.NewSessionRepository(db), auditService, nil, nil)
+	invitations := invitation.NewService(db, invitation.NewInvitationRepository(db, cipher), organization.NewOrganizationRepository(db), userRepo, users, auditService, token.NewManagerFromEnv())
+	jobs.Register(registry, user.SendEmailChangeConfirmationJob(users, mail))
+	jobs.Register(registry, invitation.SendInvitationJob(invitations, mail))
+
+	retention.Register(registry, db, cipher, Exports(db, cipher), retention.ConfigFromEnv())
+	return registry
+}
+
+// Exports returns the exporters of every module holding personal data. The API
+// exports them, and erasing users, by request or by the retention job, deletes
+// the data of those that are erasers.
+func Exports(db database.DBTX, cipher *pii.Cipher) *privacy.Registry {
+	return privacy.NewRegistry(
+		user.NewExporter(db, cipher),
+		session.NewExporter(db),
+		audit.NewExporter(db),
+		organization.NewExporter(db),
+		invitation.NewExporter(db, cipher),
+	)
+}
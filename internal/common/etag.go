@@ -0,0 +1,49 @@
+package common
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ETag returns the strong entity tag of a resource version
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ETagMatches reports whether an If-None-Match header matches etag, using the weak
+// comparison: "*" matches anything and W/ prefixes are ignored
+func ETagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatchVersions returns the versions an If-Match header accepts, nil for "*".
+// The header is "*" or a comma separated list of entity tags, which must match
+// strongly: weak tags and tags that are not a version are skipped, as they can
+// never match. ok is false when the header is malformed or accepts no version.
+func IfMatchVersions(header string) (versions []int, ok bool) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return nil, true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		weak := strings.HasPrefix(tag, "W/")
+		unquoted, found := strings.CutPrefix(strings.TrimPrefix(tag, "W/"), `"`)
+		if !found || len(unquoted) == 0 || !strings.HasSuffix(unquoted, `"`) {
+			return nil, false
+		}
+		v, err := strconv.Atoi(strings.TrimSuffix(unquoted, `"`))
+		if weak || err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	return versions, len(versions) > 0
+}
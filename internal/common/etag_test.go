@@ -0,0 +1,59 @@
+package common
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"3"`, true},
+		{`W/"3"`, true},
+		{`"2", "3"`, true},
+		{`"2",W/"3"`, true},
+		{`*`, true},
+		{`"4"`, false},
+		{`3`, false},
+		{`"2", "4"`, false},
+		{`"33"`, false},
+	}
+	for _, tt := range tests {
+		if got := ETagMatches(tt.header, ETag(3)); got != tt.want {
+			t.Errorf("ETagMatches(%s, %s) = %v, want %v", tt.header, ETag(3), got, tt.want)
+		}
+	}
+}
+
+func TestIfMatchVersions(t *testing.T) {
+	tests := []struct {
+		header   string
+		versions []int
+		ok       bool
+	}{
+		{`*`, nil, true},
+		{` * `, nil, true},
+		{`"3"`, []int{3}, true},
+		{`"3", "4"`, []int{3, 4}, true},
+		{`"3","4" , "5"`, []int{3, 4, 5}, true},
+		{`W/"3", "4"`, []int{4}, true},
+		{`"abc", "4"`, []int{4}, true},
+		{`W/"3"`, nil, false},
+		{`"abc"`, nil, false},
+		{`3`, nil, false},
+		{`"3`, nil, false},
+		{`"3", 4`, nil, false},
+		{`"3",`, nil, false},
+		{`""`, nil, false},
+		{`"`, nil, false},
+		{`"3", *`, nil, false},
+	}
+	for _, tt := range tests {
+		versions, ok := IfMatchVersions(tt.header)
+		if ok != tt.ok || !slices.Equal(versions, tt.versions) {
+			t.Errorf("IfMatchVersions(%s) = %v, %v, want %v, %v", tt.header, versions, ok, tt.versions, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package common
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+)
+
+// DefaultLocale is used when none of the Accept-Language locales is supported
+const DefaultLocale = "en"
+
+// catalogs holds the messages of every supported locale. Keys prefixed with
+// "tag." translate validator tags, the others describe request decoding errors.
+// {0} is the JSON field name and {1} the tag parameter.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"tag.required":          "{0} is required",
+		"tag.email":             "{0} must be a valid email address",
+		"tag.min-string":        "{0} must be at least {1} characters long",
+		"tag.min":               "{0} must be at least {1}",
+		"tag.max-string":        "{0} must not exceed {1} characters",
+		"tag.max":               "{0} must not exceed {1}",
+		"tag.len":               "{0} must be exactly {1} characters long",
+		"tag.gte":               "{0} must be greater than or equal to {1}",
+		"tag.lte":               "{0} must be less than or equal to {1}",
+		"tag.gt":                "{0} must be greater than {1}",
+		"tag.lt":                "{0} must be less than {1}",
+		"tag.alpha":             "{0} must contain only alphabetic characters",
+		"tag.alphanum":          "{0} must contain only alphanumeric characters",
+		"tag.numeric":           "{0} must be a valid number",
+		"tag.url":               "{0} must be a valid URL",
+		"tag.uri":               "{0} must be a valid URI",
+		"tag.oneof":             "{0} must be one of [{1}]",
+		"tag.uuid":              "{0} must be a valid UUID",
+		"tag.eqfield":           "{0} must equal {1}",
+		"tag.nefield":           "{0} must not equal {1}",
+		"tag.e164":              "{0} must be a valid phone number, include the country code for numbers outside the default region",
+		"tag.username":          "{0} may only contain letters, numbers, '.', '_' and '-', must start with a letter or number and must not be a reserved name",
+		"tag.password_strength": "{0} must contain lower case letters, upper case letters and a number",
+		"tag.slug":              "{0} may only contain lower case letters, numbers and '-', and must not start or end with '-'",
+		"invalid":               "{0} is invalid",
+		"type":                  "{0} must be {1}",
+		"type.string":           "a string",
+		"type.integer":          "an integer",
+		"type.number":           "a number",
+		"type.boolean":          "a boolean",
+		"type.object":           "an object",
+		"type.array":            "an array",
+		"type.null":             "null",
+		"unknown_field":         "{0} is not a recognized field",
+		"body_required":         "body is required",
+		"body_invalid":          "body must be valid JSON",
+		"body_too_large":        "body must not exceed {0} bytes",
+	},
+	"es": {
+		"tag.required":          "{0} es obligatorio",
+		"tag.email":             "{0} debe ser una dirección de correo electrónico válida",
+		"tag.min-string":        "{0} debe tener al menos {1} caracteres",
+		"tag.min":               "{0} debe ser como mínimo {1}",
+		"tag.max-string":        "{0} no debe superar los {1} caracteres",
+		"tag.max":               "{0} no debe superar {1}",
+		"tag.len":               "{0} debe tener exactamente {1} caracteres",
+		"tag.gte":               "{0} debe ser mayor o igual que {1}",
+		"tag.lte":               "{0} debe ser menor o igual que {1}",
+		"tag.gt":                "{0} debe ser mayor que {1}",
+		"tag.lt":                "{0} debe ser menor que {1}",
+		"tag.alpha":             "{0} solo puede contener letras",
+		"tag.alphanum":          "{0} solo puede contener letras y números",
+		"tag.numeric":           "{0} debe ser un número válido",
+		"tag.url":               "{0} debe ser una URL válida",
+		"tag.uri":               "{0} debe ser una URI válida",
+		"tag.oneof":             "{0} debe ser uno de [{1}]",
+		"tag.uuid":              "{0} debe ser un UUID válido",
+		"tag.eqfield":           "{0} debe ser igual a {1}",
+		"tag.nefield":           "{0} no debe ser igual a {1}",
+		"tag.e164":              "{0} debe ser un número de teléfono válido, incluya el código de país para números fuera de la región predeterminada",
+		"tag.username":          "{0} solo puede contener letras, números, '.', '_' y '-', debe empezar con una letra o un número y no puede ser un nombre reservado",
+		"tag.password_strength": "{0} debe contener letras minúsculas, letras mayúsculas y un número",
+		"tag.slug":              "{0} solo puede contener letras minúsculas, números y '-', y no puede empezar ni terminar con '-'",
+		"invalid":               "{0} no es válido",
+		"type":                  "{0} debe ser {1}",
+		"type.string":           "una cadena de texto",
+		"type.integer":          "un número entero",
+		"type.number":           "un número",
+		"type.boolean":          "un booleano",
+		"type.object":           "un objeto",
+		"type.array":            "una lista",
+		"type.null":             "nulo",
+		"unknown_field":         "{0} no es un campo reconocido",
+		"body_required":         "el cuerpo de la solicitud es obligatorio",
+		"body_invalid":          "el cuerpo de la solicitud debe ser JSON válido",
+		"body_too_large":        "el cuerpo de la solicitud no debe superar {0} bytes",
+	},
+}
+
+// universal holds a translator per locale with the catalog messages. It is built
+// during package initialization rather than on first use, so concurrent requests
+// never race to create it; SetupValidator adds the validator's messages once,
+// before requests are served.
+var universal = newUniversalTranslator()
+
+// newUniversalTranslator creates the translators of the supported locales with
+// the messages of their catalogs
+func newUniversalTranslator() *ut.UniversalTranslator {
+	english := en.New()
+	universal := ut.New(english, english, es.New())
+
+	for locale, catalog := range catalogs {
+		trans, _ := universal.GetTranslator(locale)
+		for key, text := range catalog {
+			if err := trans.Add(key, text, true); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return universal
+}
+
+// setupTranslations registers the validator's built-in messages for every locale
+// as a baseline, with the tags covered by the catalogs rendered from the catalogs
+func setupTranslations(v *validator.Validate) error {
+	defaults := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": en_translations.RegisterDefaultTranslations,
+		"es": es_translations.RegisterDefaultTranslations,
+	}
+
+	for locale, catalog := range catalogs {
+		trans, _ := universal.GetTranslator(locale)
+
+		if err := defaults[locale](v, trans); err != nil {
+			return err
+		}
+
+		for key := range catalog {
+			tag, ok := strings.CutPrefix(key, "tag.")
+			if !ok || strings.HasSuffix(tag, "-string") {
+				continue
+			}
+			err := v.RegisterTranslation(tag, trans, func(ut.Translator) error { return nil }, translateTag)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// translateTag renders a validation error with the catalog of the translator
+func translateTag(trans ut.Translator, fieldError validator.FieldError) string {
+	tag := fieldError.Tag()
+	param := fieldError.Param()
+
+	key := "tag." + tag
+	if (tag == "min" || tag == "max") && fieldError.Kind().String() == "string" {
+		key += "-string"
+	}
+
+	switch tag {
+	case "eqfield", "nefield":
+		// The parameter is a Go field name, refer to the JSON name instead
+		param = toSnakeCase(param)
+	}
+
+	message, err := trans.T(key, fieldError.Field(), param)
+	if err != nil {
+		return Message(trans, "invalid", fieldError.Field())
+	}
+	return message
+}
+
+// Translator returns the translator best matching an Accept-Language header.
+// Locales are tried in order of preference, each followed by its base language
+// (es-MX, then es), before falling back to DefaultLocale.
+func Translator(acceptLanguage string) ut.Translator {
+	trans, _ := universal.FindTranslator(localeCandidates(acceptLanguage)...)
+	return trans
+}
+
+// Message translates a catalog key, falling back to the key itself
+func Message(trans ut.Translator, key string, params ...string) string {
+	message, err := trans.T(key, params...)
+	if err != nil {
+		return key
+	}
+	return message
+}
+
+// localeCandidates lists locale names from an Accept-Language header by preference
+func localeCandidates(acceptLanguage string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality > 0 {
+			tags = append(tags, weighted{tag: tag, quality: quality})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].quality > tags[j].quality
+	})
+
+	var candidates []string
+	for _, tag := range tags {
+		// go-playground locales are named like es_MX
+		name := strings.ReplaceAll(tag.tag, "-", "_")
+		base, region, hasRegion := strings.Cut(name, "_")
+		base = strings.ToLower(base)
+		if hasRegion {
+			candidates = append(candidates, base+"_"+strings.ToUpper(region))
+		}
+		candidates = append(candidates, base)
+	}
+
+	return append(candidates, DefaultLocale)
+}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTranslatorFallback(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"es-MX", "es"},
+		{"es_mx", "es"},
+		{"es", "es"},
+		{"fr-CA, es-MX;q=0.8, en;q=0.5", "es"},
+		{"en-GB, es;q=0.9", "en"},
+		{"es;q=0, en", "en"},
+		{"de-DE", "en"},
+		{"*", "en"},
+		{"", "en"},
+	}
+	for _, tt := range tests {
+		if got := Translator(tt.acceptLanguage).Locale(); got != tt.want {
+			t.Errorf("Translator(%q) = %s, want %s", tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
+
+func TestLocaleCandidates(t *testing.T) {
+	got := localeCandidates("en;q=0.5, es-MX")
+	want := []string{"es_MX", "es", "en", "en"}
+	if len(got) != len(want) {
+		t.Fatalf("localeCandidates() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("localeCandidates() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestTranslatorMessages(t *testing.T) {
+	SetupValidator()
+	// A second setup, as every SetupRouter call does, must not fail or reset the catalogs
+	SetupValidator()
+
+	if got := Message(Translator("es-MX"), "body_required"); got != "el cuerpo de la solicitud es obligatorio" {
+		t.Errorf("Message(es-MX) = %q, want the Spanish message", got)
+	}
+	if got := Message(Translator("de"), "body_required"); got != "body is required" {
+		t.Errorf("Message(de) = %q, want the English message", got)
+	}
+	if got := Message(Translator("es"), "no_such_key"); got != "no_such_key" {
+		t.Errorf("Message() of a missing key = %q, want the key", got)
+	}
+}
+
+func TestTranslatorConcurrentUse(t *testing.T) {
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			SetupValidator()
+			Message(Translator("es-MX"), "body_required")
+		}()
+	}
+	wg.Wait()
+}
@@ -1,5 +1,110 @@
 package common
 
-func validate_phone(phone_number string) bool {
-	return len(phone_number) == 10 || len(phone_number) == 13
+import (
+	"errors"
+	"strings"
+
+	"metalcore-api/internal/config"
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// callingCodes maps ISO 3166 regions to their calling code and the allowed
+// lengths of the national significant number
+var callingCodes = map[string]struct {
+	code    string
+	lengths []int
+}{
+	"IN": {code: "91", lengths: []int{10}},
+	"US": {code: "1", lengths: []int{10}},
+	"CA": {code: "1", lengths: []int{10}},
+	"GB": {code: "44", lengths: []int{9, 10}},
+	"DE": {code: "49", lengths: []int{10, 11}},
+	"FR": {code: "33", lengths: []int{9}},
+	"ES": {code: "34", lengths: []int{9}},
+	"IT": {code: "39", lengths: []int{9, 10}},
+	"AU": {code: "61", lengths: []int{9}},
+	"SG": {code: "65", lengths: []int{8}},
+	"AE": {code: "971", lengths: []int{8, 9}},
+}
+
+// NormalizeEmail trims and lowercases an email address. Addresses are stored,
+// indexed and looked up normalized, so "Alice@Example.com" and "alice@example.com"
+// name the same account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// DefaultPhoneRegion is the region assumed for phone numbers without a country code
+func DefaultPhoneRegion() string {
+	return strings.ToUpper(config.GetEnv("DEFAULT_PHONE_REGION", "IN"))
+}
+
+// NormalizePhone converts a phone number to E.164 (+<country code><number>).
+// Spaces, dashes, dots and parentheses are ignored, "00" is accepted in place of
+// "+", and numbers without a country code are read as national numbers of
+// DefaultPhoneRegion (a leading trunk "0" is dropped, as is a trunk "(0)" written
+// after the country code). Numbers of known calling codes must have a valid
+// national length.
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.Replace(phone, "(0)", "", 1)
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, phone)
+
+	international := false
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		phone, international = phone[1:], true
+	case strings.HasPrefix(phone, "00"):
+		phone, international = phone[2:], true
+	}
+
+	if phone == "" || strings.IndexFunc(phone, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return "", ErrInvalidPhone
+	}
+
+	if !international {
+		region, ok := callingCodes[DefaultPhoneRegion()]
+		if !ok {
+			return "", ErrInvalidPhone
+		}
+		national := strings.TrimPrefix(phone, "0")
+		if !validLength(national, region.lengths) {
+			return "", ErrInvalidPhone
+		}
+		return "+" + region.code + national, nil
+	}
+
+	// E.164 allows at most 15 digits and country codes never start with 0
+	if phone[0] == '0' || len(phone) < 8 || len(phone) > 15 {
+		return "", ErrInvalidPhone
+	}
+
+	for _, region := range callingCodes {
+		if national, ok := strings.CutPrefix(phone, region.code); ok && validLength(national, region.lengths) {
+			return "+" + phone, nil
+		}
+	}
+	for _, region := range callingCodes {
+		if strings.HasPrefix(phone, region.code) {
+			// Known calling code with a national number of the wrong length
+			return "", ErrInvalidPhone
+		}
+	}
+
+	return "+" + phone, nil
+}
+
+func validLength(number string, lengths []int) bool {
+	for _, length := range lengths {
+		if len(number) == length {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		region string
+		phone  string
+		want   string
+	}{
+		// National numbers of the default region, with and without the trunk prefix
+		{"IN", "98765 43210", "+919876543210"},
+		{"IN", "098765-43210", "+919876543210"},
+		{"GB", "020 7946 0958", "+442079460958"},
+		{"GB", "07700 900123", "+447700900123"},
+		{"DE", "030 123456789", "+4930123456789"},
+		{"FR", "01 23 45 67 89", "+33123456789"},
+		{"US", "(415) 555-2671", "+14155552671"},
+		{"sg", "6123 4567", "+6561234567"},
+
+		// International numbers ignore the default region
+		{"IN", "+1 415 555 2671", "+14155552671"},
+		{"IN", "0044 20 7946 0958", "+442079460958"},
+		{"IN", "+44 (0)20 7946 0958", "+442079460958"},
+		{"IN", "+971 50 123 4567", "+971501234567"},
+		{"IN", "+61 412 345 678", "+61412345678"},
+		{"IN", "+39 06 1234 5678", "+390612345678"},
+		// Calling codes missing from the table are only checked against E.164
+		{"IN", "+81 3 1234 5678", "+81312345678"},
+
+		// Invalid numbers
+		{"IN", "", ""},
+		{"IN", "+", ""},
+		{"IN", "12345", ""},
+		{"IN", "98765432101", ""},
+		{"IN", "98765 4321x", ""},
+		{"IN", "+1 415 555 267", ""},
+		{"IN", "+44 20 7946 09581", ""},
+		{"IN", "+0 123 456 789", ""},
+		{"IN", "+81 3 1234 5678 90123", ""},
+		{"IN", "+81 12", ""},
+		{"XX", "98765 43210", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.region+" "+tt.phone, func(t *testing.T) {
+			t.Setenv("DEFAULT_PHONE_REGION", tt.region)
+			got, err := NormalizePhone(tt.phone)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidPhone) {
+					t.Fatalf("NormalizePhone(%q) = %q, %v, want ErrInvalidPhone", tt.phone, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("NormalizePhone(%q) = %q, %v, want %q", tt.phone, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultPhoneRegion(t *testing.T) {
+	t.Setenv("DEFAULT_PHONE_REGION", "")
+	if got := DefaultPhoneRegion(); got != "IN" {
+		t.Fatalf("DefaultPhoneRegion() = %q, want IN", got)
+	}
+	t.Setenv("DEFAULT_PHONE_REGION", "gb")
+	if got := DefaultPhoneRegion(); got != "GB" {
+		t.Fatalf("DefaultPhoneRegion() = %q, want GB", got)
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	for _, email := range []string{"alice@example.com", "Alice@Example.com", " ALICE@EXAMPLE.COM\t"} {
+		if got := NormalizeEmail(email); got != "alice@example.com" {
+			t.Errorf("NormalizeEmail(%q) = %q, want alice@example.com", email, got)
+		}
+	}
+}
@@ -1,77 +1,192 @@
 package common
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
 
+	"github.com/gin-gonic/gin/binding"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 )
 
-// FormatValidationErrors converts validator errors into a user-friendly map
-func FormatValidationErrors(err error) map[string]string {
-	errors := make(map[string]string)
+var setupOnce sync.Once
 
-	if validationErrs, ok := err.(validator.ValidationErrors); ok {
-		for _, fieldError := range validationErrs {
-			fieldName := toSnakeCase(fieldError.Field())
-			errors[fieldName] = getErrorMessage(fieldError)
+// SetupValidator configures gin's validator engine so that validation errors
+// refer to fields by their JSON (or query) name rather than the Go struct name.
+// The engine is shared by the process, it is only configured on the first call.
+func SetupValidator() {
+	setupOnce.Do(setupValidator)
+}
+
+func setupValidator() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
 		}
+		return field.Name
+	})
+
+	v.RegisterValidation("e164", validateE164)
+	v.RegisterValidation("username", validateUsername)
+	v.RegisterValidation("password_strength", validatePasswordStrength)
+	v.RegisterValidation("slug", validateSlug)
+
+	if err := setupTranslations(v); err != nil {
+		panic(err)
 	}
+}
+
+// reservedUsernames cannot be registered, they could impersonate staff or clash with routes such as /users/me
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true, "sysadmin": true,
+	"support": true, "help": true, "security": true, "staff": true, "moderator": true,
+	"api": true, "me": true, "null": true, "undefined": true, "anonymous": true,
+}
 
-	return errors
+// validateE164 accepts phone numbers that NormalizePhone can convert to E.164
+func validateE164(fl validator.FieldLevel) bool {
+	_, err := NormalizePhone(fl.Field().String())
+	return err == nil
 }
 
-// getErrorMessage returns a user-friendly error message for a validation error
-func getErrorMessage(fieldError validator.FieldError) string {
-	field := fieldError.Field()
-	tag := fieldError.Tag()
-	param := fieldError.Param()
-
-	switch tag {
-	case "required":
-		return fmt.Sprintf("%s is required", field)
-	case "email":
-		return fmt.Sprintf("%s must be a valid email address", field)
-	case "min":
-		if fieldError.Type().String() == "string" {
-			return fmt.Sprintf("%s must be at least %s characters long", field, param)
+// validateUsername allows letters, digits, '.', '_' and '-', starting with a letter
+// or digit, and rejects reserved names regardless of case
+func validateUsername(fl validator.FieldLevel) bool {
+	username := fl.Field().String()
+	if username == "" || reservedUsernames[strings.ToLower(username)] {
+		return false
+	}
+
+	for i, char := range username {
+		isAlnum := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+		if isAlnum {
+			continue
+		}
+		if i == 0 || (char != '.' && char != '_' && char != '-') {
+			return false
 		}
-		return fmt.Sprintf("%s must be at least %s", field, param)
-	case "max":
-		if fieldError.Type().String() == "string" {
-			return fmt.Sprintf("%s must not exceed %s characters", field, param)
+	}
+	return true
+}
+
+// validateSlug accepts DNS labels so that slugs can name subdomains: lower case
+// letters, digits and '-', not starting or ending with '-'
+func validateSlug(fl validator.FieldLevel) bool {
+	slug := fl.Field().String()
+	if slug == "" || strings.HasPrefix(slug, "-") || strings.HasSuffix(slug, "-") {
+		return false
+	}
+
+	for _, char := range slug {
+		if (char < 'a' || char > 'z') && (char < '0' || char > '9') && char != '-' {
+			return false
 		}
-		return fmt.Sprintf("%s must not exceed %s", field, param)
-	case "len":
-		return fmt.Sprintf("%s must be exactly %s characters long", field, param)
-	case "gte":
-		return fmt.Sprintf("%s must be greater than or equal to %s", field, param)
-	case "lte":
-		return fmt.Sprintf("%s must be less than or equal to %s", field, param)
-	case "gt":
-		return fmt.Sprintf("%s must be greater than %s", field, param)
-	case "lt":
-		return fmt.Sprintf("%s must be less than %s", field, param)
-	case "alpha":
-		return fmt.Sprintf("%s must contain only alphabetic characters", field)
-	case "alphanum":
-		return fmt.Sprintf("%s must contain only alphanumeric characters", field)
-	case "numeric":
-		return fmt.Sprintf("%s must be a valid number", field)
-	case "url":
-		return fmt.Sprintf("%s must be a valid URL", field)
-	case "uri":
-		return fmt.Sprintf("%s must be a valid URI", field)
-	case "oneof":
-		return fmt.Sprintf("%s must be one of [%s]", field, param)
-	case "uuid":
-		return fmt.Sprintf("%s must be a valid UUID", field)
-	case "eqfield":
-		return fmt.Sprintf("%s must equal %s", field, param)
-	case "nefield":
-		return fmt.Sprintf("%s must not equal %s", field, param)
+	}
+	return true
+}
+
+// validatePasswordStrength requires lower and upper case letters and a digit
+func validatePasswordStrength(fl validator.FieldLevel) bool {
+	var hasLower, hasUpper, hasDigit bool
+	for _, char := range fl.Field().String() {
+		switch {
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		}
+	}
+	return hasLower && hasUpper && hasDigit
+}
+
+// FormatValidationErrors converts validator and request decoding errors into a
+// user-friendly map keyed by JSON field name, in the language preferred by the
+// Accept-Language header
+func FormatValidationErrors(err error, acceptLanguage string) map[string]string {
+	errs := make(map[string]string)
+	trans := Translator(acceptLanguage)
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fieldError := range validationErrs {
+			fieldName := toSnakeCase(fieldError.Field())
+			errs[fieldName] = getErrorMessage(fieldError, trans)
+		}
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		errs[field] = Message(trans, "type", field, Message(trans, "type."+jsonType(typeErr.Type)))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		errs["body"] = Message(trans, "body_invalid")
+	case errors.Is(err, io.EOF):
+		errs["body"] = Message(trans, "body_required")
+	case errors.As(err, &maxBytesErr):
+		errs["body"] = Message(trans, "body_too_large", strconv.FormatInt(maxBytesErr.Limit, 10))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		errs[field] = Message(trans, "unknown_field", field)
+	}
+
+	return errs
+}
+
+// getErrorMessage returns the translated message for a validation error, tags
+// without a translation get a generic message
+func getErrorMessage(fieldError validator.FieldError, trans ut.Translator) string {
+	message := fieldError.Translate(trans)
+	if message == fieldError.Error() {
+		return Message(trans, "invalid", fieldError.Field())
+	}
+	return message
+}
+
+// jsonType names the JSON type expected for a Go type
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
 	default:
-		return fmt.Sprintf("%s is invalid", field)
+		return "object"
 	}
 }
 
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+type bindTarget struct {
+	FirstName string `json:"first_name" binding:"required,min=2"`
+	Age       int    `json:"age"`
+	Account   *struct {
+		PhoneNumber string `json:"phone_number" binding:"required"`
+	} `json:"account" binding:"omitempty"`
+}
+
+func TestFormatValidationErrors(t *testing.T) {
+	SetupValidator()
+
+	bind := func(body string) error {
+		var target bindTarget
+		return binding.JSON.BindBody([]byte(body), &target)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want map[string]string
+	}{
+		{"missing field", bind(`{}`), map[string]string{"first_name": "first_name is required"}},
+		{"too short", bind(`{"first_name":"a"}`), map[string]string{"first_name": "first_name must be at least 2 characters long"}},
+		{"wrong type", bind(`{"first_name":"ab","age":"old"}`), map[string]string{"age": "age must be an integer"}},
+		{"nested field", bind(`{"first_name":"ab","account":{}}`), map[string]string{"phone_number": "phone_number is required"}},
+		{"wrong nested type", bind(`{"first_name":"ab","account":{"phone_number":5}}`), map[string]string{"account.phone_number": "account.phone_number must be a string"}},
+		{"wrong body type", bind(`[]`), map[string]string{"body": "body must be an object"}},
+		{"empty body", bind(``), map[string]string{"body": "body is required"}},
+		{"malformed body", bind(`{"first_name":`), map[string]string{"body": "body must be valid JSON"}},
+		{"unknown field", errors.New(`json: unknown field "nickname"`), map[string]string{"nickname": "nickname is not a recognized field"}},
+		{"too large", &http.MaxBytesError{Limit: 10}, map[string]string{"body": "body must not exceed 10 bytes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatValidationErrors(tt.err, "")
+			if len(got) != len(tt.want) {
+				t.Fatalf("FormatValidationErrors(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+			for field, message := range tt.want {
+				if got[field] != message {
+					t.Errorf("FormatValidationErrors(%v)[%q] = %q, want %q", tt.err, field, got[field], message)
+				}
+			}
+		})
+	}
+}
+
+func TestCustomValidators(t *testing.T) {
+	t.Setenv("DEFAULT_PHONE_REGION", "IN")
+	v := validator.New()
+	v.RegisterValidation("e164", validateE164)
+	v.RegisterValidation("username", validateUsername)
+	v.RegisterValidation("password_strength", validatePasswordStrength)
+	v.RegisterValidation("slug", validateSlug)
+
+	tests := []struct {
+		tag   string
+		value string
+		valid bool
+	}{
+		{"e164", "+14155552671", true},
+		{"e164", "98765 43210", true},
+		{"e164", "+44 (0)20 7946 0958", true},
+		{"e164", "", false},
+		{"e164", "phone", false},
+		{"e164", "+1 415", false},
+
+		{"username", "alice", true},
+		{"username", "Alice.Smith_99-x", true},
+		{"username", "7seas", true},
+		{"username", "", false},
+		{"username", ".alice", false},
+		{"username", "_alice", false},
+		{"username", "-alice", false},
+		{"username", "alice smith", false},
+		{"username", "alice@example", false},
+		{"username", "álice", false},
+		{"username", "admin", false},
+		{"username", "Admin", false},
+		{"username", "ME", false},
+
+		{"password_strength", "Secret123", true},
+		{"password_strength", "Ünïcode9x", true},
+		{"password_strength", "secret123", false},
+		{"password_strength", "SECRET123", false},
+		{"password_strength", "SecretPass", false},
+		{"password_strength", "", false},
+
+		{"slug", "acme", true},
+		{"slug", "acme-2", true},
+		{"slug", "", false},
+		{"slug", "-acme", false},
+		{"slug", "acme-", false},
+		{"slug", "Acme", false},
+		{"slug", "acme_corp", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag+" "+tt.value, func(t *testing.T) {
+			err := v.Var(tt.value, tt.tag)
+			if (err == nil) != tt.valid {
+				t.Fatalf("%s(%q) = %v, want valid %v", tt.tag, tt.value, err, tt.valid)
+			}
+		})
+	}
+}
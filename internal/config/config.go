@@ -1,7 +1,11 @@
 package config
 
 import (
-	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,6 +20,71 @@ func LoadEnv() {
 	}
 
 	if err != nil {
-		log.Println("No .env file found.")
+		slog.Info("no .env file found")
 	}
 }
+
+// GetEnv returns the value of an environment variable or the fallback if it is unset
+func GetEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvInt returns an integer environment variable or the fallback if it is unset or invalid
+func GetEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid integer environment variable, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// GetEnvBool returns a boolean environment variable or the fallback if it is unset or invalid
+func GetEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid boolean environment variable, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// GetEnvDuration returns a duration environment variable (e.g. "15m") or the fallback if it is unset or invalid
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration environment variable, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// GetEnvList returns a comma separated environment variable, or the fallback if it is
+// unset, as a list of its trimmed non-empty items
+func GetEnvList(key, fallback string) []string {
+	var items []string
+	for _, item := range strings.Split(GetEnv(key, fallback), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
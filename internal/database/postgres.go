@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 
+	"metalcore-api/internal/tracing"
+
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -13,12 +15,22 @@ var DB *pgxpool.Pool
 func ConnectDB() {
 	db_url := os.Getenv("DATABASE_URL")
 
-	pool, err := pgxpool.New(context.Background(), db_url)
+	cfg, err := pgxpool.ParseConfig(db_url)
+	if err != nil {
+		slog.Error("error while parsing DATABASE_URL", "error", err)
+		os.Exit(1)
+	}
+
+	// Every query gets its own span in the request's trace
+	cfg.ConnConfig.Tracer = tracing.NewPgxTracer()
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
 
 	if err != nil {
-		log.Fatal("Error while connecting to DB.")
+		slog.Error("error while connecting to DB", "error", err)
+		os.Exit(1)
 	}
 
 	DB = pool
-	log.Println("Database connection successfull.")
+	slog.Info("database connection successful")
 }
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/metrics"
+	"metalcore-api/internal/retry"
+	"metalcore-api/internal/tracing"
+)
+
+// DispatcherConfig controls how often the outbox is polled and how failed events are retried
+type DispatcherConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Lease        time.Duration // How long a claimed event is reserved for its delivery
+}
+
+// DispatcherConfigFromEnv reads the OUTBOX_* variables
+func DispatcherConfigFromEnv() DispatcherConfig {
+	return DispatcherConfig{
+		PollInterval: config.GetEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		BatchSize:    config.GetEnvInt("OUTBOX_BATCH_SIZE", 100),
+		MaxAttempts:  config.GetEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+		BaseBackoff:  config.GetEnvDuration("OUTBOX_BASE_BACKOFF", time.Second),
+		MaxBackoff:   config.GetEnvDuration("OUTBOX_MAX_BACKOFF", time.Hour),
+		Lease:        config.GetEnvDuration("OUTBOX_LEASE", 5*time.Minute),
+	}
+}
+
+// outbox is the storage the dispatcher claims events from and records outcomes in
+type outbox interface {
+	claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error)
+	markDispatched(ctx context.Context, eventID int64) error
+	markFailed(ctx context.Context, eventID int64, lastError string, nextAttemptAt time.Time, dead bool) error
+}
+
+// Dispatcher delivers outbox events to its sinks
+type Dispatcher struct {
+	outbox outbox
+	sinks  []Sink
+	config DispatcherConfig
+}
+
+func NewDispatcher(db database.DBTX, sinks []Sink, config DispatcherConfig) *Dispatcher {
+	return &Dispatcher{outbox: &postgresOutbox{db: db}, sinks: sinks, config: config}
+}
+
+// Run polls the outbox until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	logger.Info("outbox dispatcher started", "sinks", len(d.sinks), "poll_interval", d.config.PollInterval)
+
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches right away, then wait for the next tick
+		for {
+			claimed, err := d.DispatchBatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Error("error while dispatching outbox events", "error", err)
+			}
+			if err != nil || claimed < d.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("outbox dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchBatch delivers one batch of due events and returns how many were claimed.
+// Claiming leases the events for DispatcherConfig.Lease in its own short statement,
+// the sinks are called without holding a transaction or row locks, and each outcome
+// is recorded as soon as the event has been delivered. An event whose dispatcher
+// dies mid-delivery is claimed again once its lease has expired.
+func (d *Dispatcher) DispatchBatch(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "events.Dispatcher.DispatchBatch")
+	defer span.End()
+
+	pending, err := d.outbox.claim(ctx, d.config.BatchSize, d.config.Lease)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+
+	var errs []error
+	for _, event := range pending {
+		if err := d.dispatch(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		tracing.RecordError(span, err)
+	}
+
+	return len(pending), err
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, event Event) error {
+	ctx = logging.With(ctx, "event_id", event.EventID, "event_type", event.EventType)
+
+	var errs []error
+	for _, sink := range d.sinks {
+		if err := sink.Deliver(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+
+	if len(errs) == 0 {
+		metrics.OutboxEvents.WithLabelValues("dispatched").Inc()
+		return d.outbox.markDispatched(ctx, event.EventID)
+	}
+
+	deliveryErr := errors.Join(errs...)
+	attempts := event.Attempts + 1
+	dead := attempts >= d.config.MaxAttempts
+	if dead {
+		metrics.OutboxEvents.WithLabelValues("dead").Inc()
+		logging.FromContext(ctx).Error("outbox event moved to dead letter", "attempts", attempts, "error", deliveryErr)
+	} else {
+		metrics.OutboxEvents.WithLabelValues("retried").Inc()
+		logging.FromContext(ctx).Warn("outbox event delivery failed, retrying", "attempts", attempts, "error", deliveryErr)
+	}
+
+	return d.outbox.markFailed(ctx, event.EventID, deliveryErr.Error(), time.Now().Add(retry.Backoff(d.config.BaseBackoff, d.config.MaxBackoff, attempts)), dead)
+}
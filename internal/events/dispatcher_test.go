@@ -0,0 +1,225 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryOutbox claims events by the same rules as the OutboxEvent queries: only
+// the oldest pending event of an aggregate is due, and claiming leases it
+type memoryOutbox struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (o *memoryOutbox) claim(_ context.Context, limit int, lease time.Duration) ([]Event, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	blocked := map[string]bool{}
+	var claimed []Event
+	for _, event := range o.events {
+		if event.DispatchedAt != nil || event.DeadAt != nil {
+			continue
+		}
+		aggregate := event.AggregateType + "/" + event.AggregateID
+		if blocked[aggregate] {
+			continue
+		}
+		blocked[aggregate] = true
+		if event.NextAttemptAt.After(now) || len(claimed) == limit {
+			continue
+		}
+		event.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, *event)
+	}
+	return claimed, nil
+}
+
+func (o *memoryOutbox) find(eventID int64) *Event {
+	for _, event := range o.events {
+		if event.EventID == eventID && event.DispatchedAt == nil && event.DeadAt == nil {
+			return event
+		}
+	}
+	return nil
+}
+
+func (o *memoryOutbox) markDispatched(_ context.Context, eventID int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if event := o.find(eventID); event != nil {
+		now := time.Now()
+		event.Attempts++
+		event.DispatchedAt = &now
+	}
+	return nil
+}
+
+func (o *memoryOutbox) markFailed(_ context.Context, eventID int64, lastError string, nextAttemptAt time.Time, dead bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if event := o.find(eventID); event != nil {
+		event.Attempts++
+		event.LastError = &lastError
+		event.NextAttemptAt = nextAttemptAt
+		if dead {
+			now := time.Now()
+			event.DeadAt = &now
+		}
+	}
+	return nil
+}
+
+func (o *memoryOutbox) pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	count := 0
+	for _, event := range o.events {
+		if event.DispatchedAt == nil && event.DeadAt == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// recordingSink records every delivery attempt per aggregate and fails the first
+// attempts of the events in failures
+type recordingSink struct {
+	mu         sync.Mutex
+	failures   map[int64]int
+	inFlight   map[string]bool
+	overlapped bool
+	attempts   map[string][]string
+}
+
+func (s *recordingSink) Name() string {
+	return "recording"
+}
+
+func (s *recordingSink) Deliver(_ context.Context, event Event) error {
+	aggregate := event.AggregateType + "/" + event.AggregateID
+
+	s.mu.Lock()
+	if s.inFlight[aggregate] {
+		s.overlapped = true
+	}
+	s.inFlight[aggregate] = true
+	s.mu.Unlock()
+
+	// Give other dispatchers the chance to claim while the delivery is running
+	time.Sleep(time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight[aggregate] = false
+
+	if s.failures[event.EventID] > 0 {
+		s.failures[event.EventID]--
+		s.attempts[aggregate] = append(s.attempts[aggregate], fmt.Sprintf("%d failed", event.EventID))
+		return errors.New("unavailable")
+	}
+	s.attempts[aggregate] = append(s.attempts[aggregate], fmt.Sprint(event.EventID))
+	return nil
+}
+
+func TestDispatcherKeepsAggregateOrder(t *testing.T) {
+	outbox := &memoryOutbox{}
+	aggregates := []string{"1", "2", "1", "3", "1", "2", "3", "1"}
+	for i, id := range aggregates {
+		outbox.events = append(outbox.events, &Event{
+			EventID:       int64(i + 1),
+			AggregateType: AggregateUser,
+			AggregateID:   id,
+			EventType:     UserUpdated,
+			NextAttemptAt: time.Now(),
+		})
+	}
+
+	sink := &recordingSink{
+		failures: map[int64]int{1: 2, 6: 1},
+		inFlight: map[string]bool{},
+		attempts: map[string][]string{},
+	}
+	dispatcher := &Dispatcher{
+		outbox: outbox,
+		sinks:  []Sink{sink},
+		config: DispatcherConfig{BatchSize: 2, MaxAttempts: 5, Lease: time.Minute},
+	}
+
+	// Several dispatchers share the outbox, failed events are due again right away
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil && outbox.pending() > 0 {
+				if _, err := dispatcher.DispatchBatch(ctx); err != nil {
+					t.Errorf("DispatchBatch() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if pending := outbox.pending(); pending != 0 {
+		t.Fatalf("%d events still pending", pending)
+	}
+	if sink.overlapped {
+		t.Fatalf("events of an aggregate were delivered concurrently")
+	}
+
+	want := map[string][]string{
+		"user/1": {"1 failed", "1 failed", "1", "3", "5", "8"},
+		"user/2": {"2", "6 failed", "6"},
+		"user/3": {"4", "7"},
+	}
+	for aggregate, attempts := range want {
+		if got := sink.attempts[aggregate]; !slices.Equal(got, attempts) {
+			t.Fatalf("deliveries of %s = %v, want %v", aggregate, got, attempts)
+		}
+	}
+}
+
+func TestDispatcherDeadLetterUnblocksAggregate(t *testing.T) {
+	outbox := &memoryOutbox{events: []*Event{
+		{EventID: 1, AggregateType: AggregateUser, AggregateID: "1", EventType: UserUpdated, NextAttemptAt: time.Now()},
+		{EventID: 2, AggregateType: AggregateUser, AggregateID: "1", EventType: UserUpdated, NextAttemptAt: time.Now()},
+	}}
+	sink := &recordingSink{
+		failures: map[int64]int{1: 10},
+		inFlight: map[string]bool{},
+		attempts: map[string][]string{},
+	}
+	dispatcher := &Dispatcher{
+		outbox: outbox,
+		sinks:  []Sink{sink},
+		config: DispatcherConfig{BatchSize: 10, MaxAttempts: 2, Lease: time.Minute},
+	}
+
+	for range 3 {
+		if _, err := dispatcher.DispatchBatch(context.Background()); err != nil {
+			t.Fatalf("DispatchBatch() error = %v", err)
+		}
+	}
+
+	if outbox.events[0].DeadAt == nil || outbox.events[0].Attempts != 2 {
+		t.Fatalf("event 1 = %+v, want dead after 2 attempts", outbox.events[0])
+	}
+	if got, want := sink.attempts["user/1"], []string{"1 failed", "1 failed", "2"}; !slices.Equal(got, want) {
+		t.Fatalf("deliveries = %v, want %v", got, want)
+	}
+}
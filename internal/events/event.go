@@ -0,0 +1,35 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Aggregate types
+const (
+	AggregateUser = "user"
+)
+
+// Event types
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+	UserErased  = "user.erased"
+)
+
+// Event is a domain event stored in the outbox until it has been delivered to every sink
+type Event struct {
+	EventID       int64           `db:"EventId" json:"event_id"`
+	AggregateType string          `db:"AggregateType" json:"aggregate_type"`
+	AggregateID   string          `db:"AggregateId" json:"aggregate_id"`
+	EventType     string          `db:"EventType" json:"event_type"`
+	Payload       json.RawMessage `db:"Payload" json:"payload"`
+	RequestID     *string         `db:"RequestId" json:"request_id,omitempty"`
+	OccurredAt    time.Time       `db:"OccurredAt" json:"occurred_at"`
+	Attempts      int             `db:"Attempts" json:"-"`
+	NextAttemptAt time.Time       `db:"NextAttemptAt" json:"-"`
+	LastError     *string         `db:"LastError" json:"-"`
+	DispatchedAt  *time.Time      `db:"DispatchedAt" json:"-"`
+	DeadAt        *time.Time      `db:"DeadAt" json:"-"`
+}
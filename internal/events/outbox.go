@@ -0,0 +1,200 @@
+package events
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+)
+
+// Publish writes an event to the outbox using db, which should be the transaction
+// of the change so that the event is only dispatched if the change commits
+func Publish(ctx context.Context, db database.DBTX, aggregateType, aggregateID, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var requestID *string
+	if id := logging.RequestID(ctx); id != "" {
+		requestID = &id
+	}
+
+	query := `
+		INSERT INTO public."OutboxEvent" (
+			"AggregateType",
+			"AggregateId",
+			"EventType",
+			"Payload",
+			"RequestId"
+		)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err = db.Exec(ctx, query, aggregateType, aggregateID, eventType, data, requestID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while writing outbox event", "event_type", eventType, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// postgresOutbox stores the outbox in the OutboxEvent table
+type postgresOutbox struct {
+	db database.DBTX
+}
+
+// claim leases up to limit due events by moving their NextAttemptAt past the lease.
+// Only the oldest pending event of each aggregate is eligible, and a leased event
+// stays pending, so events of an aggregate are delivered one at a time and in
+// order. SKIP LOCKED lets several dispatchers share the outbox.
+func (o *postgresOutbox) claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error) {
+	query := `
+		UPDATE public."OutboxEvent"
+		SET "NextAttemptAt" = NOW() + $2::INTERVAL
+		WHERE "EventId" IN (
+			SELECT e."EventId"
+			FROM public."OutboxEvent" e
+			WHERE e."DispatchedAt" IS NULL
+			  AND e."DeadAt" IS NULL
+			  AND e."NextAttemptAt" <= NOW()
+			  AND NOT EXISTS (
+				SELECT 1
+				FROM public."OutboxEvent" p
+				WHERE p."AggregateType" = e."AggregateType"
+				  AND p."AggregateId" = e."AggregateId"
+				  AND p."DispatchedAt" IS NULL
+				  AND p."DeadAt" IS NULL
+				  AND p."EventId" < e."EventId"
+			  )
+			ORDER BY e."EventId"
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING
+			"EventId",
+			"AggregateType",
+			"AggregateId",
+			"EventType",
+			"Payload",
+			"RequestId",
+			"OccurredAt",
+			"Attempts"
+	`
+
+	rows, err := o.db.Query(ctx, query, limit, lease)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in claim", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []Event
+	for rows.Next() {
+		var event Event
+		err := rows.Scan(
+			&event.EventID,
+			&event.AggregateType,
+			&event.AggregateID,
+			&event.EventType,
+			&event.Payload,
+			&event.RequestID,
+			&event.OccurredAt,
+			&event.Attempts,
+		)
+		if err != nil {
+			logging.FromContext(ctx).Error("error scanning outbox row", "error", err)
+			return nil, err
+		}
+		pending = append(pending, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("error iterating rows", "error", err)
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the subquery
+	slices.SortFunc(pending, func(a, b Event) int { return cmp.Compare(a.EventID, b.EventID) })
+
+	return pending, nil
+}
+
+// markDispatched and markFailed skip events that are no longer pending, which
+// happens when a lease expired and another dispatcher recorded the outcome first
+func (o *postgresOutbox) markDispatched(ctx context.Context, eventID int64) error {
+	_, err := o.db.Exec(ctx, `
+		UPDATE public."OutboxEvent"
+		SET "DispatchedAt" = NOW(),
+			"Attempts" = "Attempts" + 1,
+			"LastError" = NULL
+		WHERE "EventId" = $1
+		  AND "DispatchedAt" IS NULL
+		  AND "DeadAt" IS NULL
+	`, eventID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while marking outbox event dispatched", "error", err)
+	}
+	return err
+}
+
+// markFailed schedules another attempt at nextAttemptAt, or moves the event to the
+// dead-letter state when dead is set
+func (o *postgresOutbox) markFailed(ctx context.Context, eventID int64, lastError string, nextAttemptAt time.Time, dead bool) error {
+	_, err := o.db.Exec(ctx, `
+		UPDATE public."OutboxEvent"
+		SET "Attempts" = "Attempts" + 1,
+			"LastError" = $2,
+			"NextAttemptAt" = $3,
+			"DeadAt" = CASE WHEN $4 THEN NOW() ELSE NULL END
+		WHERE "EventId" = $1
+		  AND "DispatchedAt" IS NULL
+		  AND "DeadAt" IS NULL
+	`, eventID, lastError, nextAttemptAt, dead)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while marking outbox event failed", "error", err)
+	}
+	return err
+}
+
+// DeleteFinished deletes up to limit events dispatched or dead-lettered before
+// before, or only counts them in dry run mode
+func DeleteFinished(ctx context.Context, db database.DBTX, before time.Time, limit int, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := db.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM public."OutboxEvent"
+			WHERE "DispatchedAt" < $1
+			   OR "DeadAt" < $1
+		`, before).Scan(&count)
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in DeleteFinished (count)", "error", err)
+		}
+		return count, err
+	}
+
+	query := `
+		DELETE FROM public."OutboxEvent"
+		WHERE "EventId" IN (
+			SELECT "EventId"
+			FROM public."OutboxEvent"
+			WHERE "DispatchedAt" < $1
+			   OR "DeadAt" < $1
+			LIMIT $2
+		)
+	`
+
+	tag, err := db.Exec(ctx, query, before, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting finished outbox events", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,148 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/logging"
+)
+
+// Sink receives dispatched events. Delivery is at-least-once: an event is delivered
+// again to every sink when any of them fails, so sinks must be idempotent on EventID.
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, event Event) error
+}
+
+// NewSinksFromEnv returns the sinks listed in EVENTS_SINKS ("log", "webhook"),
+// followed by the in-process bus which is always attached
+func NewSinksFromEnv(bus *Bus) []Sink {
+	var sinks []Sink
+	for _, name := range strings.Split(config.GetEnv("EVENTS_SINKS", "log"), ",") {
+		switch strings.TrimSpace(name) {
+		case "log":
+			sinks = append(sinks, &LogSink{})
+		case "webhook":
+			url := config.GetEnv("EVENTS_WEBHOOK_URL", "")
+			if url == "" {
+				logging.FromContext(context.Background()).Warn("EVENTS_WEBHOOK_URL is not set, webhook sink disabled")
+				continue
+			}
+			sinks = append(sinks, NewWebhookSink(url, config.GetEnvDuration("EVENTS_WEBHOOK_TIMEOUT", 10*time.Second)))
+		case "":
+		default:
+			logging.FromContext(context.Background()).Warn("unknown event sink, ignoring", "sink", name)
+		}
+	}
+	return append(sinks, bus)
+}
+
+// LogSink writes events to the log
+type LogSink struct{}
+
+func (s *LogSink) Name() string {
+	return "log"
+}
+
+func (s *LogSink) Deliver(ctx context.Context, event Event) error {
+	logging.FromContext(ctx).Info(
+		"domain event",
+		"event_id", event.EventID,
+		"event_type", event.EventType,
+		"aggregate_type", event.AggregateType,
+		"aggregate_id", event.AggregateID,
+	)
+	return nil
+}
+
+// WebhookSink POSTs every event as JSON to a single URL. Any response other than
+// 2xx counts as a failure and the event is retried.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Deliver(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", fmt.Sprint(event.EventID))
+	req.Header.Set("X-Event-Type", event.EventType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Handler processes an event delivered by the in-process bus
+type Handler func(ctx context.Context, event Event) error
+
+// Bus delivers events to handlers subscribed within the process
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+// AllEvents subscribes a handler to every event type
+const AllEvents = "*"
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[string][]Handler{}}
+}
+
+// Subscribe registers handler for eventType, or for every event with AllEvents
+func (b *Bus) Subscribe(eventType string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+func (b *Bus) Name() string {
+	return "inprocess"
+}
+
+func (b *Bus) Deliver(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler{}, b.handlers[event.EventType]...), b.handlers[AllEvents]...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
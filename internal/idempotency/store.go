@@ -0,0 +1,223 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrInFlight is returned while another request with the key is being processed
+	ErrInFlight = errors.New("a request with this idempotency key is in progress")
+	// ErrMismatch is returned when the key was used for a different request
+	ErrMismatch = errors.New("idempotency key was used for a different request")
+	// ErrClaimLost is returned when completing a request whose claim timed out and
+	// was taken by another request, or whose key expired and was reused
+	ErrClaimLost = errors.New("idempotency key is no longer held by the request")
+)
+
+// Response is a stored response, replayed to retries of the request
+type Response struct {
+	Status  int
+	Headers http.Header
+	Body    []byte
+}
+
+// Store keeps idempotency keys with the fingerprint of the request that used them
+// and, once it completed, its response
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db database.DBTX) *Store {
+	return &Store{db: db}
+}
+
+// Acquire claims key for the request with fingerprint and returns nil when the
+// caller should process it. When the request was already processed its stored
+// response is returned instead. The claim expires after lockTimeout so that a
+// crashed request does not block retries, the key itself after ttl.
+func (s *Store) Acquire(ctx context.Context, key string, fingerprint []byte, lockTimeout, ttl time.Duration) (*Response, error) {
+	// Claim new keys, expired keys, and keys abandoned by a request that never
+	// completed, provided the retry is the same request
+	var claimed bool
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO public."IdempotencyKey" (
+			"Key",
+			"Fingerprint",
+			"LockedUntil",
+			"ExpiresAt"
+		)
+		VALUES ($1, $2, NOW() + $3::INTERVAL, NOW() + $4::INTERVAL)
+		ON CONFLICT ("Key") DO UPDATE
+		SET "Fingerprint" = EXCLUDED."Fingerprint",
+			"Status" = NULL,
+			"Headers" = NULL,
+			"Body" = NULL,
+			"CreatedAt" = NOW(),
+			"LockedUntil" = EXCLUDED."LockedUntil",
+			"ExpiresAt" = EXCLUDED."ExpiresAt"
+		WHERE "IdempotencyKey"."ExpiresAt" <= NOW()
+		   OR ("IdempotencyKey"."Status" IS NULL
+		       AND "IdempotencyKey"."LockedUntil" <= NOW()
+		       AND "IdempotencyKey"."Fingerprint" = EXCLUDED."Fingerprint")
+		RETURNING True
+	`, key, fingerprint, lockTimeout, ttl).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		logging.FromContext(ctx).Error("database error in Acquire (claim)", "error", err)
+		return nil, err
+	}
+
+	var storedFingerprint []byte
+	var response Response
+	var status *int
+
+	err = s.db.QueryRow(ctx, `
+		SELECT
+			"Fingerprint",
+			"Status",
+			"Headers",
+			"Body"
+		FROM public."IdempotencyKey"
+		WHERE "Key" = $1
+	`, key).Scan(
+		&storedFingerprint,
+		&status,
+		&response.Headers,
+		&response.Body,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Released by the request holding it in the meantime, let the client retry
+			return nil, ErrInFlight
+		}
+		logging.FromContext(ctx).Error("database error in Acquire (lookup)", "error", err)
+		return nil, err
+	}
+
+	if !bytes.Equal(storedFingerprint, fingerprint) {
+		return nil, ErrMismatch
+	}
+	if status == nil {
+		return nil, ErrInFlight
+	}
+
+	response.Status = *status
+	return &response, nil
+}
+
+// Complete stores the response of the request with fingerprint holding key and
+// releases the claim. It returns ErrClaimLost when the key holds another response
+// or the claim of a different request.
+func (s *Store) Complete(ctx context.Context, key string, fingerprint []byte, response Response) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE public."IdempotencyKey"
+		SET "Status" = $2,
+			"Headers" = $3,
+			"Body" = $4,
+			"LockedUntil" = NULL
+		WHERE "Key" = $1
+		  AND "Status" IS NULL
+		  AND "Fingerprint" = $5
+	`, key, response.Status, response.Headers, response.Body, fingerprint)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while storing idempotent response", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrClaimLost
+	}
+
+	return nil
+}
+
+// Release forgets a key claimed by the request with fingerprint that failed
+// without a response worth replaying, so that a retry processes it again
+func (s *Store) Release(ctx context.Context, key string, fingerprint []byte) error {
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM public."IdempotencyKey"
+		WHERE "Key" = $1
+		  AND "Status" IS NULL
+		  AND "Fingerprint" = $2
+	`, key, fingerprint)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while releasing idempotency key", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes up to limit expired keys and returns how many were deleted,
+// or with dryRun how many expired keys there are
+func (s *Store) DeleteExpired(ctx context.Context, limit int, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := s.db.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM public."IdempotencyKey"
+			WHERE "ExpiresAt" <= NOW()
+		`).Scan(&count)
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in DeleteExpired (count)", "error", err)
+		}
+		return count, err
+	}
+
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM public."IdempotencyKey"
+		WHERE "Key" IN (
+			SELECT "Key"
+			FROM public."IdempotencyKey"
+			WHERE "ExpiresAt" <= NOW()
+			LIMIT $1
+		)
+	`, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting expired idempotency keys", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteMentioning deletes the keys whose stored response contains any of the
+// values as a JSON string, such as the email of an erased user, so that personal
+// data is neither kept nor replayed
+func (s *Store) DeleteMentioning(ctx context.Context, values []string) (int64, error) {
+	patterns := make([][]byte, 0, len(values))
+	for _, value := range values {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return 0, err
+		}
+		patterns = append(patterns, encoded)
+	}
+
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM public."IdempotencyKey"
+		WHERE "Body" IS NOT NULL
+		  AND EXISTS (
+			SELECT 1
+			FROM unnest($1::BYTEA[]) AS p (pattern)
+			WHERE position(p.pattern IN "Body") > 0
+		  )
+	`, patterns)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting idempotency keys by content", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultMaxAttempts = 10
+
+// options of a single Enqueue call
+type options struct {
+	priority    int
+	runAt       time.Time
+	uniqueKey   *string
+	reference   *string
+	maxAttempts int
+}
+
+// Option customizes an enqueued job
+type Option func(*options)
+
+// WithPriority runs the job before queued jobs of lower priority (default 0)
+func WithPriority(priority int) Option {
+	return func(o *options) { o.priority = priority }
+}
+
+// RunAt schedules the job to run no earlier than at
+func RunAt(at time.Time) Option {
+	return func(o *options) { o.runAt = at }
+}
+
+// RunIn schedules the job to run after delay
+func RunIn(delay time.Duration) Option {
+	return RunAt(time.Now().Add(delay))
+}
+
+// Unique skips the enqueue while another job with the same key is queued or running
+func Unique(key string) Option {
+	return func(o *options) { o.uniqueKey = &key }
+}
+
+// WithReference names the record the job is about, such as "user:7", so that the
+// jobs of a record can be deleted with DeleteByReference when it is erased
+func WithReference(reference string) Option {
+	return func(o *options) { o.reference = &reference }
+}
+
+// WithMaxAttempts sets how many times the job is tried before it is dead-lettered
+func WithMaxAttempts(attempts int) Option {
+	return func(o *options) { o.maxAttempts = attempts }
+}
+
+// Enqueue adds a job to the queue using db. Pass the transaction of the change
+// that requires the job so that it is only queued if the change commits. It
+// returns the job ID, or 0 when a unique job with the same key already exists.
+func Enqueue(ctx context.Context, db database.DBTX, args Args, opts ...Option) (int64, error) {
+	o := options{runAt: time.Now(), maxAttempts: defaultMaxAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO public."Job" (
+			"Kind",
+			"Payload",
+			"Priority",
+			"RunAt",
+			"MaxAttempts",
+			"UniqueKey",
+			"Reference"
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT ("UniqueKey") WHERE "UniqueKey" IS NOT NULL AND "Status" IN ('queued', 'running') DO NOTHING
+		RETURNING "JobId"
+	`
+
+	var jobID int64
+	err = db.QueryRow(ctx, query, args.Kind(), payload, o.priority, o.runAt, o.maxAttempts, o.uniqueKey, o.reference).Scan(&jobID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Debug("unique job already queued", "kind", args.Kind(), "unique_key", *o.uniqueKey)
+			return 0, nil
+		}
+		logging.FromContext(ctx).Error("error while enqueuing job", "kind", args.Kind(), "error", err)
+		return 0, err
+	}
+
+	return jobID, nil
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// Args is the typed payload of a job. Kind identifies the handler that runs it
+// and must be stable, as it is stored with queued jobs.
+type Args interface {
+	Kind() string
+}
+
+// Job is a unit of background work stored in the queue
+type Job struct {
+	JobID       int64           `db:"JobId" json:"job_id"`
+	Kind        string          `db:"Kind" json:"kind"`
+	Payload     json.RawMessage `db:"Payload" json:"payload"`
+	Priority    int             `db:"Priority" json:"priority"`
+	RunAt       time.Time       `db:"RunAt" json:"run_at"`
+	Status      string          `db:"Status" json:"status"`
+	Attempts    int             `db:"Attempts" json:"attempts"`
+	MaxAttempts int             `db:"MaxAttempts" json:"max_attempts"`
+	LastError   *string         `db:"LastError" json:"last_error,omitempty"`
+	UniqueKey   *string         `db:"UniqueKey" json:"unique_key,omitempty"`
+	Reference   *string         `db:"Reference" json:"reference,omitempty"`
+	LockedAt    *time.Time      `db:"LockedAt" json:"locked_at,omitempty"`
+	LockedBy    *string         `db:"LockedBy" json:"locked_by,omitempty"`
+	CreatedAt   time.Time       `db:"CreatedAt" json:"created_at"`
+	UpdatedAt   *time.Time      `db:"UpdatedAt" json:"updated_at,omitempty"`
+	FinishedAt  *time.Time      `db:"FinishedAt" json:"finished_at,omitempty"`
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the job is dead-lettered right away instead of retried
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
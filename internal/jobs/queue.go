@@ -0,0 +1,222 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+)
+
+const jobColumns = `
+			"JobId",
+			"Kind",
+			"Payload",
+			"Priority",
+			"RunAt",
+			"Status",
+			"Attempts",
+			"MaxAttempts",
+			"LastError",
+			"UniqueKey",
+			"Reference",
+			"LockedAt",
+			"LockedBy",
+			"CreatedAt",
+			"UpdatedAt",
+			"FinishedAt"`
+
+// claim marks up to limit due jobs of the given kinds as running by workerID and
+// returns them. SKIP LOCKED lets any number of workers poll the same queue.
+func claim(ctx context.Context, db database.DBTX, kinds []string, limit int, workerID string) ([]Job, error) {
+	query := `
+		UPDATE public."Job"
+		SET "Status" = 'running',
+			"Attempts" = "Attempts" + 1,
+			"LockedAt" = NOW(),
+			"LockedBy" = $3,
+			"UpdatedAt" = NOW()
+		WHERE "JobId" IN (
+			SELECT "JobId"
+			FROM public."Job"
+			WHERE "Status" = 'queued'
+			  AND "RunAt" <= NOW()
+			  AND "Kind" = ANY($1)
+			ORDER BY "Priority" DESC, "RunAt", "JobId"
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING` + jobColumns
+
+	rows, err := db.Query(ctx, query, kinds, limit, workerID)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in claim", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []Job
+	for rows.Next() {
+		var job Job
+		err := rows.Scan(
+			&job.JobID,
+			&job.Kind,
+			&job.Payload,
+			&job.Priority,
+			&job.RunAt,
+			&job.Status,
+			&job.Attempts,
+			&job.MaxAttempts,
+			&job.LastError,
+			&job.UniqueKey,
+			&job.Reference,
+			&job.LockedAt,
+			&job.LockedBy,
+			&job.CreatedAt,
+			&job.UpdatedAt,
+			&job.FinishedAt,
+		)
+		if err != nil {
+			logging.FromContext(ctx).Error("error scanning job row", "error", err)
+			return nil, err
+		}
+		claimed = append(claimed, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("error iterating rows", "error", err)
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+// errLeaseLost is returned when recording the outcome of a job whose claim has
+// ended, because it was rescued and possibly claimed again meanwhile
+var errLeaseLost = errors.New("job lease lost")
+
+// complete marks the job succeeded, if it is still held by the claim that returned it
+func complete(ctx context.Context, db database.DBTX, job *Job) error {
+	tag, err := db.Exec(ctx, `
+		UPDATE public."Job"
+		SET "Status" = 'succeeded',
+			"LastError" = NULL,
+			"LockedAt" = NULL,
+			"LockedBy" = NULL,
+			"FinishedAt" = NOW(),
+			"UpdatedAt" = NOW()
+		WHERE "JobId" = $1
+		  AND "Status" = 'running'
+		  AND "LockedBy" = $2
+		  AND "LockedAt" = $3
+	`, job.JobID, job.LockedBy, job.LockedAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while completing job", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errLeaseLost
+	}
+	return nil
+}
+
+// fail queues the job again at runAt, or dead-letters it when dead is set, if it
+// is still held by the claim that returned it
+func fail(ctx context.Context, db database.DBTX, job *Job, lastError string, runAt time.Time, dead bool) error {
+	tag, err := db.Exec(ctx, `
+		UPDATE public."Job"
+		SET "Status" = CASE WHEN $4 THEN 'dead' ELSE 'queued' END,
+			"LastError" = $2,
+			"RunAt" = $3,
+			"LockedAt" = NULL,
+			"LockedBy" = NULL,
+			"FinishedAt" = CASE WHEN $4 THEN NOW() ELSE NULL END,
+			"UpdatedAt" = NOW()
+		WHERE "JobId" = $1
+		  AND "Status" = 'running'
+		  AND "LockedBy" = $5
+		  AND "LockedAt" = $6
+	`, job.JobID, lastError, runAt, dead, job.LockedBy, job.LockedAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while failing job", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errLeaseLost
+	}
+	return nil
+}
+
+// rescue requeues jobs that have been running for longer than after, which
+// happens when a worker dies mid-job. Jobs out of attempts are dead-lettered.
+func rescue(ctx context.Context, db database.DBTX, after time.Duration) (int64, error) {
+	tag, err := db.Exec(ctx, `
+		UPDATE public."Job"
+		SET "Status" = CASE WHEN "Attempts" >= "MaxAttempts" THEN 'dead' ELSE 'queued' END,
+			"LastError" = 'worker stopped before the job finished',
+			"LockedAt" = NULL,
+			"LockedBy" = NULL,
+			"FinishedAt" = CASE WHEN "Attempts" >= "MaxAttempts" THEN NOW() ELSE NULL END,
+			"UpdatedAt" = NOW()
+		WHERE "Status" = 'running'
+		  AND "LockedAt" < NOW() - $1::INTERVAL
+	`, after)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while rescuing stuck jobs", "error", err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteFinished deletes up to limit jobs that succeeded or were dead-lettered
+// before before, or only counts them in dry run mode
+func DeleteFinished(ctx context.Context, db database.DBTX, before time.Time, limit int, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := db.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM public."Job"
+			WHERE "Status" IN ('succeeded', 'dead')
+			  AND "FinishedAt" < $1
+		`, before).Scan(&count)
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in DeleteFinished (count)", "error", err)
+		}
+		return count, err
+	}
+
+	query := `
+		DELETE FROM public."Job"
+		WHERE "JobId" IN (
+			SELECT "JobId"
+			FROM public."Job"
+			WHERE "Status" IN ('succeeded', 'dead')
+			  AND "FinishedAt" < $1
+			LIMIT $2
+		)
+	`
+
+	tag, err := db.Exec(ctx, query, before, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting finished jobs", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteByReference deletes every job about the referenced records, whatever its
+// status. A job running meanwhile finishes, but leaves no row behind.
+func DeleteByReference(ctx context.Context, db database.DBTX, references []string) (int64, error) {
+	tag, err := db.Exec(ctx, `
+		DELETE FROM public."Job"
+		WHERE "Reference" = ANY($1)
+	`, references)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting jobs by reference", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// handlerFunc runs a job from its stored payload
+type handlerFunc func(ctx context.Context, job *Job) error
+
+// Registry maps job kinds to their handlers
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]handlerFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: map[string]handlerFunc{}}
+}
+
+// Register adds the handler for jobs whose arguments are of type T. The payload
+// is decoded into T before the handler runs, a payload that does not decode
+// dead-letters the job.
+func Register[T Args](r *Registry, handler func(ctx context.Context, args T) error) {
+	var zero T
+	kind := zero.Kind()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[kind]; exists {
+		panic(fmt.Sprintf("jobs: handler for kind %q registered twice", kind))
+	}
+
+	r.handlers[kind] = func(ctx context.Context, job *Job) error {
+		var args T
+		if err := json.Unmarshal(job.Payload, &args); err != nil {
+			return Permanent(fmt.Errorf("decoding %s payload: %w", kind, err))
+		}
+		return handler(ctx, args)
+	}
+}
+
+func (r *Registry) handler(kind string) (handlerFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[kind]
+	return handler, ok
+}
+
+// Kinds returns the registered job kinds
+func (r *Registry) Kinds() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	kinds := make([]string, 0, len(r.handlers))
+	for kind := range r.handlers {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
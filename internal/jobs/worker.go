@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/metrics"
+	"metalcore-api/internal/retry"
+	"metalcore-api/internal/tracing"
+)
+
+// WorkerConfig controls job polling, concurrency, retries and shutdown
+type WorkerConfig struct {
+	Concurrency     int
+	PollInterval    time.Duration
+	Timeout         time.Duration
+	RescueAfter     time.Duration
+	BaseBackoff     time.Duration
+	MaxBackoff      time.Duration
+	ShutdownTimeout time.Duration
+}
+
+// WorkerConfigFromEnv reads the JOBS_* variables
+func WorkerConfigFromEnv() WorkerConfig {
+	return WorkerConfig{
+		Concurrency:     config.GetEnvInt("JOBS_CONCURRENCY", 10),
+		PollInterval:    config.GetEnvDuration("JOBS_POLL_INTERVAL", time.Second),
+		Timeout:         config.GetEnvDuration("JOBS_TIMEOUT", 5*time.Minute),
+		RescueAfter:     config.GetEnvDuration("JOBS_RESCUE_AFTER", 30*time.Minute),
+		BaseBackoff:     config.GetEnvDuration("JOBS_BASE_BACKOFF", 5*time.Second),
+		MaxBackoff:      config.GetEnvDuration("JOBS_MAX_BACKOFF", time.Hour),
+		ShutdownTimeout: config.GetEnvDuration("JOBS_SHUTDOWN_TIMEOUT", 30*time.Second),
+	}
+}
+
+// Worker runs queued jobs with the handlers of its registry
+type Worker struct {
+	db       database.DBTX
+	registry *Registry
+	config   WorkerConfig
+	id       string
+}
+
+func NewWorker(db database.DBTX, registry *Registry, config WorkerConfig) *Worker {
+	hostname, _ := os.Hostname()
+	return &Worker{
+		db:       db,
+		registry: registry,
+		config:   config,
+		id:       fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// Run polls for jobs until ctx is cancelled. It then stops claiming jobs and
+// waits up to ShutdownTimeout for running jobs before cancelling them; cancelled
+// jobs are queued again.
+func (w *Worker) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx).With("worker_id", w.id)
+	kinds := w.registry.Kinds()
+	logger.Info("job worker started", "kinds", kinds, "concurrency", w.config.Concurrency)
+
+	// Running jobs outlive ctx so that they can finish during shutdown
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	slots := make(chan struct{}, w.config.Concurrency)
+	finished := make(chan struct{}, w.config.Concurrency)
+	var running sync.WaitGroup
+
+	poll := time.NewTicker(w.config.PollInterval)
+	defer poll.Stop()
+	rescueTicker := time.NewTicker(max(w.config.RescueAfter/2, time.Second))
+	defer rescueTicker.Stop()
+
+	for {
+		if free := w.config.Concurrency - len(slots); free > 0 && len(kinds) > 0 {
+			claimed, err := claim(ctx, w.db, kinds, free, w.id)
+			if err != nil && ctx.Err() == nil {
+				logger.Error("error while claiming jobs", "error", err)
+			}
+
+			for i := range claimed {
+				job := claimed[i]
+				slots <- struct{}{}
+				running.Add(1)
+				go func() {
+					defer running.Done()
+					w.work(jobCtx, &job)
+					<-slots
+					select {
+					case finished <- struct{}{}:
+					default:
+					}
+				}()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			w.shutdown(logger, &running, cancelJobs)
+			return
+		case <-rescueTicker.C:
+			if rescued, err := rescue(ctx, w.db, w.config.RescueAfter); err == nil && rescued > 0 {
+				logger.Warn("requeued jobs of unresponsive workers", "count", rescued)
+			}
+		case <-poll.C:
+		case <-finished:
+		}
+	}
+}
+
+func (w *Worker) shutdown(logger *slog.Logger, running *sync.WaitGroup, cancelJobs context.CancelFunc) {
+	logger.Info("job worker stopping, waiting for running jobs")
+
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(w.config.ShutdownTimeout):
+		logger.Info("shutdown timeout reached, cancelling running jobs")
+		cancelJobs()
+		<-done
+	}
+
+	logger.Info("job worker stopped")
+}
+
+// work runs a claimed job and records its outcome
+func (w *Worker) work(ctx context.Context, job *Job) {
+	ctx = logging.With(ctx, "job_id", job.JobID, "job_kind", job.Kind, "attempt", job.Attempts)
+	ctx, span := tracing.Start(ctx, "jobs.Worker.work")
+	defer span.End()
+
+	start := time.Now()
+	err := w.execute(ctx, job)
+	metrics.JobDuration.WithLabelValues(job.Kind).Observe(time.Since(start).Seconds())
+
+	// The outcome is recorded even when the job was cancelled by shutdown
+	recordCtx := context.WithoutCancel(ctx)
+
+	if err == nil {
+		metrics.JobsProcessed.WithLabelValues(job.Kind, "succeeded").Inc()
+		if err := complete(recordCtx, w.db, job); errors.Is(err, errLeaseLost) {
+			logging.FromContext(ctx).Warn("job lease lost, success not recorded")
+		}
+		return
+	}
+
+	tracing.RecordError(span, err)
+	dead := isPermanent(err) || job.Attempts >= job.MaxAttempts
+	runAt := time.Now().Add(retry.Backoff(w.config.BaseBackoff, w.config.MaxBackoff, job.Attempts))
+
+	if dead {
+		metrics.JobsProcessed.WithLabelValues(job.Kind, "dead").Inc()
+		logging.FromContext(ctx).Error("job failed permanently", "error", err)
+	} else {
+		metrics.JobsProcessed.WithLabelValues(job.Kind, "retried").Inc()
+		logging.FromContext(ctx).Warn("job failed, retrying", "run_at", runAt, "error", err)
+	}
+
+	// A job rescued while it ran belongs to its next run, which records its own outcome
+	if err := fail(recordCtx, w.db, job, err.Error(), runAt, dead); errors.Is(err, errLeaseLost) {
+		logging.FromContext(ctx).Warn("job lease lost, failure not recorded")
+	}
+}
+
+// execute runs the job's handler with the job timeout, turning panics into errors
+func (w *Worker) execute(ctx context.Context, job *Job) (err error) {
+	handler, ok := w.registry.handler(job.Kind)
+	if !ok {
+		return Permanent(errors.New("no handler registered for job kind " + job.Kind))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.config.Timeout)
+	defer cancel()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+
+	return handler(ctx, job)
+}
@@ -0,0 +1,20 @@
+package mailer
+
+import "context"
+
+// SendEmailArgs is the job that delivers a message in the background, so that a
+// slow or failing mail server neither blocks nor fails the request that sends it
+type SendEmailArgs struct {
+	Message Message `json:"message"`
+}
+
+func (SendEmailArgs) Kind() string {
+	return "send_email"
+}
+
+// SendEmailJob returns the handler of SendEmailArgs jobs
+func SendEmailJob(m Mailer) func(ctx context.Context, args SendEmailArgs) error {
+	return func(ctx context.Context, args SendEmailArgs) error {
+		return m.Send(ctx, args.Message)
+	}
+}
@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/logging"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv returns the mailer named by MAIL_DRIVER, "smtp" or "log". The driver
+// must be set, so that a misconfigured deployment does not silently stop sending email.
+func NewFromEnv() (Mailer, error) {
+	switch driver := config.GetEnv("MAIL_DRIVER", ""); driver {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     config.GetEnv("SMTP_HOST", "localhost"),
+			Port:     config.GetEnv("SMTP_PORT", "587"),
+			Username: config.GetEnv("SMTP_USERNAME", ""),
+			Password: config.GetEnv("SMTP_PASSWORD", ""),
+			From:     config.GetEnv("MAIL_FROM", "no-reply@metalcore.local"),
+		}, nil
+	case "log":
+		return &LogMailer{LogBodies: config.GetEnvBool("MAIL_LOG_BODIES", false)}, nil
+	case "":
+		return nil, errors.New("MAIL_DRIVER is required, set it to smtp or log")
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", driver)
+	}
+}
+
+// SMTPMailer sends messages through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", m.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(msg.Body)
+
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{msg.To}, []byte(body.String()))
+}
+
+// LogMailer writes messages to the log instead of sending them, for local development.
+// Bodies carry confirmation and invitation links, they are only logged with LogBodies.
+type LogMailer struct {
+	LogBodies bool
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	body := "[redacted]"
+	if m.LogBodies {
+		body = msg.Body
+	}
+	logging.FromContext(ctx).Info("mail not sent, logging instead", "subject", msg.Subject, "body", body)
+	return nil
+}
@@ -0,0 +1,226 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "metalcore"
+
+// Registry holds every metric exposed by the application
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of HTTP requests by route template, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "status"},
+	)
+
+	HTTPRequestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "Number of HTTP requests currently being served.",
+		},
+	)
+)
+
+// Business counters
+var (
+	UsersCreated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "users",
+			Name:      "created_total",
+			Help:      "Number of users created.",
+		},
+	)
+
+	UsersDeleted = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "users",
+			Name:      "deleted_total",
+			Help:      "Number of users soft deleted.",
+		},
+	)
+
+	UsersRestored = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "users",
+			Name:      "restored_total",
+			Help:      "Number of soft deleted users restored by logging in during the grace period.",
+		},
+	)
+
+	UsersErased = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "users",
+			Name:      "erased_total",
+			Help:      "Number of users anonymized on an erasure request.",
+		},
+	)
+
+	LoginSuccesses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "login_successes_total",
+			Help:      "Number of successful logins.",
+		},
+	)
+
+	LoginFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "login_failures_total",
+			Help:      "Number of failed logins by reason.",
+		},
+		[]string{"reason"},
+	)
+
+	PasswordRehashes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "password_rehashes_total",
+			Help:      "Number of password hashes upgraded on login by result.",
+		},
+		[]string{"result"},
+	)
+
+	PasswordChanges = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "users",
+			Name:      "password_changes_total",
+			Help:      "Number of passwords changed by their owner.",
+		},
+	)
+
+	EmailChanges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "users",
+			Name:      "email_changes_total",
+			Help:      "Number of email changes by stage (requested, confirmed).",
+		},
+		[]string{"stage"},
+	)
+)
+
+// Background processing
+var (
+	OutboxEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "outbox",
+			Name:      "events_total",
+			Help:      "Number of outbox delivery attempts by result (dispatched, retried, dead).",
+		},
+		[]string{"result"},
+	)
+
+	WebhookDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "webhooks",
+			Name:      "deliveries_total",
+			Help:      "Number of webhook delivery attempts by result (succeeded, retried, dead).",
+		},
+		[]string{"result"},
+	)
+
+	JobsProcessed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "jobs",
+			Name:      "processed_total",
+			Help:      "Number of job runs by kind and result (succeeded, retried, dead).",
+		},
+		[]string{"kind", "result"},
+	)
+
+	JobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "jobs",
+			Name:      "duration_seconds",
+			Help:      "Duration of job runs by kind.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"kind"},
+	)
+
+	SchedulerLeader = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "leader",
+			Help:      "Whether this instance holds the scheduler lock (1) or not (0).",
+		},
+	)
+
+	ScheduledRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "runs_total",
+			Help:      "Number of scheduled jobs by name and result (enqueued, skipped, failed).",
+		},
+		[]string{"name", "result"},
+	)
+
+	RetentionRows = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "retention",
+			Name:      "rows_total",
+			Help:      "Number of rows affected by retention by target, action and dry_run.",
+		},
+		[]string{"target", "action", "dry_run"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		UsersCreated,
+		UsersDeleted,
+		UsersRestored,
+		UsersErased,
+		LoginSuccesses,
+		LoginFailures,
+		PasswordRehashes,
+		PasswordChanges,
+		EmailChanges,
+		OutboxEvents,
+		WebhookDeliveries,
+		JobsProcessed,
+		JobDuration,
+		SchedulerLeader,
+		ScheduledRuns,
+		RetentionRows,
+	)
+}
+
+// Handler serves the registry in the Prometheus text exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
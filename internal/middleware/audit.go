@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"metalcore-api/internal/modules/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditClient stores the client IP address and user agent in the request context
+// so that audit entries written while handling the request can attribute them
+func AuditClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := audit.WithClient(c.Request.Context(), audit.Client{
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/token"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const principalKey = "principal"
+
+// Principal is the authenticated caller of a request, either a user with an access
+// token or an internal service with a client certificate
+type Principal struct {
+	UserID    int
+	SessionID int64
+	Role      string
+	TenantID  int64  // Organization the access token is scoped to, zero when unscoped
+	Service   string // Common name of the service's client certificate, empty for users
+}
+
+// SessionValidator checks that the session behind an access token is still active
+type SessionValidator interface {
+	IsActive(ctx context.Context, sessionID int64, userID int) (bool, error)
+}
+
+// RequireAuth validates the bearer access token and its session, and stores the
+// resulting Principal on the gin context. Requests without a token are
+// authenticated as the service of their client certificate, if it is one of services.
+func RequireAuth(tokens *token.Manager, sessions SessionValidator, services ServicePrincipals) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			if principal, ok := services.principal(c.Request); ok {
+				c.Set(principalKey, principal)
+				ctx := logging.With(c.Request.Context(), "service", principal.Service)
+				c.Request = c.Request.WithContext(audit.WithService(ctx, principal.Service))
+				c.Next()
+				return
+			}
+		}
+
+		scheme, raw, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || raw == "" {
+			abortUnauthorized(c, "Missing bearer token")
+			return
+		}
+
+		claims, err := tokens.Parse(raw)
+		if err != nil {
+			abortUnauthorized(c, "Invalid or expired token")
+			return
+		}
+
+		active, err := sessions.IsActive(c.Request.Context(), claims.SessionID, claims.UserID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+			return
+		}
+		if !active {
+			abortUnauthorized(c, "Session has been revoked or has expired")
+			return
+		}
+
+		c.Set(principalKey, &Principal{
+			UserID:    claims.UserID,
+			SessionID: claims.SessionID,
+			Role:      claims.Role,
+			TenantID:  claims.TenantID,
+		})
+		ctx := logging.With(c.Request.Context(), "user_id", claims.UserID)
+		c.Request = c.Request.WithContext(audit.WithActor(ctx, claims.UserID))
+		c.Next()
+	}
+}
+
+// RequireRole rejects authenticated callers whose role is not one of roles.
+// It must run after RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		if !slices.Contains(roles, principal.Role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, common.ErrorResponse{
+				Status:  http.StatusForbidden,
+				Error:   "Forbidden",
+				Message: "You do not have permission to perform this action",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentPrincipal returns the authenticated caller set by RequireAuth
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+func abortUnauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, common.ErrorResponse{
+		Status:  http.StatusUnauthorized,
+		Error:   "Unauthorized",
+		Message: message,
+	})
+}
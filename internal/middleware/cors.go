@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/config"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	AllowedOrigins   []string      // Exact origins, "*" for any, or "https://*.example.com" for subdomains
+	AllowCredentials bool          // Allow cookies and the Authorization header, "*" then echoes the origin
+	AllowedMethods   []string      // Methods allowed in preflight requests
+	AllowedHeaders   []string      // Request headers allowed in preflight requests
+	ExposedHeaders   []string      // Response headers readable by the browser
+	MaxAge           time.Duration // How long browsers may cache a preflight response
+}
+
+// CORSConfigFromEnv reads CORS_ALLOWED_ORIGINS, CORS_ALLOW_CREDENTIALS,
+// CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS, CORS_EXPOSED_HEADERS and CORS_MAX_AGE.
+// Without allowed origins cross-origin requests get no CORS headers.
+func CORSConfigFromEnv() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins:   config.GetEnvList("CORS_ALLOWED_ORIGINS", ""),
+		AllowCredentials: config.GetEnvBool("CORS_ALLOW_CREDENTIALS", false),
+		AllowedMethods:   config.GetEnvList("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE"),
+		AllowedHeaders: config.GetEnvList("CORS_ALLOWED_HEADERS", strings.Join([]string{
+			"Authorization", "Content-Type", "Accept-Language", IdempotencyKeyHeader,
+			"If-Match", "If-None-Match", RequestIDHeader, "X-Organization",
+		}, ",")),
+		ExposedHeaders: config.GetEnvList("CORS_EXPOSED_HEADERS", strings.Join([]string{
+			"ETag", "Content-Disposition", "Retry-After", RequestIDHeader, IdempotentReplayedHeader,
+		}, ",")),
+		MaxAge: config.GetEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+	}
+}
+
+// CORS answers preflight requests and adds CORS headers to requests from allowed
+// origins. Preflights from other origins are rejected with 403, their other
+// requests pass without CORS headers, so browsers do not expose the responses.
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+	allowMethods := strings.Join(cfg.AllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || len(cfg.AllowedOrigins) == 0 {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !anyOrigin && !originAllowed(cfg.AllowedOrigins, origin) {
+			if preflight {
+				c.AbortWithStatusJSON(http.StatusForbidden, common.ErrorResponse{
+					Status:  http.StatusForbidden,
+					Error:   "Forbidden",
+					Message: "Origin " + origin + " is not allowed",
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if anyOrigin && !cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				c.Header("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Methods", allowMethods)
+		c.Header("Access-Control-Allow-Headers", allowHeaders)
+		if cfg.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// originAllowed matches origin against exact origins and "scheme://*.domain" patterns,
+// which allow any subdomain of domain but not domain itself
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "/"))
+		if pattern == origin {
+			return true
+		}
+		scheme, domain, found := strings.Cut(pattern, "://*.")
+		if !found {
+			continue
+		}
+		host, ok := strings.CutPrefix(origin, scheme+"://")
+		if !ok {
+			continue
+		}
+		subdomain, ok := strings.CutSuffix(host, "."+domain)
+		if ok && isSubdomain(subdomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubdomain reports whether name is made of DNS labels only, so that no other
+// part of an origin can end in an allowed domain
+func isSubdomain(name string) bool {
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return false
+		}
+		for _, char := range label {
+			if (char < 'a' || char > 'z') && (char < '0' || char > '9') && char != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
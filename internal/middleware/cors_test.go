@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://*.tenants.example.com", "http://localhost:3000/"}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://App.Example.com", true},
+		{"http://localhost:3000", true},
+		{"https://acme.tenants.example.com", true},
+		{"https://a.b.tenants.example.com", true},
+
+		// The apex of a wildcard is not one of its subdomains
+		{"https://tenants.example.com", false},
+		// Schemes must match
+		{"http://app.example.com", false},
+		{"http://acme.tenants.example.com", false},
+		// Ports are part of the origin
+		{"https://app.example.com:8443", false},
+		{"http://localhost:3001", false},
+		// Suffix and prefix tricks
+		{"https://evil-example.com", false},
+		{"https://app.example.com.evil.com", false},
+		{"https://evilapp.example.com", false},
+		{"https://eviltenants.example.com", false},
+		{"https://acme.tenants.example.com.evil.com", false},
+		{"https://evil.com/.tenants.example.com", false},
+		{"https://evil.com#.tenants.example.com", false},
+		{"https://evil.com?.tenants.example.com", false},
+		{"https://user@acme.tenants.example.com", false},
+		{"https://.tenants.example.com", false},
+		{"null", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := originAllowed(allowed, tt.origin); got != tt.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func corsRouter(cfg CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORS(cfg))
+	r.GET("/things", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.OPTIONS("/things", func(c *gin.Context) { c.Status(http.StatusTeapot) })
+	return r
+}
+
+func corsRequest(r *gin.Engine, method, origin string, preflight bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/things", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if preflight {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := corsRouter(CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	w := corsRequest(r, http.MethodOptions, "https://app.example.com", true)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("allowed preflight = %d, want 204", w.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST",
+		"Access-Control-Allow-Headers": "Authorization, Content-Type",
+		"Access-Control-Max-Age":       "600",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if got := w.Header().Values("Vary"); len(got) != 3 {
+		t.Errorf("Vary = %v, want Origin and the preflight request headers", got)
+	}
+
+	w = corsRequest(r, http.MethodOptions, "https://evil-example.com", true)
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed preflight = %d with Allow-Origin %q, want 403 without it", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// OPTIONS without Access-Control-Request-Method is not a preflight
+	w = corsRequest(r, http.MethodOptions, "https://app.example.com", false)
+	if w.Code != http.StatusTeapot {
+		t.Fatalf("plain OPTIONS = %d, want it to reach the handler", w.Code)
+	}
+}
+
+func TestCORSSimpleRequests(t *testing.T) {
+	r := corsRouter(CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		ExposedHeaders: []string{"ETag", "X-Request-ID"},
+	})
+
+	w := corsRequest(r, http.MethodGet, "https://app.example.com", false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("allowed request = %d with Allow-Origin %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "ETag, X-Request-ID" {
+		t.Errorf("Access-Control-Expose-Headers = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q without AllowCredentials", got)
+	}
+
+	// Requests from other origins are served, but browsers will not expose the response
+	w = corsRequest(r, http.MethodGet, "https://evil.com", false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed request = %d with Allow-Origin %q, want 200 without it", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// Same-origin requests carry no Origin and get no CORS headers
+	w = corsRequest(r, http.MethodGet, "", false)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Vary") != "" {
+		t.Fatalf("request without Origin got CORS headers: %v", w.Header())
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials bool
+		wantOrigin  string
+	}{
+		{"without credentials", false, "*"},
+		{"with credentials", true, "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := corsRouter(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: tt.credentials})
+
+			w := corsRequest(r, http.MethodGet, "https://app.example.com", false)
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			wantCredentials := ""
+			if tt.credentials {
+				wantCredentials = "true"
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, wantCredentials)
+			}
+		})
+	}
+}
+
+func TestCORSDisabled(t *testing.T) {
+	r := corsRouter(CORSConfig{})
+
+	w := corsRequest(r, http.MethodOptions, "https://app.example.com", true)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || w.Code == http.StatusNoContent {
+		t.Fatalf("preflight without allowed origins = %d with Allow-Origin %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/idempotency"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/tenant"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// Response headers that belong to the request they were sent with and are not replayed
+var unreplayedHeaders = []string{RequestIDHeader, "Date", "Content-Length"}
+
+// IdempotencyConfig controls how long keys are kept and locked
+type IdempotencyConfig struct {
+	TTL         time.Duration // How long a completed response is replayed
+	LockTimeout time.Duration // After this a request that never completed no longer blocks retries
+}
+
+// IdempotencyConfigFromEnv reads IDEMPOTENCY_TTL and IDEMPOTENCY_LOCK_TIMEOUT
+func IdempotencyConfigFromEnv() IdempotencyConfig {
+	return IdempotencyConfig{
+		TTL:         config.GetEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		LockTimeout: config.GetEnvDuration("IDEMPOTENCY_LOCK_TIMEOUT", time.Minute),
+	}
+}
+
+// IdempotencyStore keeps idempotency keys and the responses of their requests,
+// see idempotency.Store
+type IdempotencyStore interface {
+	Acquire(ctx context.Context, key string, fingerprint []byte, lockTimeout, ttl time.Duration) (*idempotency.Response, error)
+	Complete(ctx context.Context, key string, fingerprint []byte, response idempotency.Response) error
+	Release(ctx context.Context, key string, fingerprint []byte) error
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to retry.
+// The first request with a key is processed and its response stored; retries of
+// the same request get the stored response with Idempotent-Replayed: true. Reusing
+// the key for a different request (method, path, body, caller or organization) is
+// rejected with 422, and retries arriving while the first request is still
+// processed with 409. Server errors are not stored, so the request can be retried
+// with the key, and neither are responses marked Cache-Control: no-store or setting
+// cookies, which carry credentials.
+//
+// It is registered on the routes creating resources, after authentication and
+// ValidateRequest, so that the caller and the tenant are known.
+func Idempotency(store IdempotencyStore, cfg IdempotencyConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, common.ErrorResponse{
+				Status:  http.StatusBadRequest,
+				Error:   "Invalid idempotency key",
+				Message: "Idempotency-Key must be at most " + strconv.Itoa(maxIdempotencyKeyLength) + " characters",
+			})
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, common.ErrorResponse{
+					Status: http.StatusBadRequest,
+					Error:  "Invalid request body",
+				})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		ctx := c.Request.Context()
+		fingerprint := requestFingerprint(c, body)
+		stored, err := store.Acquire(ctx, key, fingerprint, cfg.LockTimeout, cfg.TTL)
+		switch {
+		case errors.Is(err, idempotency.ErrMismatch):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, common.ErrorResponse{
+				Status:  http.StatusUnprocessableEntity,
+				Error:   "Idempotency key reused",
+				Message: "This Idempotency-Key was already used for a different request",
+			})
+			return
+		case errors.Is(err, idempotency.ErrInFlight):
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusConflict, common.ErrorResponse{
+				Status:  http.StatusConflict,
+				Error:   "Request in progress",
+				Message: "A request with this Idempotency-Key is still being processed, retry later",
+			})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+			return
+		case stored != nil:
+			replay(c, stored)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// The outcome is stored even when the client has gone away, it will retry
+		ctx = context.WithoutCancel(ctx)
+		if recorder.Status() >= http.StatusInternalServerError || carriesCredentials(recorder.Header()) {
+			if err := store.Release(ctx, key, fingerprint); err != nil {
+				logging.FromContext(ctx).Error("idempotency key stays locked until it times out", "error", err)
+			}
+			return
+		}
+
+		headers := recorder.Header().Clone()
+		for _, name := range unreplayedHeaders {
+			headers.Del(name)
+		}
+		response := idempotency.Response{Status: recorder.Status(), Headers: headers, Body: recorder.body.Bytes()}
+		switch err := store.Complete(ctx, key, fingerprint, response); {
+		case errors.Is(err, idempotency.ErrClaimLost):
+			logging.FromContext(ctx).Warn("idempotency key claim timed out, response not stored")
+		case err != nil:
+			logging.FromContext(ctx).Error("idempotent response was not stored", "error", err)
+		}
+	}
+}
+
+// requestFingerprint identifies a request by method, path, query, caller, tenant and
+// body. Authenticated callers are identified by their principal, so a retry with a
+// refreshed access token is still the same request.
+func requestFingerprint(c *gin.Context, body []byte) []byte {
+	caller := c.GetHeader("Authorization")
+	if principal, ok := CurrentPrincipal(c); ok {
+		caller = "user:" + strconv.Itoa(principal.UserID) + ",service:" + principal.Service
+	}
+	var tenantID int64
+	if t, ok := tenant.FromContext(c.Request.Context()); ok {
+		tenantID = t.ID
+	}
+
+	h := sha256.New()
+	for _, part := range []string{c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, caller, strconv.FormatInt(tenantID, 10)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// carriesCredentials reports whether a response must not be stored for replay
+func carriesCredentials(header http.Header) bool {
+	return header.Get("Set-Cookie") != "" || strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-store")
+}
+
+func replay(c *gin.Context, stored *idempotency.Response) {
+	for name, values := range stored.Headers {
+		c.Writer.Header()[name] = values
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Status(stored.Status)
+	c.Writer.Write(stored.Body)
+	c.Abort()
+}
+
+// responseRecorder keeps a copy of the response body written through it
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"metalcore-api/internal/idempotency"
+	"metalcore-api/internal/tenant"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryStore is an in-memory IdempotencyStore following the semantics of idempotency.Store
+type memoryStore struct {
+	mu   sync.Mutex
+	keys map[string]*memoryKey
+}
+
+type memoryKey struct {
+	fingerprint []byte
+	response    *idempotency.Response
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{keys: map[string]*memoryKey{}}
+}
+
+func (s *memoryStore) Acquire(ctx context.Context, key string, fingerprint []byte, lockTimeout, ttl time.Duration) (*idempotency.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.keys[key]
+	switch {
+	case !ok:
+		s.keys[key] = &memoryKey{fingerprint: fingerprint}
+		return nil, nil
+	case !bytes.Equal(stored.fingerprint, fingerprint):
+		return nil, idempotency.ErrMismatch
+	case stored.response == nil:
+		return nil, idempotency.ErrInFlight
+	}
+	return stored.response, nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, key string, fingerprint []byte, response idempotency.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.keys[key]
+	if !ok || stored.response != nil || !bytes.Equal(stored.fingerprint, fingerprint) {
+		return idempotency.ErrClaimLost
+	}
+	stored.response = &response
+	return nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, key string, fingerprint []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.keys[key]; ok && stored.response == nil && bytes.Equal(stored.fingerprint, fingerprint) {
+		delete(s.keys, key)
+	}
+	return nil
+}
+
+func (s *memoryStore) stored(key string) (*memoryKey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.keys[key]
+	return stored, ok
+}
+
+// idempotencyRouter counts the requests reaching its handlers. The caller is taken
+// from X-User, as RequireAuth would, and the tenant from X-Tenant.
+func idempotencyRouter(store IdempotencyStore, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID(), func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set(principalKey, &Principal{UserID: len(user)})
+		}
+		if slug := c.GetHeader("X-Tenant"); slug != "" {
+			c.Request = c.Request.WithContext(tenant.WithTenant(c.Request.Context(), &tenant.Tenant{ID: int64(len(slug)), Slug: slug}))
+		}
+	}, Idempotency(store, IdempotencyConfig{TTL: time.Hour, LockTimeout: time.Minute}))
+
+	r.POST("/things", func(c *gin.Context) {
+		*calls++
+		c.Header("Location", "/things/1")
+		c.JSON(http.StatusCreated, gin.H{"call": *calls})
+	})
+	r.POST("/fail", func(c *gin.Context) {
+		*calls++
+		c.Status(http.StatusInternalServerError)
+	})
+	r.POST("/login", func(c *gin.Context) {
+		*calls++
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{"access_token": "secret"})
+	})
+	r.POST("/cookie", func(c *gin.Context) {
+		*calls++
+		c.SetCookie("session", "secret", 60, "/", "", true, true)
+		c.Status(http.StatusOK)
+	})
+	r.PUT("/things", func(c *gin.Context) {
+		*calls++
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func idempotentRequest(r *gin.Engine, method, path, key, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	var calls int
+	r := idempotencyRouter(newMemoryStore(), &calls)
+
+	first := idempotentRequest(r, http.MethodPost, "/things", "key-1", `{"name":"a"}`)
+	retry := idempotentRequest(r, http.MethodPost, "/things", "key-1", `{"name":"a"}`)
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want once", calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry = %d %s, want the first response %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("Idempotent-Replayed = %q on the retry and %q on the first response", retry.Header().Get(IdempotentReplayedHeader), first.Header().Get(IdempotentReplayedHeader))
+	}
+	if retry.Header().Get("Location") != "/things/1" || retry.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Fatalf("retry headers = %v, want the stored headers", retry.Header())
+	}
+	// Headers of the request they were sent with are not replayed
+	if id := retry.Header().Get(RequestIDHeader); id == "" || id == first.Header().Get(RequestIDHeader) {
+		t.Fatalf("retry %s = %q, want its own, not %q", RequestIDHeader, id, first.Header().Get(RequestIDHeader))
+	}
+
+	other := idempotentRequest(r, http.MethodPost, "/things", "key-2", `{"name":"a"}`)
+	if calls != 2 || other.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("another key was replayed, handler ran %d times", calls)
+	}
+	idempotentRequest(r, http.MethodPost, "/things", "", `{"name":"a"}`)
+	if calls != 3 {
+		t.Fatalf("a request without a key was not processed, handler ran %d times", calls)
+	}
+}
+
+func TestIdempotencyRejectsDifferentRequests(t *testing.T) {
+	alice := []string{"X-User", "alice", "X-Tenant", "acme"}
+	tests := []struct {
+		name  string
+		first []string
+		path  string
+		body  string
+		retry []string
+	}{
+		{"body", nil, "/things", `{"name":"b"}`, nil},
+		{"query", nil, "/things?dry_run=true", `{"name":"a"}`, nil},
+		{"credentials of an anonymous request", nil, "/things", `{"name":"a"}`, []string{"Authorization", "Bearer other"}},
+		{"caller", alice, "/things", `{"name":"a"}`, []string{"X-User", "bob", "X-Tenant", "acme"}},
+		{"tenant", alice, "/things", `{"name":"a"}`, []string{"X-User", "alice", "X-Tenant", "globex"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			r := idempotencyRouter(newMemoryStore(), &calls)
+			idempotentRequest(r, http.MethodPost, "/things", "key", `{"name":"a"}`, tt.first...)
+
+			w := idempotentRequest(r, http.MethodPost, tt.path, "key", tt.body, tt.retry...)
+			if w.Code != http.StatusUnprocessableEntity || calls != 1 {
+				t.Fatalf("reuse for a different %s = %d, handler ran %d times, want 422", tt.name, w.Code, calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyFingerprintFollowsPrincipal(t *testing.T) {
+	var calls int
+	r := idempotencyRouter(newMemoryStore(), &calls)
+
+	idempotentRequest(r, http.MethodPost, "/things", "key", `{}`, "X-User", "alice", "Authorization", "Bearer first")
+	// The same caller retrying with a refreshed access token
+	w := idempotentRequest(r, http.MethodPost, "/things", "key", `{}`, "X-User", "alice", "Authorization", "Bearer refreshed")
+
+	if calls != 1 || w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("retry with a new token = %d, handler ran %d times, want a replay", w.Code, calls)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	var calls int
+	store := newMemoryStore()
+	r := idempotencyRouter(store, &calls)
+	// Make the first request look like it has not completed yet
+	idempotentRequest(r, http.MethodPost, "/things", "key", `{}`)
+	stored, _ := store.stored("key")
+	stored.response = nil
+
+	w := idempotentRequest(r, http.MethodPost, "/things", "key", `{}`)
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" || calls != 1 {
+		t.Fatalf("concurrent retry = %d with Retry-After %q, handler ran %d times, want 409", w.Code, w.Header().Get("Retry-After"), calls)
+	}
+}
+
+func TestIdempotencyDoesNotStore(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"server errors", "/fail"},
+		{"no-store responses", "/login"},
+		{"responses setting cookies", "/cookie"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			store := newMemoryStore()
+			r := idempotencyRouter(store, &calls)
+
+			idempotentRequest(r, http.MethodPost, tt.path, "key", `{}`)
+			if _, ok := store.stored("key"); ok {
+				t.Fatal("the response was stored, want the key released")
+			}
+			w := idempotentRequest(r, http.MethodPost, tt.path, "key", `{}`)
+			if calls != 2 || w.Header().Get(IdempotentReplayedHeader) != "" {
+				t.Fatalf("retry was replayed, handler ran %d times", calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyIgnoresOtherRequests(t *testing.T) {
+	var calls int
+	store := newMemoryStore()
+	r := idempotencyRouter(store, &calls)
+
+	idempotentRequest(r, http.MethodPut, "/things", "key", `{}`)
+	idempotentRequest(r, http.MethodPut, "/things", "key", `{}`)
+	if calls != 2 {
+		t.Fatalf("PUT with a key ran %d times, want every request processed", calls)
+	}
+
+	w := idempotentRequest(r, http.MethodPost, "/things", strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`)
+	if w.Code != http.StatusBadRequest || calls != 2 {
+		t.Fatalf("overlong key = %d, want 400", w.Code)
+	}
+}
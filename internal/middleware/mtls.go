@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"log/slog"
+	"metalcore-api/internal/config"
+	"net/http"
+	"strings"
+)
+
+// ServicePrincipals maps the subject common names of verified client certificates
+// to the roles of the internal services presenting them
+type ServicePrincipals map[string]string
+
+// ServicePrincipalsFromEnv reads MTLS_PRINCIPALS, comma separated "common name:role"
+// pairs such as "billing:admin,reports:user"
+func ServicePrincipalsFromEnv() ServicePrincipals {
+	principals := ServicePrincipals{}
+	for _, pair := range config.GetEnvList("MTLS_PRINCIPALS", "") {
+		name, role, found := strings.Cut(pair, ":")
+		name, role = strings.TrimSpace(name), strings.TrimSpace(role)
+		if !found || name == "" || role == "" {
+			slog.Warn("invalid MTLS_PRINCIPALS entry, expected common name:role", "entry", pair)
+			continue
+		}
+		principals[name] = role
+	}
+	return principals
+}
+
+// principal returns the service principal of the request's verified client
+// certificate. Certificates the TLS handshake did not verify are ignored.
+func (s ServicePrincipals) principal(r *http.Request) (*Principal, bool) {
+	if len(s) == 0 || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	role, ok := s[name]
+	if !ok {
+		return nil, false
+	}
+	return &Principal{Service: name, Role: role}, true
+}
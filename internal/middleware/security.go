@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"metalcore-api/internal/config"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityHeadersConfig controls the security headers added to every response.
+// Empty values leave their header out.
+type SecurityHeadersConfig struct {
+	HSTSMaxAge            time.Duration // Zero disables Strict-Transport-Security
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ContentSecurityPolicy string
+	FrameOptions          string // DENY or SAMEORIGIN
+}
+
+// SecurityHeadersConfigFromEnv reads SECURITY_HSTS_MAX_AGE,
+// SECURITY_HSTS_INCLUDE_SUBDOMAINS, SECURITY_HSTS_PRELOAD, SECURITY_CSP and
+// SECURITY_FRAME_OPTIONS. The CSP and frame options are disabled with "off".
+func SecurityHeadersConfigFromEnv() SecurityHeadersConfig {
+	cfg := SecurityHeadersConfig{
+		HSTSMaxAge:            config.GetEnvDuration("SECURITY_HSTS_MAX_AGE", 365*24*time.Hour),
+		HSTSIncludeSubdomains: config.GetEnvBool("SECURITY_HSTS_INCLUDE_SUBDOMAINS", true),
+		HSTSPreload:           config.GetEnvBool("SECURITY_HSTS_PRELOAD", false),
+		ContentSecurityPolicy: config.GetEnv("SECURITY_CSP", "default-src 'none'; frame-ancestors 'none'"),
+		FrameOptions:          config.GetEnv("SECURITY_FRAME_OPTIONS", "DENY"),
+	}
+	if cfg.ContentSecurityPolicy == "off" {
+		cfg.ContentSecurityPolicy = ""
+	}
+	if cfg.FrameOptions == "off" {
+		cfg.FrameOptions = ""
+	}
+	return cfg
+}
+
+// SecurityHeaders adds HSTS, Content-Security-Policy, X-Content-Type-Options and
+// X-Frame-Options to every response. Browsers ignore HSTS received over plain
+// HTTP, so it is sent regardless of the scheme. Handlers serving HTML replace the
+// API's policy with their own.
+func SecurityHeaders(cfg SecurityHeadersConfig) gin.HandlerFunc {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		if hsts != "" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		if cfg.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		}
+		if cfg.FrameOptions != "" {
+			header.Set("X-Frame-Options", cfg.FrameOptions)
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+		c.Next()
+	}
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/tenant"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantResolver finds the organization named by a slug or ID in which the user is
+// a member, returning tenant.ErrNotFound otherwise
+type TenantResolver interface {
+	ResolveTenant(ctx context.Context, key string, userID int) (*tenant.Tenant, error)
+}
+
+// RequireTenant resolves the organization of the request from the sources of cfg,
+// checks that the caller is a member and puts the tenant on the request context.
+// A token scoped to an organization cannot act in another one. It must run after
+// RequireAuth.
+func RequireTenant(cfg tenant.Config, resolver TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		key := tenantKey(c, cfg, principal)
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, common.ErrorResponse{
+				Status:  http.StatusBadRequest,
+				Error:   "Organization required",
+				Message: "Name the organization in the " + cfg.Header + " header",
+			})
+			return
+		}
+
+		t, err := resolver.ResolveTenant(c.Request.Context(), key, principal.UserID)
+		if err != nil {
+			if errors.Is(err, tenant.ErrNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, common.ErrorResponse{
+					Status: http.StatusNotFound,
+					Error:  "Organization not found",
+				})
+				return
+			}
+			logging.FromContext(c.Request.Context()).Error("error while resolving tenant", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+			return
+		}
+
+		if principal.TenantID != 0 && principal.TenantID != t.ID {
+			c.AbortWithStatusJSON(http.StatusForbidden, common.ErrorResponse{
+				Status:  http.StatusForbidden,
+				Error:   "Forbidden",
+				Message: "The access token is scoped to another organization",
+			})
+			return
+		}
+
+		ctx := logging.With(c.Request.Context(), "tenant_id", t.ID)
+		c.Request = c.Request.WithContext(tenant.WithTenant(ctx, t))
+		c.Next()
+	}
+}
+
+// tenantKey returns the organization named by the first source of cfg that names one
+func tenantKey(c *gin.Context, cfg tenant.Config, principal *Principal) string {
+	for _, source := range cfg.Sources {
+		var key string
+		switch source {
+		case tenant.SourceClaim:
+			if principal.TenantID != 0 {
+				key = strconv.FormatInt(principal.TenantID, 10)
+			}
+		case tenant.SourceHeader:
+			key = c.GetHeader(cfg.Header)
+		case tenant.SourceSubdomain:
+			key = cfg.Subdomain(c.Request.Host)
+		}
+		if key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// RequireTenantRole rejects callers whose role in the request's organization is not
+// one of roles. It must run after RequireTenant.
+func RequireTenantRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := tenant.FromContext(c.Request.Context())
+		if !ok || !slices.Contains(roles, t.Role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, common.ErrorResponse{
+				Status:  http.StatusForbidden,
+				Error:   "Forbidden",
+				Message: "You do not have permission to perform this action in the organization",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"encoding/json"
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/openapi"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type validationBody struct {
+	Name  string   `json:"name" binding:"required"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+}
+
+// validationRouter serves POST /things behind a fake authentication that requires
+// an Authorization header, and POST /undocumented which the document does not describe
+func validationRouter(cfg ValidationConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	doc := openapi.New("Test", "1.0.0", "")
+	doc.Add(openapi.Route{Method: http.MethodPost, Path: "/things", Body: validationBody{}})
+
+	requireAuth := func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		}
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	r := gin.New()
+	r.Use(LimitRequestBody(cfg))
+	r.POST("/things", requireAuth, ValidateRequest(doc, cfg), ok)
+	r.POST("/undocumented", requireAuth, ValidateRequest(doc, cfg), ok)
+	return r
+}
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		strict      bool
+		path        string
+		contentType string
+		body        string
+		anonymous   bool
+		wantStatus  int
+		wantDetails []string
+	}{
+		{name: "valid", body: `{"name":"a","count":1,"tags":["x"]}`, wantStatus: http.StatusOK},
+		{name: "content type with parameters", contentType: "application/json; charset=utf-8", body: `{"name":"a"}`, wantStatus: http.StatusOK},
+		{name: "wrong content type", contentType: "text/plain", body: `{"name":"a"}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "too large", body: `{"name":"` + strings.Repeat("a", 100) + `"}`, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "wrong types", body: `{"name":1,"count":"2","tags":[3]}`, wantStatus: http.StatusBadRequest, wantDetails: []string{"name", "count", "tags[0]"}},
+		{name: "unknown field", body: `{"name":"a","extra":true}`, wantStatus: http.StatusOK},
+		{name: "unknown field in strict mode", strict: true, body: `{"name":"a","extra":true}`, wantStatus: http.StatusBadRequest, wantDetails: []string{"extra"}},
+		{name: "malformed JSON is left to the handler", body: `{"name":`, wantStatus: http.StatusOK},
+		{name: "undocumented route", path: "/undocumented", contentType: "text/plain", body: "anything", wantStatus: http.StatusOK},
+		{name: "unauthenticated", anonymous: true, contentType: "text/plain", body: `{"name":1}`, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := validationRouter(ValidationConfig{MaxBodyBytes: 64, Strict: tt.strict})
+
+			path := tt.path
+			if path == "" {
+				path = "/things"
+			}
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if !tt.anonymous {
+				req.Header.Set("Authorization", "Bearer test")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if len(tt.wantDetails) == 0 {
+				return
+			}
+			var response common.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range tt.wantDetails {
+				if response.Details[field] == "" {
+					t.Errorf("details = %v, want an entry for %s", response.Details, field)
+				}
+			}
+		})
+	}
+}
+
+func TestRouteMaxBodyBytes(t *testing.T) {
+	r := validationRouter(ValidationConfig{MaxBodyBytes: 8, RouteMaxBodyBytes: map[string]int64{"POST /things": 1024}})
+
+	req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(`{"name":"longer than eight bytes"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer test")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the route's larger limit to apply", w.Code)
+	}
+}
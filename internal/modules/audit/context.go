@@ -0,0 +1,40 @@
+package audit
+
+import "context"
+
+type contextKey int
+
+const clientKey contextKey = iota
+
+// Client identifies who is performing a change, as recorded in audit entries
+type Client struct {
+	ActorID      *int
+	ActorService string // Service performing the change, for requests without a user
+	IPAddress    string
+	UserAgent    string
+}
+
+// WithClient returns a context carrying the client performing the request
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey, client)
+}
+
+// WithActor returns a context whose client is attributed to the authenticated user
+func WithActor(ctx context.Context, userID int) context.Context {
+	client := ClientFromContext(ctx)
+	client.ActorID = &userID
+	return WithClient(ctx, client)
+}
+
+// WithService returns a context whose client is attributed to the authenticated service
+func WithService(ctx context.Context, service string) context.Context {
+	client := ClientFromContext(ctx)
+	client.ActorService = service
+	return WithClient(ctx, client)
+}
+
+// ClientFromContext returns the client stored in the context, if any
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey).(Client)
+	return client
+}
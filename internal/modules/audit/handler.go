@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"metalcore-api/internal/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// List returns audit entries, newest first, filtered by the query parameters
+func (h *Handler) List(c *gin.Context) {
+	var filter ListAuditRequest
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid query parameters",
+			Message: err.Error(),
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	entries, total, err := h.service.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+			Status:  http.StatusInternalServerError,
+			Error:   "Internal server error",
+			Message: "An unexpected error occurred",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.PaginatedResponse{
+		Data:       ToAuditEntryListResponse(entries),
+		Pagination: common.NewPaginationMetadata(&filter.PaginationRequest, total),
+	})
+}
@@ -0,0 +1,22 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Entry struct {
+	AuditID      int64           `db:"AuditId" json:"audit_id"`
+	OccurredAt   time.Time       `db:"OccurredAt" json:"occurred_at"`
+	ActorID      *int            `db:"ActorId" json:"actor_id,omitempty"`
+	ActorService *string         `db:"ActorService" json:"actor_service,omitempty"`
+	Action       string          `db:"Action" json:"action"`
+	TargetType   string          `db:"TargetType" json:"target_type"`
+	TargetID     string          `db:"TargetId" json:"target_id"`
+	Before       json.RawMessage `db:"Before" json:"before,omitempty"`
+	After        json.RawMessage `db:"After" json:"after,omitempty"`
+	Diff         json.RawMessage `db:"Diff" json:"diff,omitempty"`
+	IPAddress    *string         `db:"IpAddress" json:"ip_address,omitempty"`
+	UserAgent    *string         `db:"UserAgent" json:"user_agent,omitempty"`
+	RequestID    *string         `db:"RequestId" json:"request_id,omitempty"`
+}
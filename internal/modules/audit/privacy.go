@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"context"
+	"strconv"
+
+	"metalcore-api/internal/database"
+)
+
+// Exporter contributes the audit entries about the user, or made by them, to personal data exports
+type Exporter struct {
+	repo *AuditRepository
+}
+
+func NewExporter(db database.DBTX) *Exporter {
+	return &Exporter{repo: NewAuditRepository(db)}
+}
+
+func (e *Exporter) Name() string { return "audit_log" }
+
+func (e *Exporter) Export(ctx context.Context, userID int) (any, error) {
+	entries, err := e.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, entry := range entries {
+		// Changes the user made to someone else hold the other party's data
+		if entry.TargetType != TargetUser || entry.TargetID != strconv.Itoa(userID) {
+			entries[i].Before, entries[i].After, entries[i].Diff = nil, nil, nil
+		}
+	}
+
+	return ToAuditEntryListResponse(entries), nil
+}
@@ -0,0 +1,298 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type AuditRepository struct {
+	db database.DBTX
+}
+
+func NewAuditRepository(db database.DBTX) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Insert appends an entry. Pass the transaction of the audited change as db so
+// that the entry is committed or rolled back together with it.
+func (r *AuditRepository) Insert(ctx context.Context, db database.DBTX, entry *Entry) error {
+	query := `
+		INSERT INTO public."AuditLog" (
+			"ActorId",
+			"ActorService",
+			"Action",
+			"TargetType",
+			"TargetId",
+			"Before",
+			"After",
+			"Diff",
+			"IpAddress",
+			"UserAgent",
+			"RequestId"
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING
+			"AuditId",
+			"OccurredAt"
+	`
+
+	err := db.QueryRow(
+		ctx,
+		query,
+		entry.ActorID,
+		entry.ActorService,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.Before,
+		entry.After,
+		entry.Diff,
+		entry.IPAddress,
+		entry.UserAgent,
+		entry.RequestID,
+	).Scan(
+		&entry.AuditID,
+		&entry.OccurredAt,
+	)
+
+	if err != nil {
+		logging.FromContext(ctx).Error("error while inserting audit entry", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuditRepository) List(ctx context.Context, filter ListAuditRequest) ([]Entry, int64, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != nil {
+		addCondition(`"ActorId" = $%d`, *filter.ActorID)
+	}
+	if filter.ActorService != "" {
+		addCondition(`"ActorService" = $%d`, filter.ActorService)
+	}
+	if filter.Action != "" {
+		addCondition(`"Action" = $%d`, filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition(`"TargetType" = $%d`, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		addCondition(`"TargetId" = $%d`, filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		addCondition(`"RequestId" = $%d`, filter.RequestID)
+	}
+	if filter.From != nil {
+		addCondition(`"OccurredAt" >= $%d`, *filter.From)
+	}
+	if filter.To != nil {
+		addCondition(`"OccurredAt" < $%d`, *filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var totalCount int64
+	countQuery := `SELECT COUNT(*) FROM public."AuditLog" ` + where
+
+	err := r.db.QueryRow(ctx, countQuery, args...).Scan(&totalCount)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in List (count)", "error", err)
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			"AuditId",
+			"OccurredAt",
+			"ActorId",
+			"ActorService",
+			"Action",
+			"TargetType",
+			"TargetId",
+			"Before",
+			"After",
+			"Diff",
+			"IpAddress",
+			"UserAgent",
+			"RequestId"
+		FROM public."AuditLog"
+		%s
+		ORDER BY "OccurredAt" DESC, "AuditId" DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+
+	rows, err := r.db.Query(ctx, query, append(args, filter.GetLimit(), filter.GetOffset())...)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in List", "error", err)
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var entries []Entry
+
+	for rows.Next() {
+		var entry Entry
+
+		err := rows.Scan(
+			&entry.AuditID,
+			&entry.OccurredAt,
+			&entry.ActorID,
+			&entry.ActorService,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&entry.Before,
+			&entry.After,
+			&entry.Diff,
+			&entry.IPAddress,
+			&entry.UserAgent,
+			&entry.RequestID,
+		)
+		if err != nil {
+			logging.FromContext(ctx).Error("error scanning audit row", "error", err)
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("error iterating rows", "error", err)
+		return nil, 0, err
+	}
+
+	return entries, totalCount, nil
+}
+
+// enableMaintenance lets the rest of the transaction update and delete audit
+// entries, which the append-only trigger rejects otherwise
+func enableMaintenance(ctx context.Context, db database.DBTX) error {
+	_, err := db.Exec(ctx, `SET LOCAL app.audit_maintenance = 'on'`)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while enabling audit maintenance", "error", err)
+	}
+	return err
+}
+
+// RedactTarget removes the before/after snapshots of every entry about the target,
+// keeping who did what and when. Must run in a transaction.
+func (r *AuditRepository) RedactTarget(ctx context.Context, db database.DBTX, targetType, targetID string) (int64, error) {
+	if err := enableMaintenance(ctx, db); err != nil {
+		return 0, err
+	}
+
+	query := `
+		UPDATE public."AuditLog"
+		SET "Before" = NULL,
+			"After" = NULL,
+			"Diff" = NULL
+		WHERE "TargetType" = $1
+		  AND "TargetId" = $2
+		  AND ("Before" IS NOT NULL OR "After" IS NOT NULL OR "Diff" IS NOT NULL)
+	`
+
+	tag, err := db.Exec(ctx, query, targetType, targetID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while redacting audit entries", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteBefore deletes up to limit entries that occurred before the cutoff. With
+// dryRun they are only counted, without limit. Must run in a transaction.
+func (r *AuditRepository) DeleteBefore(ctx context.Context, db database.DBTX, before time.Time, limit int, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := db.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM public."AuditLog"
+			WHERE "OccurredAt" < $1
+		`, before).Scan(&count)
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in DeleteBefore (count)", "error", err)
+		}
+		return count, err
+	}
+
+	if err := enableMaintenance(ctx, db); err != nil {
+		return 0, err
+	}
+
+	query := `
+		DELETE FROM public."AuditLog"
+		WHERE "AuditId" IN (
+			SELECT "AuditId"
+			FROM public."AuditLog"
+			WHERE "OccurredAt" < $1
+			ORDER BY "AuditId"
+			LIMIT $2
+		)
+	`
+
+	tag, err := db.Exec(ctx, query, before, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting audit entries", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ListForUser returns the entries the user acted in or that target the user, oldest first
+func (r *AuditRepository) ListForUser(ctx context.Context, userID int) ([]Entry, error) {
+	query := `
+		SELECT
+			"AuditId",
+			"OccurredAt",
+			"ActorId",
+			"ActorService",
+			"Action",
+			"TargetType",
+			"TargetId",
+			"Before",
+			"After",
+			"Diff",
+			"IpAddress",
+			"UserAgent",
+			"RequestId"
+		FROM public."AuditLog"
+		WHERE "ActorId" = $1
+		   OR ("TargetType" = $2 AND "TargetId" = $3)
+		ORDER BY "OccurredAt", "AuditId"
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, TargetUser, strconv.Itoa(userID))
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ListForUser", "error", err)
+		return nil, err
+	}
+
+	entries, err := pgx.CollectRows(rows, pgx.RowToStructByName[Entry])
+	if err != nil {
+		logging.FromContext(ctx).Error("error scanning audit rows", "error", err)
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,19 @@
+package audit
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func RegisterRoutes(rg *gin.RouterGroup, db *pgxpool.Pool, requireAdmin ...gin.HandlerFunc) {
+	// Initialize dependencies (Dependency Injection)
+	repo := NewAuditRepository(db)
+	service := NewService(repo)
+	handler := NewHandler(service)
+
+	// Register routes, the audit log is only readable by administrators
+	auditGroup := rg.Group("/audit", requireAdmin...)
+	{
+		auditGroup.GET("", handler.List)
+	}
+}
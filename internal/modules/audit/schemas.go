@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"encoding/json"
+	"metalcore-api/internal/common"
+	"time"
+)
+
+// ListAuditRequest represents the query parameters for listing audit entries
+type ListAuditRequest struct {
+	common.PaginationRequest
+	ActorID      *int       `form:"actor_id" binding:"omitempty,min=1"`
+	ActorService string     `form:"actor_service" binding:"omitempty,max=255"`
+	Action       string     `form:"action" binding:"omitempty,max=100"`
+	TargetType   string     `form:"target_type" binding:"omitempty,max=50"`
+	TargetID     string     `form:"target_id" binding:"omitempty,max=100"`
+	RequestID    string     `form:"request_id" binding:"omitempty,max=128"`
+	From         *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"` // Inclusive lower bound of OccurredAt (RFC 3339)
+	To           *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`   // Exclusive upper bound of OccurredAt (RFC 3339)
+}
+
+// AuditEntryResponse represents the HTTP response structure for an audit entry
+type AuditEntryResponse struct {
+	AuditID      int64           `json:"audit_id"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	ActorID      *int            `json:"actor_id,omitempty"`
+	ActorService *string         `json:"actor_service,omitempty"`
+	Action       string          `json:"action"`
+	TargetType   string          `json:"target_type"`
+	TargetID     string          `json:"target_id"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	Diff         json.RawMessage `json:"diff,omitempty"`
+	IPAddress    *string         `json:"ip_address,omitempty"`
+	UserAgent    *string         `json:"user_agent,omitempty"`
+	RequestID    *string         `json:"request_id,omitempty"`
+}
+
+// ToAuditEntryListResponse converts a slice of Entry models to AuditEntryResponse schemas
+func ToAuditEntryListResponse(entries []Entry) []AuditEntryResponse {
+	responses := make([]AuditEntryResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = AuditEntryResponse{
+			AuditID:      entry.AuditID,
+			OccurredAt:   entry.OccurredAt,
+			ActorID:      entry.ActorID,
+			ActorService: entry.ActorService,
+			Action:       entry.Action,
+			TargetType:   entry.TargetType,
+			TargetID:     entry.TargetID,
+			Before:       entry.Before,
+			After:        entry.After,
+			Diff:         entry.Diff,
+			IPAddress:    entry.IPAddress,
+			UserAgent:    entry.UserAgent,
+			RequestID:    entry.RequestID,
+		}
+	}
+	return responses
+}
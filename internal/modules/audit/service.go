@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Target types recorded in the audit log
+const (
+	TargetUser         = "user"
+	TargetOrganization = "organization"
+	TargetInvitation   = "invitation"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionUserCreate         = "user.create"
+	ActionUserUpdate         = "user.update"
+	ActionUserUpdateProfile  = "user.update_profile"
+	ActionUserDelete         = "user.delete"
+	ActionUserRestore        = "user.restore"
+	ActionUserPasswordChange = "user.password_change"
+	ActionUserPasswordRehash = "user.password_rehash"
+	ActionUserEmailChange    = "user.email_change"
+	ActionUserAnonymize      = "user.anonymize"
+	ActionUserPurge          = "user.purge"
+	ActionUserErase          = "user.erase"
+
+	ActionOrganizationCreate       = "organization.create"
+	ActionOrganizationMemberAdd    = "organization.member_add"
+	ActionOrganizationMemberUpdate = "organization.member_update"
+	ActionOrganizationMemberRemove = "organization.member_remove"
+
+	ActionInvitationCreate = "invitation.create"
+	ActionInvitationResend = "invitation.resend"
+	ActionInvitationRevoke = "invitation.revoke"
+	ActionInvitationAccept = "invitation.accept"
+)
+
+type Service struct {
+	repo *AuditRepository
+}
+
+func NewService(repo *AuditRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// Record appends an audit entry for action on the target using db, which should be
+// the transaction of the change so that the entry is written atomically with it.
+// before and after are JSON snapshots of the target, either may be nil. The actor,
+// IP address, user agent and request ID are taken from the context.
+func (s *Service) Record(ctx context.Context, db database.DBTX, action, targetType, targetID string, before, after any) error {
+	ctx, span := tracing.Start(ctx, "audit.Service.Record")
+	defer span.End()
+
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+	diff, err := diffSnapshots(beforeJSON, afterJSON)
+	if err != nil {
+		return err
+	}
+	if beforeJSON, err = redact(beforeJSON); err != nil {
+		return err
+	}
+	if afterJSON, err = redact(afterJSON); err != nil {
+		return err
+	}
+
+	client := ClientFromContext(ctx)
+	entry := &Entry{
+		ActorID:      client.ActorID,
+		ActorService: optional(client.ActorService),
+		Action:       action,
+		TargetType:   targetType,
+		TargetID:     targetID,
+		Before:       beforeJSON,
+		After:        afterJSON,
+		Diff:         diff,
+		IPAddress:    optional(client.IPAddress),
+		UserAgent:    optional(client.UserAgent),
+		RequestID:    optional(logging.RequestID(ctx)),
+	}
+
+	return s.repo.Insert(ctx, db, entry)
+}
+
+func (s *Service) List(ctx context.Context, filter ListAuditRequest) ([]Entry, int64, error) {
+	ctx, span := tracing.Start(ctx, "audit.Service.List")
+	defer span.End()
+
+	return s.repo.List(ctx, filter)
+}
+
+// RedactTarget strips the snapshots of every entry about the target, within the
+// transaction db that erases the target's personal data
+func (s *Service) RedactTarget(ctx context.Context, db database.DBTX, targetType, targetID string) error {
+	ctx, span := tracing.Start(ctx, "audit.Service.RedactTarget")
+	defer span.End()
+
+	_, err := s.repo.RedactTarget(ctx, db, targetType, targetID)
+	return err
+}
+
+// Purge deletes entries that occurred before the cutoff in batches of batchSize and
+// returns how many were deleted, or with dryRun how many would be
+func (s *Service) Purge(ctx context.Context, db database.DBTX, before time.Time, batchSize int, dryRun bool) (int64, error) {
+	ctx, span := tracing.Start(ctx, "audit.Service.Purge")
+	defer span.End()
+
+	if dryRun {
+		return s.repo.DeleteBefore(ctx, db, before, batchSize, true)
+	}
+
+	var total int64
+	for {
+		var deleted int64
+		err := database.WithTx(ctx, db, func(tx pgx.Tx) error {
+			var err error
+			deleted, err = s.repo.DeleteBefore(ctx, tx, before, batchSize, false)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		if deleted < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// personalFields are the snapshot fields holding personal data. Their values never
+// reach the audit log, the diff still names them when they change.
+var personalFields = map[string]bool{
+	"username":   true,
+	"first_name": true,
+	"last_name":  true,
+	"email":      true,
+	"phone":      true,
+}
+
+// redactedValue replaces the value of a personal field that is set
+var redactedValue = json.RawMessage(`"[redacted]"`)
+
+// redactValue returns redactedValue for a personal field's value, keeping null so
+// that setting and clearing a field can still be told apart
+func redactValue(key string, value json.RawMessage) json.RawMessage {
+	if !personalFields[key] || value == nil || bytes.Equal(value, []byte("null")) {
+		return value
+	}
+	return redactedValue
+}
+
+// redact replaces the values of the personal fields of a snapshot
+func redact(snapshot json.RawMessage) (json.RawMessage, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(snapshot, &fields); err != nil {
+		return nil, err
+	}
+
+	redacted := false
+	for key, value := range fields {
+		if personal := redactValue(key, value); !bytes.Equal(personal, value) {
+			fields[key] = personal
+			redacted = true
+		}
+	}
+	if !redacted {
+		return snapshot, nil
+	}
+	return json.Marshal(fields)
+}
+
+// snapshot marshals a target to JSON. Fields tagged json:"-", such as password
+// hashes, never reach the audit log.
+func snapshot(value any) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// diffSnapshots returns the top level fields that differ between the snapshots
+// as {"field": {"from": ..., "to": ...}}, or nil when either side is missing.
+// The values of personal fields are redacted.
+func diffSnapshots(before, after json.RawMessage) (json.RawMessage, error) {
+	if before == nil || after == nil {
+		return nil, nil
+	}
+
+	var from, to map[string]json.RawMessage
+	if err := json.Unmarshal(before, &from); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(after, &to); err != nil {
+		return nil, err
+	}
+
+	type change struct {
+		From json.RawMessage `json:"from"`
+		To   json.RawMessage `json:"to"`
+	}
+
+	changes := map[string]change{}
+	for key, value := range from {
+		if other, ok := to[key]; !ok || !bytes.Equal(value, other) {
+			changes[key] = change{From: redactValue(key, value), To: redactValue(key, orNull(other))}
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			changes[key] = change{From: orNull(nil), To: redactValue(key, value)}
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+func orNull(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	return value
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestSnapshotsRedactPersonalFields(t *testing.T) {
+	type target struct {
+		UserID    int     `json:"user_id"`
+		Email     string  `json:"email"`
+		FirstName *string `json:"first_name"`
+		Role      string  `json:"role"`
+	}
+
+	name := "Ada"
+	before, _ := snapshot(target{UserID: 7, Email: "ada@example.com", Role: "user"})
+	after, _ := snapshot(target{UserID: 7, Email: "ada@example.org", FirstName: &name, Role: "admin"})
+
+	diff, err := diffSnapshots(before, after)
+	if err != nil {
+		t.Fatalf("diffSnapshots() error = %v", err)
+	}
+	var changes map[string]map[string]any
+	if err := json.Unmarshal(diff, &changes); err != nil {
+		t.Fatalf("diff %s is not an object: %v", diff, err)
+	}
+
+	want := map[string][2]any{
+		"email":      {"[redacted]", "[redacted]"},
+		"first_name": {nil, "[redacted]"},
+		"role":       {"user", "admin"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("diff = %s, want the fields %v", diff, want)
+	}
+	for field, values := range want {
+		if changes[field]["from"] != values[0] || changes[field]["to"] != values[1] {
+			t.Fatalf("diff[%s] = %v, want from %v to %v", field, changes[field], values[0], values[1])
+		}
+	}
+
+	redacted, err := redact(after)
+	if err != nil {
+		t.Fatalf("redact() error = %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(redacted, &fields); err != nil {
+		t.Fatalf("redacted snapshot %s is not an object: %v", redacted, err)
+	}
+	if fields["email"] != "[redacted]" || fields["first_name"] != "[redacted]" || fields["user_id"] != float64(7) || fields["role"] != "admin" {
+		t.Fatalf("redact() = %s, want personal fields redacted and the rest kept", redacted)
+	}
+
+	cleared, _ := redact(before)
+	fields = nil
+	if err := json.Unmarshal(cleared, &fields); err != nil || fields["first_name"] != nil {
+		t.Fatalf("redact() = %s, want null fields kept as null", cleared)
+	}
+}
+
+func TestWithServiceKeepsClient(t *testing.T) {
+	ctx := WithClient(context.Background(), Client{IPAddress: "10.0.0.1", UserAgent: "billing/1.0"})
+	client := ClientFromContext(WithService(ctx, "billing"))
+
+	if client.ActorService != "billing" || client.ActorID != nil {
+		t.Errorf("actor = %v/%q, want the billing service", client.ActorID, client.ActorService)
+	}
+	if client.IPAddress != "10.0.0.1" || client.UserAgent != "billing/1.0" {
+		t.Errorf("client = %+v, want the request's IP and user agent kept", client)
+	}
+}
@@ -0,0 +1,15 @@
+package auth
+
+import "time"
+
+// TokenPair is the result of a successful authentication at the service layer
+type TokenPair struct {
+	AccessToken string
+	ExpiresIn   time.Duration
+}
+
+// ClientInfo describes the client that is authenticating
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"metalcore-api/internal/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) Login(c *gin.Context) {
+	var payload LoginRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationErrors := common.FormatValidationErrors(err, c.GetHeader("Accept-Language"))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: validationErrors,
+		})
+		return
+	}
+
+	tokens, err := h.service.Login(c.Request.Context(), payload, ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
+	if err != nil {
+		switch err {
+		case ErrInvalidCredentials:
+			c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+				Status:  http.StatusUnauthorized,
+				Error:   "Invalid credentials",
+				Message: "Email or password is incorrect",
+			})
+		case ErrNotMember:
+			c.JSON(http.StatusForbidden, common.ErrorResponse{
+				Status:  http.StatusForbidden,
+				Error:   "Not a member",
+				Message: "You are not a member of the organization",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	// Access tokens must not be kept by caches or replayed by the idempotency store
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"data": ToAuthResponse(tokens),
+	})
+}
@@ -1,7 +1,30 @@
-// package auth
+package auth
 
-// import "github.com/gin-gonic/gin"
+import (
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/organization"
+	"metalcore-api/internal/modules/session"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/password"
+	"metalcore-api/internal/pii"
+	"metalcore-api/internal/token"
 
-// func RegisterRoutes(r *gin.Engine) {
-// 	r.POST("/login", Login)
-// }
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func RegisterRoutes(rg *gin.RouterGroup, db *pgxpool.Pool, hasher *password.Hasher, cipher *pii.Cipher, tokens *token.Manager, validate gin.HandlerFunc) {
+	// Initialize dependencies (Dependency Injection)
+	userRepo := user.NewUserRepository(db, cipher)
+	sessionRepo := session.NewSessionRepository(db)
+	auditService := audit.NewService(audit.NewAuditRepository(db))
+	tenants := organization.NewService(db, organization.NewOrganizationRepository(db), userRepo, auditService)
+	service := NewService(db, userRepo, sessionRepo, auditService, hasher, tokens, tenants)
+	handler := NewHandler(service)
+
+	// Register routes
+	authGroup := rg.Group("/auth", validate)
+	{
+		authGroup.POST("/login", handler.Login)
+	}
+}
@@ -4,16 +4,18 @@ package auth
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// Organization scopes the access token to an organization the user is a member of, by slug or ID
+	Organization string `json:"organization" binding:"omitempty,max=63"`
 }
 
 // RegisterRequest represents the HTTP request structure for user registration
 type RegisterRequest struct {
-	Username  string  `json:"username" binding:"required,min=3,max=50"`
+	Username  string  `json:"username" binding:"required,min=3,max=50,username"`
 	Email     string  `json:"email" binding:"required,email"`
-	Password  string  `json:"password" binding:"required,min=8"`
+	Password  string  `json:"password" binding:"required,min=8,password_strength"`
 	FirstName *string `json:"first_name" binding:"omitempty,max=100"`
 	LastName  *string `json:"last_name" binding:"omitempty,max=100"`
-	Phone     *string `json:"phone" binding:"omitempty"`
+	Phone     *string `json:"phone" binding:"omitempty,e164"`
 }
 
 // AuthResponse represents the HTTP response structure for authentication
@@ -28,3 +30,16 @@ type AuthResponse struct {
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
+
+// ToAuthResponse converts a TokenPair to the AuthResponse schema
+func ToAuthResponse(tokens *TokenPair) *AuthResponse {
+	if tokens == nil {
+		return nil
+	}
+
+	return &AuthResponse{
+		Token:     tokens.AccessToken,
+		ExpiresIn: int(tokens.ExpiresIn.Seconds()),
+		TokenType: "Bearer",
+	}
+}
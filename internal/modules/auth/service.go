@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/metrics"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/organization"
+	"metalcore-api/internal/modules/session"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/password"
+	"metalcore-api/internal/tenant"
+	"metalcore-api/internal/token"
+	"metalcore-api/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrNotMember          = errors.New("user is not a member of the organization")
+)
+
+type Service struct {
+	db       database.DBTX
+	users    *user.UserRepository
+	sessions *session.SessionRepository
+	audit    *audit.Service
+	hasher   *password.Hasher
+	tokens   *token.Manager
+	tenants  *organization.Service
+}
+
+func NewService(db database.DBTX, users *user.UserRepository, sessions *session.SessionRepository, audit *audit.Service, hasher *password.Hasher, tokens *token.Manager, tenants *organization.Service) *Service {
+	return &Service{db: db, users: users, sessions: sessions, audit: audit, hasher: hasher, tokens: tokens, tenants: tenants}
+}
+
+// Login verifies the credentials, opens a session and issues an access token for it.
+// Hashes produced by an outdated algorithm or with outdated parameters are
+// transparently upgraded, and accounts within their deletion grace period are restored.
+// Naming an organization scopes the token to it, the user must be a member.
+func (s *Service) Login(ctx context.Context, payload LoginRequest, client ClientInfo) (*TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "auth.Service.Login")
+	defer span.End()
+
+	u, err := s.users.GetByEmail(ctx, payload.Email, user.DeletionGracePeriod())
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Takes as long as a wrong password, so the response time does not reveal accounts
+			s.hasher.VerifyDummy(payload.Password)
+			metrics.LoginFailures.WithLabelValues("unknown_email").Inc()
+			return nil, ErrInvalidCredentials
+		}
+		metrics.LoginFailures.WithLabelValues("error").Inc()
+		return nil, err
+	}
+
+	needsRehash, err := s.hasher.Verify(payload.Password, u.Password)
+	if err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			metrics.LoginFailures.WithLabelValues("wrong_password").Inc()
+			return nil, ErrInvalidCredentials
+		}
+		metrics.LoginFailures.WithLabelValues("error").Inc()
+		return nil, err
+	}
+
+	var tenantID int64
+	if payload.Organization != "" {
+		t, err := s.tenants.ResolveTenant(ctx, payload.Organization, u.UserID)
+		if err != nil {
+			if errors.Is(err, tenant.ErrNotFound) {
+				metrics.LoginFailures.WithLabelValues("not_member").Inc()
+				return nil, ErrNotMember
+			}
+			metrics.LoginFailures.WithLabelValues("error").Inc()
+			return nil, err
+		}
+		tenantID = t.ID
+	}
+
+	if needsRehash {
+		s.upgradeHash(ctx, u.UserID, payload.Password)
+	}
+
+	// Logging in during the deletion grace period cancels a self-deletion
+	if u.DeletedAt != nil {
+		err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+			if err := s.users.WithTx(tx).Restore(ctx, u.UserID); err != nil {
+				return err
+			}
+			after := *u
+			after.DeletedAt = nil
+			if err := s.audit.Record(audit.WithActor(ctx, u.UserID), tx, audit.ActionUserRestore, audit.TargetUser, strconv.Itoa(u.UserID), u, &after); err != nil {
+				return err
+			}
+			return user.PublishUserUpdated(ctx, tx, u, &after)
+		})
+		if err != nil {
+			return nil, err
+		}
+		metrics.UsersRestored.Inc()
+	}
+
+	created, err := s.sessions.Create(ctx, &session.Session{
+		UserID:    u.UserID,
+		UserAgent: optional(client.UserAgent),
+		IPAddress: optional(client.IPAddress),
+		ExpiresAt: time.Now().Add(s.tokens.AccessTTL()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.tokens.Issue(u.UserID, u.Role, tenantID, created.SessionID, created.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.LoginSuccesses.Inc()
+
+	return &TokenPair{
+		AccessToken: accessToken,
+		ExpiresIn:   s.tokens.AccessTTL(),
+	}, nil
+}
+
+// upgradeHash stores a fresh hash for the password. Failures are logged and
+// ignored so that they never block a successful login.
+func (s *Service) upgradeHash(ctx context.Context, userID int, plain string) {
+	hashed, err := s.hasher.Hash(plain)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while rehashing password", "user_id", userID, "error", err)
+		metrics.PasswordRehashes.WithLabelValues("failed").Inc()
+		return
+	}
+
+	err = database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		if err := s.users.WithTx(tx).UpdatePassword(ctx, userID, hashed); err != nil {
+			return err
+		}
+		return s.audit.Record(audit.WithActor(ctx, userID), tx, audit.ActionUserPasswordRehash, audit.TargetUser, strconv.Itoa(userID), nil, nil)
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("error while storing rehashed password", "user_id", userID, "error", err)
+		metrics.PasswordRehashes.WithLabelValues("failed").Inc()
+		return
+	}
+	metrics.PasswordRehashes.WithLabelValues("upgraded").Inc()
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,241 @@
+package invitation
+
+import (
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/middleware"
+	"metalcore-api/internal/modules/organization"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/tenant"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	var payload CreateInvitationRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	invitation, err := h.service.Create(c.Request.Context(), t, principal.UserID, payload)
+	if err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "invitation has been sent.",
+		"data":    ToInvitationResponse(invitation),
+	})
+}
+
+func (h *Handler) List(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+
+	var pagination common.PaginationRequest
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid pagination parameters",
+			Message: err.Error(),
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	invitations, total, err := h.service.List(c.Request.Context(), t, pagination.GetPage(), pagination.GetPageSize())
+	if err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, common.PaginatedResponse{
+		Data:       ToInvitationListResponse(invitations),
+		Pagination: common.NewPaginationMetadata(&pagination, total),
+	})
+}
+
+func (h *Handler) Resend(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+	invitationID, ok := invitationIDParam(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.service.Resend(c.Request.Context(), t, invitationID)
+	if err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "invitation has been resent.",
+		"data":    ToInvitationResponse(invitation),
+	})
+}
+
+func (h *Handler) Revoke(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+	invitationID, ok := invitationIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Revoke(c.Request.Context(), t, invitationID); err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "invitation has been revoked.",
+	})
+}
+
+// Accept joins the invitation's organization, creating the account if needed
+func (h *Handler) Accept(c *gin.Context) {
+	var payload AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	acceptance, err := h.service.Accept(c.Request.Context(), payload)
+	if err != nil {
+		h.invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "invitation has been accepted.",
+		"data":    ToAcceptInvitationResponse(acceptance),
+	})
+}
+
+func (h *Handler) invitationError(c *gin.Context, err error) {
+	switch err {
+	case ErrInvitationNotFound:
+		c.JSON(http.StatusNotFound, common.ErrorResponse{
+			Status: http.StatusNotFound,
+			Error:  "Invitation not found",
+		})
+	case ErrInvitationExists:
+		c.JSON(http.StatusConflict, common.ErrorResponse{
+			Status:  http.StatusConflict,
+			Error:   "Invitation already exists",
+			Message: "Resend or revoke the open invitation instead",
+		})
+	case ErrInvitationClosed:
+		c.JSON(http.StatusConflict, common.ErrorResponse{
+			Status:  http.StatusConflict,
+			Error:   "Invitation closed",
+			Message: err.Error(),
+		})
+	case ErrInvalidInvitation:
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid token",
+			Message: "The invitation link is invalid, has expired or has been replaced",
+		})
+	case ErrAccountRequired:
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Account required",
+			Message: "No account uses the invited email, send account to create one",
+		})
+	case organization.ErrOwnerRequired:
+		c.JSON(http.StatusForbidden, common.ErrorResponse{
+			Status:  http.StatusForbidden,
+			Error:   "Forbidden",
+			Message: err.Error(),
+		})
+	case organization.ErrMemberExists:
+		c.JSON(http.StatusConflict, common.ErrorResponse{
+			Status: http.StatusConflict,
+			Error:  "User is already a member",
+		})
+	case user.ErrUsernameExists:
+		c.JSON(http.StatusConflict, common.ErrorResponse{
+			Status:  http.StatusConflict,
+			Error:   "Username already exists",
+			Message: "Please choose a different username",
+		})
+	case user.ErrEmailExists:
+		c.JSON(http.StatusConflict, common.ErrorResponse{
+			Status:  http.StatusConflict,
+			Error:   "Email already exists",
+			Message: "The invited email belongs to an inactive or deleted account",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+			Status:  http.StatusInternalServerError,
+			Error:   "Internal server error",
+			Message: "An unexpected error occurred",
+		})
+	}
+}
+
+// currentTenant returns the tenant set by middleware.RequireTenant
+func currentTenant(c *gin.Context) (*tenant.Tenant, bool) {
+	t, ok := tenant.FromContext(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Organization required",
+		})
+	}
+	return t, ok
+}
+
+func invitationIDParam(c *gin.Context) (int64, bool) {
+	invitationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || invitationID < 1 {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid invitation ID",
+		})
+		return 0, false
+	}
+	return invitationID, true
+}
@@ -0,0 +1,27 @@
+package invitation
+
+import (
+	"context"
+
+	"metalcore-api/internal/mailer"
+)
+
+// SendInvitationArgs is the job that mails an invitation. Only the invitation is
+// referenced: its token is issued when the job runs, so that it is never stored in
+// the queue.
+type SendInvitationArgs struct {
+	OrganizationID int64 `json:"organization_id"`
+	InvitationID   int64 `json:"invitation_id"`
+	SendCount      int   `json:"send_count"` // The sending the job belongs to, a resend supersedes it
+}
+
+func (SendInvitationArgs) Kind() string {
+	return "invitation.send"
+}
+
+// SendInvitationJob returns the handler of SendInvitationArgs jobs
+func SendInvitationJob(s *Service, m mailer.Mailer) func(ctx context.Context, args SendInvitationArgs) error {
+	return func(ctx context.Context, args SendInvitationArgs) error {
+		return s.Deliver(ctx, m, args.OrganizationID, args.InvitationID, args.SendCount)
+	}
+}
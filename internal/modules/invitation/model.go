@@ -0,0 +1,47 @@
+package invitation
+
+import "time"
+
+// Statuses of an invitation, derived from its timestamps
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusRevoked  = "revoked"
+	StatusExpired  = "expired"
+)
+
+type Invitation struct {
+	InvitationID   int64      `db:"InvitationId" json:"invitation_id"`
+	OrganizationID int64      `db:"OrganizationId" json:"organization_id"`
+	Email          string     `db:"Email" json:"email"`
+	Role           string     `db:"Role" json:"role"`
+	InvitedBy      *int       `db:"InvitedBy" json:"invited_by,omitempty"`
+	TokenHash      *string    `db:"TokenHash" json:"-"`
+	ExpiresAt      time.Time  `db:"ExpiresAt" json:"expires_at"`
+	SentAt         *time.Time `db:"SentAt" json:"sent_at,omitempty"`
+	SendCount      int        `db:"SendCount" json:"send_count"`
+	AcceptedAt     *time.Time `db:"AcceptedAt" json:"accepted_at,omitempty"`
+	AcceptedBy     *int       `db:"AcceptedBy" json:"accepted_by,omitempty"`
+	RevokedAt      *time.Time `db:"RevokedAt" json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `db:"CreatedAt" json:"created_at"`
+}
+
+// Status returns whether the invitation is pending, accepted, revoked or expired at now
+func (i *Invitation) Status(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return StatusAccepted
+	case i.RevokedAt != nil:
+		return StatusRevoked
+	case !now.Before(i.ExpiresAt):
+		return StatusExpired
+	default:
+		return StatusPending
+	}
+}
+
+// Open reports whether the invitation has been neither accepted nor revoked. Open
+// invitations can be resent and revoked, expired ones included.
+func (i *Invitation) Open() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil
+}
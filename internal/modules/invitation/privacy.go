@@ -0,0 +1,36 @@
+package invitation
+
+import (
+	"context"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/pii"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Exporter contributes the invitations addressed to the user's email to personal
+// data exports, erasing the user deletes them
+type Exporter struct {
+	repo *InvitationRepository
+}
+
+func NewExporter(db database.DBTX, cipher *pii.Cipher) *Exporter {
+	return &Exporter{repo: NewInvitationRepository(db, cipher)}
+}
+
+func (e *Exporter) Name() string { return "invitations" }
+
+func (e *Exporter) Export(ctx context.Context, userID int) (any, error) {
+	invitations, err := e.repo.ListForInvitee(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ToInvitationListResponse(invitations), nil
+}
+
+func (e *Exporter) Erase(ctx context.Context, tx pgx.Tx, userIDs []int) error {
+	_, err := e.repo.WithTx(tx).DeleteForInvitees(ctx, userIDs)
+	return err
+}
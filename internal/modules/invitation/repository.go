@@ -0,0 +1,458 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/pii"
+	"metalcore-api/internal/tenant"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The invited email is encrypted with the repository's cipher. Its blind index is
+// keyed like the users' email index, so that queries can match invitations to the
+// account of the address. The names must not change.
+const (
+	fieldEmail      = "InvitationEmail"
+	fieldEmailIndex = "Email"
+)
+
+// InvitationRepository stores the invitations of organizations. Every query runs
+// scoped to the invitation's tenant, or to the invitee, so that row-level security applies.
+type InvitationRepository struct {
+	db     database.DBTX
+	cipher *pii.Cipher
+}
+
+// NewInvitationRepository returns a repository encrypting the invited emails with
+// cipher. A nil cipher stores them in plaintext.
+func NewInvitationRepository(db database.DBTX, cipher *pii.Cipher) *InvitationRepository {
+	return &InvitationRepository{db: db, cipher: cipher}
+}
+
+// WithTx returns a repository that runs its queries in tx
+func (r *InvitationRepository) WithTx(tx pgx.Tx) *InvitationRepository {
+	return &InvitationRepository{db: tx, cipher: r.cipher}
+}
+
+// scoped runs fn in a transaction, or a savepoint of the current one, in which
+// row-level security limits invitations to tenantID
+func (r *InvitationRepository) scoped(ctx context.Context, tenantID int64, fn func(tx pgx.Tx) error) error {
+	return database.WithTx(ctx, r.db, func(tx pgx.Tx) error {
+		if err := tenant.Scope(ctx, tx, tenantID, 0); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// invitee runs fn in a transaction, or a savepoint of the current one, in which
+// row-level security limits invitations to those addressed to the email of userID
+func (r *InvitationRepository) invitee(ctx context.Context, userID int, fn func(tx pgx.Tx) error) error {
+	return database.WithTx(ctx, r.db, func(tx pgx.Tx) error {
+		if err := tenant.Scope(ctx, tx, 0, userID); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// addressedTo matches the invitations to the email of the user in $1, by blind
+// index or, for plaintext rows, by the address
+const addressedTo = `
+			EXISTS (
+				SELECT 1
+				FROM public."User" u
+				WHERE u."UserId" = $1
+				  AND (u."EmailIndex" = "Invitation"."EmailIndex"
+				   OR ("Invitation"."EmailIndex" IS NULL AND LOWER("Invitation"."Email") = LOWER(u."Email")))
+			)`
+
+const invitationColumns = `
+			"InvitationId",
+			"OrganizationId",
+			"Email",
+			"Role",
+			"InvitedBy",
+			"TokenHash",
+			"ExpiresAt",
+			"SentAt",
+			"SendCount",
+			"AcceptedAt",
+			"AcceptedBy",
+			"RevokedAt",
+			"CreatedAt"`
+
+// Create inserts an unsent invitation, the email is stored normalized
+func (r *InvitationRepository) Create(ctx context.Context, invitation *Invitation) (*Invitation, error) {
+	invitation.Email = common.NormalizeEmail(invitation.Email)
+	email, err := r.cipher.Encrypt(fieldEmail, invitation.Email)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while encrypting invitation email", "error", err)
+		return nil, err
+	}
+
+	err = r.scoped(ctx, invitation.OrganizationID, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO public."Invitation" (
+				"OrganizationId",
+				"Email",
+				"EmailIndex",
+				"Role",
+				"InvitedBy",
+				"ExpiresAt"
+			)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING
+				"InvitationId",
+				"CreatedAt"
+		`
+
+		return tx.QueryRow(
+			ctx,
+			query,
+			invitation.OrganizationID,
+			email,
+			r.cipher.BlindIndex(fieldEmailIndex, invitation.Email),
+			invitation.Role,
+			invitation.InvitedBy,
+			invitation.ExpiresAt,
+		).Scan(
+			&invitation.InvitationID,
+			&invitation.CreatedAt,
+		)
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("error while creating invitation", "error", err)
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// Lock returns the invitation and locks it until the end of the transaction
+func (r *InvitationRepository) Lock(ctx context.Context, tenantID, invitationID int64) (*Invitation, error) {
+	var invitation Invitation
+	err := r.scoped(ctx, tenantID, func(tx pgx.Tx) error {
+		query := `
+			SELECT` + invitationColumns + `
+			FROM public."Invitation"
+			WHERE "OrganizationId" = $1
+			  AND "InvitationId" = $2
+			FOR UPDATE
+		`
+
+		rows, err := tx.Query(ctx, query, tenantID, invitationID)
+		if err != nil {
+			return err
+		}
+		invitation, err = pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Invitation])
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("database error in Lock", "error", err)
+		}
+		return nil, err
+	}
+
+	if err := r.open(ctx, &invitation); err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// List returns a page of the organization's invitations, newest first
+func (r *InvitationRepository) List(ctx context.Context, tenantID int64, offset, limit int) ([]Invitation, int64, error) {
+	var invitations []Invitation
+	var totalCount int64
+	err := r.scoped(ctx, tenantID, func(tx pgx.Tx) error {
+		countQuery := `
+			SELECT COUNT(*)
+			FROM public."Invitation"
+			WHERE "OrganizationId" = $1
+		`
+
+		if err := tx.QueryRow(ctx, countQuery, tenantID).Scan(&totalCount); err != nil {
+			return err
+		}
+
+		query := `
+			SELECT` + invitationColumns + `
+			FROM public."Invitation"
+			WHERE "OrganizationId" = $1
+			ORDER BY "CreatedAt" DESC, "InvitationId" DESC
+			LIMIT $2 OFFSET $3
+		`
+
+		rows, err := tx.Query(ctx, query, tenantID, limit, offset)
+		if err != nil {
+			return err
+		}
+		invitations, err = pgx.CollectRows(rows, pgx.RowToStructByName[Invitation])
+		return err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in List", "error", err)
+		return nil, 0, err
+	}
+
+	for i := range invitations {
+		if err := r.open(ctx, &invitations[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return invitations, totalCount, nil
+}
+
+// Send clears the token of the invitation, sets its expiry and counts the sending.
+// The token of the sending is set by IssueToken.
+func (r *InvitationRepository) Send(ctx context.Context, invitation *Invitation, expiresAt time.Time) error {
+	err := r.scoped(ctx, invitation.OrganizationID, func(tx pgx.Tx) error {
+		query := `
+			UPDATE public."Invitation"
+			SET "TokenHash" = NULL,
+				"ExpiresAt" = $3,
+				"SentAt" = NOW(),
+				"SendCount" = "SendCount" + 1
+			WHERE "OrganizationId" = $1
+			  AND "InvitationId" = $2
+			RETURNING
+				"SentAt",
+				"SendCount"
+		`
+
+		return tx.QueryRow(ctx, query, invitation.OrganizationID, invitation.InvitationID, expiresAt).Scan(
+			&invitation.SentAt,
+			&invitation.SendCount,
+		)
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("error while sending invitation", "error", err)
+		return err
+	}
+
+	invitation.TokenHash = nil
+	invitation.ExpiresAt = expiresAt
+	return nil
+}
+
+// IssueToken replaces the token of the invitation
+func (r *InvitationRepository) IssueToken(ctx context.Context, invitation *Invitation, tokenHash string) error {
+	err := r.scoped(ctx, invitation.OrganizationID, func(tx pgx.Tx) error {
+		query := `
+			UPDATE public."Invitation"
+			SET "TokenHash" = $3
+			WHERE "OrganizationId" = $1
+			  AND "InvitationId" = $2
+		`
+
+		_, err := tx.Exec(ctx, query, invitation.OrganizationID, invitation.InvitationID, tokenHash)
+		return err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("error while issuing invitation token", "error", err)
+		return err
+	}
+
+	invitation.TokenHash = &tokenHash
+	return nil
+}
+
+// Revoke marks an open invitation as revoked
+func (r *InvitationRepository) Revoke(ctx context.Context, invitation *Invitation) error {
+	err := r.scoped(ctx, invitation.OrganizationID, func(tx pgx.Tx) error {
+		query := `
+			UPDATE public."Invitation"
+			SET "RevokedAt" = NOW()
+			WHERE "OrganizationId" = $1
+			  AND "InvitationId" = $2
+			  AND "AcceptedAt" IS NULL
+			  AND "RevokedAt" IS NULL
+			RETURNING "RevokedAt"
+		`
+
+		return tx.QueryRow(ctx, query, invitation.OrganizationID, invitation.InvitationID).Scan(&invitation.RevokedAt)
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("error while revoking invitation", "error", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Accept marks an open invitation as accepted by the user
+func (r *InvitationRepository) Accept(ctx context.Context, invitation *Invitation, userID int) error {
+	err := r.scoped(ctx, invitation.OrganizationID, func(tx pgx.Tx) error {
+		query := `
+			UPDATE public."Invitation"
+			SET "AcceptedAt" = NOW(),
+				"AcceptedBy" = $3
+			WHERE "OrganizationId" = $1
+			  AND "InvitationId" = $2
+			  AND "AcceptedAt" IS NULL
+			  AND "RevokedAt" IS NULL
+			RETURNING "AcceptedAt"
+		`
+
+		return tx.QueryRow(ctx, query, invitation.OrganizationID, invitation.InvitationID, userID).Scan(&invitation.AcceptedAt)
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("error while accepting invitation", "error", err)
+		}
+		return err
+	}
+
+	invitation.AcceptedBy = &userID
+	return nil
+}
+
+// ListForInvitee returns the invitations of every organization addressed to the
+// user's email, newest first
+func (r *InvitationRepository) ListForInvitee(ctx context.Context, userID int) ([]Invitation, error) {
+	var invitations []Invitation
+	err := r.invitee(ctx, userID, func(tx pgx.Tx) error {
+		query := `
+			SELECT` + invitationColumns + `
+			FROM public."Invitation"
+			WHERE` + addressedTo + `
+			ORDER BY "CreatedAt" DESC, "InvitationId" DESC
+		`
+
+		rows, err := tx.Query(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+		invitations, err = pgx.CollectRows(rows, pgx.RowToStructByName[Invitation])
+		return err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ListForInvitee", "error", err)
+		return nil, err
+	}
+
+	for i := range invitations {
+		if err := r.open(ctx, &invitations[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return invitations, nil
+}
+
+// DeleteForInvitees deletes the invitations of every organization addressed to the
+// users' emails and returns how many were deleted
+func (r *InvitationRepository) DeleteForInvitees(ctx context.Context, userIDs []int) (int64, error) {
+	var deleted int64
+	for _, userID := range userIDs {
+		err := r.invitee(ctx, userID, func(tx pgx.Tx) error {
+			tag, err := tx.Exec(ctx, `
+				DELETE FROM public."Invitation"
+				WHERE`+addressedTo, userID)
+			deleted += tag.RowsAffected()
+			return err
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in DeleteForInvitees", "error", err)
+			return 0, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// ReencryptBatch re-encrypts with the active key the emails of up to limit of the
+// organization's invitations after afterID that are plaintext, sealed with a retired
+// key or not normalized, and fills in missing blind indexes. It returns the last
+// invitation ID visited, 0 when there are no more invitations, and how many
+// invitations were, or with dryRun would be, updated.
+func (r *InvitationRepository) ReencryptBatch(ctx context.Context, tenantID, afterID int64, limit int, dryRun bool) (int64, int, error) {
+	var lastID int64
+	updated := 0
+	err := r.scoped(ctx, tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			SELECT
+				"InvitationId",
+				"Email",
+				"EmailIndex"
+			FROM public."Invitation"
+			WHERE "OrganizationId" = $1
+			  AND "InvitationId" > $2
+			ORDER BY "InvitationId"
+			LIMIT $3
+			FOR UPDATE
+		`, tenantID, afterID, limit)
+		if err != nil {
+			return err
+		}
+
+		type storedInvitation struct {
+			InvitationID int64
+			Email        string
+			EmailIndex   []byte
+		}
+		invitations, err := pgx.CollectRows(rows, pgx.RowToStructByPos[storedInvitation])
+		if err != nil {
+			return err
+		}
+
+		for _, stored := range invitations {
+			lastID = stored.InvitationID
+
+			email, err := r.cipher.Decrypt(fieldEmail, stored.Email)
+			if err != nil {
+				return err
+			}
+			if !r.cipher.NeedsReencrypt(stored.Email) && stored.EmailIndex != nil && email == common.NormalizeEmail(email) {
+				continue
+			}
+			updated++
+			if dryRun {
+				continue
+			}
+
+			email = common.NormalizeEmail(email)
+			sealed, err := r.cipher.Encrypt(fieldEmail, email)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(ctx, `
+				UPDATE public."Invitation"
+				SET "Email" = $3,
+					"EmailIndex" = $4
+				WHERE "OrganizationId" = $1
+				  AND "InvitationId" = $2
+			`, tenantID, stored.InvitationID, sealed, r.cipher.BlindIndex(fieldEmailIndex, email))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ReencryptBatch", "organization_id", tenantID, "error", err)
+		return 0, 0, err
+	}
+
+	return lastID, updated, nil
+}
+
+// open decrypts the email of a scanned invitation in place
+func (r *InvitationRepository) open(ctx context.Context, invitation *Invitation) error {
+	err := r.cipher.DecryptPtr(fieldEmail, &invitation.Email)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while decrypting invitation", "invitation_id", invitation.InvitationID, "error", err)
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package invitation
+
+import (
+	"metalcore-api/internal/middleware"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/organization"
+	"metalcore-api/internal/modules/session"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/password"
+	"metalcore-api/internal/pii"
+	"metalcore-api/internal/token"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func RegisterRoutes(rg *gin.RouterGroup, db *pgxpool.Pool, hasher *password.Hasher, cipher *pii.Cipher, tokens *token.Manager, validate, idempotent, requireAuth, requireTenant gin.HandlerFunc) {
+	// Initialize dependencies (Dependency Injection)
+	userRepo := user.NewUserRepository(db, cipher)
+	auditService := audit.NewService(audit.NewAuditRepository(db))
+	accounts := user.NewService(db, userRepo, session.NewSessionRepository(db), auditService, nil, hasher)
+	service := NewService(db, NewInvitationRepository(db, cipher), organization.NewOrganizationRepository(db), userRepo, accounts, auditService, tokens)
+	handler := NewHandler(service)
+
+	// Public route, the invitation token authorizes the request
+	rg.POST("/invitations/accept", validate, handler.Accept)
+
+	// Invitations of the organization the request acts in, owners and admins only
+	adminGroup := rg.Group("/org/invitations", requireAuth, requireTenant, middleware.RequireTenantRole(organization.RoleOwner, organization.RoleAdmin), validate)
+	{
+		adminGroup.POST("", idempotent, handler.Create)
+		adminGroup.GET("", handler.List)
+		adminGroup.POST("/:id/resend", handler.Resend)
+		adminGroup.DELETE("/:id", handler.Revoke)
+	}
+}
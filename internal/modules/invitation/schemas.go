@@ -0,0 +1,103 @@
+package invitation
+
+import (
+	"time"
+
+	"metalcore-api/internal/modules/organization"
+)
+
+// CreateInvitationRequest represents the HTTP request structure for inviting someone to the organization
+type CreateInvitationRequest struct {
+	Email          string `json:"email" binding:"required,email"`
+	Role           string `json:"role" binding:"required,oneof=owner admin member"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=720"` // Defaults to INVITATION_TTL
+}
+
+// AcceptInvitationRequest represents the HTTP request structure for accepting an invitation.
+// The invitation is added to the account registered with its email, Account creates
+// that account when there is none.
+type AcceptInvitationRequest struct {
+	Token   string             `json:"token" binding:"required"`
+	Account *NewAccountRequest `json:"account"`
+}
+
+// NewAccountRequest represents the account created when accepting an invitation, its
+// email is the invitation's
+type NewAccountRequest struct {
+	Username  string  `json:"username" binding:"required,min=3,max=50,username"`
+	FirstName *string `json:"first_name" binding:"omitempty,max=100"`
+	LastName  *string `json:"last_name" binding:"omitempty,max=100"`
+	Phone     *string `json:"phone" binding:"required,e164"`
+	Password  string  `json:"password" binding:"required,min=8,password_strength"`
+}
+
+// InvitationResponse represents an invitation, the token is only sent by email
+type InvitationResponse struct {
+	InvitationID   int64      `json:"invitation_id"`
+	OrganizationID int64      `json:"organization_id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	Status         string     `json:"status"`
+	InvitedBy      *int       `json:"invited_by,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+	SendCount      int        `json:"send_count"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	AcceptedBy     *int       `json:"accepted_by,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// AcceptInvitationResponse represents the membership created by accepting an invitation
+type AcceptInvitationResponse struct {
+	Organization   *organization.OrganizationResponse `json:"organization"`
+	UserID         int                                `json:"user_id"`
+	Username       string                             `json:"username"`
+	AccountCreated bool                               `json:"account_created"`
+}
+
+// ToInvitationResponse converts an invitation to the response schema
+func ToInvitationResponse(invitation *Invitation) *InvitationResponse {
+	if invitation == nil {
+		return nil
+	}
+
+	return &InvitationResponse{
+		InvitationID:   invitation.InvitationID,
+		OrganizationID: invitation.OrganizationID,
+		Email:          invitation.Email,
+		Role:           invitation.Role,
+		Status:         invitation.Status(time.Now()),
+		InvitedBy:      invitation.InvitedBy,
+		ExpiresAt:      invitation.ExpiresAt,
+		SentAt:         invitation.SentAt,
+		SendCount:      invitation.SendCount,
+		AcceptedAt:     invitation.AcceptedAt,
+		AcceptedBy:     invitation.AcceptedBy,
+		RevokedAt:      invitation.RevokedAt,
+		CreatedAt:      invitation.CreatedAt,
+	}
+}
+
+// ToInvitationListResponse converts invitations to the response schema
+func ToInvitationListResponse(invitations []Invitation) []*InvitationResponse {
+	responses := make([]*InvitationResponse, len(invitations))
+	for i := range invitations {
+		responses[i] = ToInvitationResponse(&invitations[i])
+	}
+	return responses
+}
+
+// ToAcceptInvitationResponse converts an acceptance to the response schema
+func ToAcceptInvitationResponse(acceptance *Acceptance) *AcceptInvitationResponse {
+	if acceptance == nil {
+		return nil
+	}
+
+	return &AcceptInvitationResponse{
+		Organization:   organization.ToOrganizationResponse(acceptance.Organization, acceptance.Role),
+		UserID:         acceptance.UserID,
+		Username:       acceptance.Username,
+		AccountCreated: acceptance.AccountCreated,
+	}
+}
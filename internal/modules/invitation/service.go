@@ -0,0 +1,375 @@
+package invitation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/jobs"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/mailer"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/organization"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/tenant"
+	"metalcore-api/internal/token"
+	"metalcore-api/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvitationExists   = errors.New("an open invitation for this email already exists")
+	ErrInvitationClosed   = errors.New("invitation has already been accepted or revoked")
+	ErrInvalidInvitation  = errors.New("invitation token is invalid or expired")
+	ErrAccountRequired    = errors.New("no account uses the invitation's email, account details are required")
+)
+
+// DefaultTTL is how long invitations are valid when created without an expiry
+func DefaultTTL() time.Duration {
+	return config.GetEnvDuration("INVITATION_TTL", 7*24*time.Hour)
+}
+
+// Acceptance is the membership created by accepting an invitation
+type Acceptance struct {
+	Organization   *organization.Organization
+	Role           string
+	UserID         int
+	Username       string
+	AccountCreated bool
+}
+
+type Service struct {
+	db       database.DBTX
+	repo     *InvitationRepository
+	orgs     *organization.OrganizationRepository
+	users    *user.UserRepository
+	accounts *user.Service
+	audit    *audit.Service
+	tokens   *token.Manager
+}
+
+func NewService(db database.DBTX, repo *InvitationRepository, orgs *organization.OrganizationRepository, users *user.UserRepository, accounts *user.Service, audit *audit.Service, tokens *token.Manager) *Service {
+	return &Service{db: db, repo: repo, orgs: orgs, users: users, accounts: accounts, audit: audit, tokens: tokens}
+}
+
+// Create invites the email to the tenant's organization and sends the invitation.
+// Only owners can invite owners, and members of the organization cannot be invited.
+func (s *Service) Create(ctx context.Context, t *tenant.Tenant, inviterID int, payload CreateInvitationRequest) (*Invitation, error) {
+	ctx, span := tracing.Start(ctx, "invitation.Service.Create")
+	defer span.End()
+
+	if payload.Role == organization.RoleOwner && t.Role != organization.RoleOwner {
+		return nil, organization.ErrOwnerRequired
+	}
+
+	ttl := DefaultTTL()
+	if payload.ExpiresInHours > 0 {
+		ttl = time.Duration(payload.ExpiresInHours) * time.Hour
+	}
+
+	// The invitee's account is found by the normalized email, so that it links however
+	// the address was typed
+	payload.Email = common.NormalizeEmail(payload.Email)
+
+	var invitation *Invitation
+	err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		existing, err := s.users.WithTx(tx).GetByEmail(ctx, payload.Email, 0)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if existing != nil {
+			_, err := s.orgs.WithTx(tx).GetMembership(ctx, t.ID, existing.UserID)
+			if err == nil {
+				return organization.ErrMemberExists
+			}
+			if !errors.Is(err, pgx.ErrNoRows) {
+				return err
+			}
+		}
+
+		invitation, err = s.repo.WithTx(tx).Create(ctx, &Invitation{
+			OrganizationID: t.ID,
+			Email:          payload.Email,
+			Role:           payload.Role,
+			InvitedBy:      &inviterID,
+			ExpiresAt:      time.Now().Add(ttl),
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.send(ctx, tx, invitation, ttl); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, audit.ActionInvitationCreate, audit.TargetInvitation, strconv.FormatInt(invitation.InvitationID, 10), nil, invitation)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrInvitationExists
+		}
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+func (s *Service) List(ctx context.Context, t *tenant.Tenant, page, pageSize int) ([]Invitation, int64, error) {
+	ctx, span := tracing.Start(ctx, "invitation.Service.List")
+	defer span.End()
+
+	offset := (page - 1) * pageSize
+	return s.repo.List(ctx, t.ID, offset, pageSize)
+}
+
+// Resend sends an open invitation again with a new token, valid for as long as the
+// previous one was. Links sent before stop working.
+func (s *Service) Resend(ctx context.Context, t *tenant.Tenant, invitationID int64) (*Invitation, error) {
+	ctx, span := tracing.Start(ctx, "invitation.Service.Resend")
+	defer span.End()
+
+	var invitation *Invitation
+	err := s.change(ctx, t, invitationID, func(tx pgx.Tx, before *Invitation) error {
+		ttl := DefaultTTL()
+		if before.SentAt != nil {
+			ttl = before.ExpiresAt.Sub(*before.SentAt)
+		}
+
+		after := *before
+		if err := s.send(ctx, tx, &after, ttl); err != nil {
+			return err
+		}
+		invitation = &after
+		return s.audit.Record(ctx, tx, audit.ActionInvitationResend, audit.TargetInvitation, strconv.FormatInt(invitationID, 10), before, invitation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// Revoke revokes an open invitation, its link stops working
+func (s *Service) Revoke(ctx context.Context, t *tenant.Tenant, invitationID int64) error {
+	ctx, span := tracing.Start(ctx, "invitation.Service.Revoke")
+	defer span.End()
+
+	return s.change(ctx, t, invitationID, func(tx pgx.Tx, before *Invitation) error {
+		after := *before
+		if err := s.repo.WithTx(tx).Revoke(ctx, &after); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, audit.ActionInvitationRevoke, audit.TargetInvitation, strconv.FormatInt(invitationID, 10), before, &after)
+	})
+}
+
+// change locks the invitation and runs change on it in a transaction when it is open
+func (s *Service) change(ctx context.Context, t *tenant.Tenant, invitationID int64, change func(tx pgx.Tx, before *Invitation) error) error {
+	return database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		before, err := s.repo.WithTx(tx).Lock(ctx, t.ID, invitationID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvitationNotFound
+			}
+			return err
+		}
+		if !before.Open() {
+			return ErrInvitationClosed
+		}
+		return change(tx, before)
+	})
+}
+
+// Accept adds the account registered with the invitation's email to the organization,
+// creating the account from payload.Account when there is none. The invitation's
+// token proves control of the email, so an existing account is linked without its
+// password.
+func (s *Service) Accept(ctx context.Context, payload AcceptInvitationRequest) (*Acceptance, error) {
+	ctx, span := tracing.Start(ctx, "invitation.Service.Accept")
+	defer span.End()
+
+	claims, err := s.tokens.ParseInvitation(payload.Token)
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+
+	var acceptance *Acceptance
+	err = database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		before, err := repo.Lock(ctx, claims.TenantID, claims.InvitationID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidInvitation
+			}
+			return err
+		}
+		if before.TokenHash == nil || *before.TokenHash != hashToken(payload.Token) || before.Status(time.Now()) != StatusPending {
+			return ErrInvalidInvitation
+		}
+
+		// Accounts are global, the invitee is looked up and created outside the
+		// organization's scope, which would hide users who are not members yet
+		if err := tenant.Scope(ctx, tx, 0, 0); err != nil {
+			return err
+		}
+
+		acceptance = &Acceptance{Role: before.Role}
+		member, err := s.users.WithTx(tx).GetByEmail(ctx, before.Email, 0)
+		switch {
+		case err == nil:
+		case errors.Is(err, pgx.ErrNoRows):
+			if payload.Account == nil {
+				return ErrAccountRequired
+			}
+			// Inactive and deleted accounts keep their email
+			exists, err := s.users.WithTx(tx).EmailExists(ctx, before.Email)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return user.ErrEmailExists
+			}
+
+			member, err = s.accounts.WithTx(tx).Create(ctx, user.CreateUserRequest{
+				Username:  payload.Account.Username,
+				FirstName: payload.Account.FirstName,
+				LastName:  payload.Account.LastName,
+				Email:     before.Email,
+				Phone:     payload.Account.Phone,
+				Password:  payload.Account.Password,
+			})
+			if err != nil {
+				return err
+			}
+			acceptance.AccountCreated = true
+		default:
+			return err
+		}
+		acceptance.UserID = member.UserID
+		acceptance.Username = member.Username
+
+		ctx := audit.WithActor(ctx, member.UserID)
+		membership, err := s.orgs.WithTx(tx).AddMember(ctx, before.OrganizationID, member.UserID, before.Role)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return organization.ErrMemberExists
+			}
+			return err
+		}
+		membership.Username = member.Username
+
+		after := *before
+		if err := repo.Accept(ctx, &after, member.UserID); err != nil {
+			return err
+		}
+
+		acceptance.Organization, err = s.orgs.WithTx(tx).Resolve(ctx, strconv.FormatInt(before.OrganizationID, 10))
+		if err != nil {
+			return err
+		}
+
+		if err := s.audit.Record(ctx, tx, audit.ActionOrganizationMemberAdd, audit.TargetOrganization, strconv.FormatInt(before.OrganizationID, 10), nil, membership); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, audit.ActionInvitationAccept, audit.TargetInvitation, strconv.FormatInt(before.InvitationID, 10), before, &after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return acceptance, nil
+}
+
+// send renews the invitation's expiry to ttl from now, invalidates its previous
+// token and queues its email within tx. The token is issued by the job, so that
+// it is never stored in the queue.
+func (s *Service) send(ctx context.Context, tx pgx.Tx, invitation *Invitation, ttl time.Duration) error {
+	if err := s.repo.WithTx(tx).Send(ctx, invitation, time.Now().Add(ttl)); err != nil {
+		return err
+	}
+
+	_, err := jobs.Enqueue(ctx, tx, SendInvitationArgs{
+		OrganizationID: invitation.OrganizationID,
+		InvitationID:   invitation.InvitationID,
+		SendCount:      invitation.SendCount,
+	}, jobs.WithPriority(10))
+	return err
+}
+
+// Deliver issues the token of a pending invitation and mails its link. It runs the
+// sending numbered sendCount, sendings superseded by a resend and invitations that
+// were accepted, revoked or expired in the meantime are skipped.
+func (s *Service) Deliver(ctx context.Context, m mailer.Mailer, organizationID, invitationID int64, sendCount int) error {
+	ctx, span := tracing.Start(ctx, "invitation.Service.Deliver")
+	defer span.End()
+
+	var msg *mailer.Message
+	err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		invitation, err := repo.Lock(ctx, organizationID, invitationID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		if invitation.SendCount != sendCount || invitation.Status(time.Now()) != StatusPending {
+			return nil
+		}
+
+		rawToken, err := s.tokens.IssueInvitation(invitation.InvitationID, invitation.OrganizationID, invitation.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if err := repo.IssueToken(ctx, invitation, hashToken(rawToken)); err != nil {
+			return err
+		}
+
+		org, err := s.orgs.WithTx(tx).Resolve(ctx, strconv.FormatInt(invitation.OrganizationID, 10))
+		if err != nil {
+			return err
+		}
+
+		ttl := invitation.ExpiresAt.Sub(*invitation.SentAt).Round(time.Minute)
+		acceptURL := fmt.Sprintf("%s/accept-invitation?token=%s", config.GetEnv("APP_BASE_URL", "http://localhost:8090"), rawToken)
+		msg = &mailer.Message{
+			To:      invitation.Email,
+			Subject: fmt.Sprintf("You have been invited to join %s", org.Name),
+			Body: fmt.Sprintf(
+				"Hi,\n\nYou have been invited to join %s as %s. Accept the invitation by opening the link below within %s:\n\n%s\n\nIf you were not expecting this invitation, you can ignore this email.\n",
+				org.Name, invitation.Role, ttl, acceptURL,
+			),
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		logging.FromContext(ctx).Info("invitation is no longer pending or was resent, not sending", "invitation_id", invitationID)
+		return nil
+	}
+
+	// The token is committed before the email goes out, a failed send is retried with a new one
+	return m.Send(ctx, *msg)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
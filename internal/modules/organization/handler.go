@@ -0,0 +1,281 @@
+package organization
+
+import (
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/middleware"
+	"metalcore-api/internal/tenant"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	var payload CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	org, err := h.service.Create(c.Request.Context(), principal.UserID, payload)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "organization has been created successfully.",
+		"data":    ToOrganizationResponse(org, RoleOwner),
+	})
+}
+
+// ListMine lists the organizations the caller is a member of
+func (h *Handler) ListMine(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	memberships, err := h.service.ListForUser(c.Request.Context(), principal.UserID)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	organizations := make([]*OrganizationResponse, len(memberships))
+	for i, membership := range memberships {
+		organizations[i] = ToOrganizationResponse(membership.Organization, membership.Role)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": organizations,
+	})
+}
+
+// GetCurrent returns the organization the request acts in
+func (h *Handler) GetCurrent(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+
+	org, err := h.service.Get(c.Request.Context(), t)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": ToOrganizationResponse(org, t.Role),
+	})
+}
+
+func (h *Handler) ListMembers(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+
+	var pagination common.PaginationRequest
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid pagination parameters",
+			Message: err.Error(),
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	memberships, total, err := h.service.ListMembers(c.Request.Context(), t, pagination.GetPage(), pagination.GetPageSize())
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, common.PaginatedResponse{
+		Data:       ToMemberListResponse(memberships),
+		Pagination: common.NewPaginationMetadata(&pagination, total),
+	})
+}
+
+func (h *Handler) AddMember(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+
+	var payload AddMemberRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	membership, err := h.service.AddMember(c.Request.Context(), t, payload)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "member has been added successfully.",
+		"data":    ToMemberResponse(membership),
+	})
+}
+
+func (h *Handler) UpdateMember(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var payload UpdateMemberRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	membership, err := h.service.UpdateMember(c.Request.Context(), t, userID, payload)
+	if err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "member has been updated successfully.",
+		"data":    ToMemberResponse(membership),
+	})
+}
+
+func (h *Handler) RemoveMember(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveMember(c.Request.Context(), t, userID); err != nil {
+		h.organizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "member has been removed.",
+	})
+}
+
+func (h *Handler) organizationError(c *gin.Context, err error) {
+	switch err {
+	case tenant.ErrNotFound:
+		c.JSON(http.StatusNotFound, common.ErrorResponse{
+			Status: http.StatusNotFound,
+			Error:  "Organization not found",
+		})
+	case ErrSlugExists:
+		c.JSON(http.StatusConflict, common.ErrorResponse{
+			Status:  http.StatusConflict,
+			Error:   "Slug already exists",
+			Message: "Please choose a different slug",
+		})
+	case ErrUserNotFound:
+		c.JSON(http.StatusNotFound, common.ErrorResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		})
+	case ErrMemberNotFound:
+		c.JSON(http.StatusNotFound, common.ErrorResponse{
+			Status: http.StatusNotFound,
+			Error:  "Member not found",
+		})
+	case ErrMemberExists:
+		c.JSON(http.StatusConflict, common.ErrorResponse{
+			Status: http.StatusConflict,
+			Error:  "User is already a member",
+		})
+	case ErrOwnerRequired:
+		c.JSON(http.StatusForbidden, common.ErrorResponse{
+			Status:  http.StatusForbidden,
+			Error:   "Forbidden",
+			Message: err.Error(),
+		})
+	case ErrLastOwner:
+		c.JSON(http.StatusConflict, common.ErrorResponse{
+			Status:  http.StatusConflict,
+			Error:   "Last owner",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+			Status:  http.StatusInternalServerError,
+			Error:   "Internal server error",
+			Message: "An unexpected error occurred",
+		})
+	}
+}
+
+// currentTenant returns the tenant set by middleware.RequireTenant
+func currentTenant(c *gin.Context) (*tenant.Tenant, bool) {
+	t, ok := tenant.FromContext(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Organization required",
+		})
+	}
+	return t, ok
+}
+
+func userIDParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID < 1 {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user ID",
+		})
+		return 0, false
+	}
+	return userID, true
+}
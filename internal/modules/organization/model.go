@@ -0,0 +1,29 @@
+package organization
+
+import "time"
+
+// Roles of a member within an organization
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+type Organization struct {
+	OrganizationID int64      `db:"OrganizationId" json:"organization_id"`
+	Slug           string     `db:"Slug" json:"slug"`
+	Name           string     `db:"Name" json:"name"`
+	CreatedAt      time.Time  `db:"CreatedAt" json:"created_at"`
+	UpdatedAt      *time.Time `db:"UpdatedAt" json:"updated_at,omitempty"`
+}
+
+// Membership is a user's role in an organization, Organization is set when listing a user's organizations
+type Membership struct {
+	OrganizationID int64         `db:"OrganizationId" json:"organization_id"`
+	UserID         int           `db:"UserId" json:"user_id"`
+	Username       string        `db:"Username" json:"username"`
+	Role           string        `db:"Role" json:"role"`
+	CreatedAt      time.Time     `db:"CreatedAt" json:"created_at"`
+	UpdatedAt      *time.Time    `db:"UpdatedAt" json:"updated_at,omitempty"`
+	Organization   *Organization `db:"-" json:"-"`
+}
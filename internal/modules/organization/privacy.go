@@ -0,0 +1,39 @@
+package organization
+
+import (
+	"context"
+
+	"metalcore-api/internal/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Exporter contributes the user's organizations and roles to personal data exports,
+// erasing the user removes them from their organizations
+type Exporter struct {
+	repo *OrganizationRepository
+}
+
+func NewExporter(db database.DBTX) *Exporter {
+	return &Exporter{repo: NewOrganizationRepository(db)}
+}
+
+func (e *Exporter) Name() string { return "organizations" }
+
+func (e *Exporter) Export(ctx context.Context, userID int) (any, error) {
+	memberships, err := e.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	organizations := make([]*OrganizationResponse, len(memberships))
+	for i, membership := range memberships {
+		organizations[i] = ToOrganizationResponse(membership.Organization, membership.Role)
+	}
+	return organizations, nil
+}
+
+func (e *Exporter) Erase(ctx context.Context, tx pgx.Tx, userIDs []int) error {
+	_, err := e.repo.WithTx(tx).RemoveUsers(ctx, userIDs)
+	return err
+}
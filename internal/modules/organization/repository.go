@@ -0,0 +1,425 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/tenant"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// OrganizationRepository stores organizations and their memberships. Every query on
+// memberships runs scoped to a tenant (or user) so that the row-level security
+// policy of the table applies on top of the tenant conditions in the queries.
+type OrganizationRepository struct {
+	db database.DBTX
+}
+
+func NewOrganizationRepository(db database.DBTX) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// WithTx returns a repository that runs its queries in tx
+func (r *OrganizationRepository) WithTx(tx pgx.Tx) *OrganizationRepository {
+	return &OrganizationRepository{db: tx}
+}
+
+// scoped runs fn in a transaction, or a savepoint of the current one, in which
+// row-level security limits memberships to tenantID and userID
+func (r *OrganizationRepository) scoped(ctx context.Context, tenantID int64, userID int, fn func(tx pgx.Tx) error) error {
+	return database.WithTx(ctx, r.db, func(tx pgx.Tx) error {
+		if err := tenant.Scope(ctx, tx, tenantID, userID); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+const membershipColumns = `
+			m."OrganizationId",
+			m."UserId",
+			u."Username",
+			m."Role",
+			m."CreatedAt",
+			m."UpdatedAt"`
+
+func scanMembership(row pgx.Row, membership *Membership) error {
+	return row.Scan(
+		&membership.OrganizationID,
+		&membership.UserID,
+		&membership.Username,
+		&membership.Role,
+		&membership.CreatedAt,
+		&membership.UpdatedAt,
+	)
+}
+
+// Create inserts the organization with ownerID as its first owner
+func (r *OrganizationRepository) Create(ctx context.Context, org *Organization, ownerID int) (*Organization, error) {
+	err := database.WithTx(ctx, r.db, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO public."Organization" (
+				"Slug",
+				"Name"
+			)
+			VALUES ($1, $2)
+			RETURNING
+				"OrganizationId",
+				"CreatedAt"
+		`
+
+		err := tx.QueryRow(ctx, query, org.Slug, org.Name).Scan(&org.OrganizationID, &org.CreatedAt)
+		if err != nil {
+			logging.FromContext(ctx).Error("error while creating organization", "error", err)
+			return err
+		}
+
+		if err := tenant.Scope(ctx, tx, org.OrganizationID, ownerID); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO public."Membership" (
+				"OrganizationId",
+				"UserId",
+				"Role"
+			)
+			VALUES ($1, $2, $3)
+		`, org.OrganizationID, ownerID, RoleOwner)
+		if err != nil {
+			logging.FromContext(ctx).Error("error while adding organization owner", "error", err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// Resolve finds an organization by numeric ID or slug
+func (r *OrganizationRepository) Resolve(ctx context.Context, key string) (*Organization, error) {
+	query := `
+		SELECT
+			"OrganizationId",
+			"Slug",
+			"Name",
+			"CreatedAt",
+			"UpdatedAt"
+		FROM public."Organization"
+		WHERE "Slug" = $1
+	`
+	args := []any{key}
+	if id, err := strconv.ParseInt(key, 10, 64); err == nil {
+		query = `
+			SELECT
+				"OrganizationId",
+				"Slug",
+				"Name",
+				"CreatedAt",
+				"UpdatedAt"
+			FROM public."Organization"
+			WHERE "OrganizationId" = $1
+		`
+		args = []any{id}
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in Resolve", "error", err)
+		return nil, err
+	}
+
+	org, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Organization])
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("error scanning organization row", "error", err)
+		}
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// GetMembership returns the membership of the user in the organization
+func (r *OrganizationRepository) GetMembership(ctx context.Context, tenantID int64, userID int) (*Membership, error) {
+	return r.membership(ctx, tenantID, userID, "")
+}
+
+// LockMembership returns the membership of the user in the organization and locks
+// it until the end of the transaction
+func (r *OrganizationRepository) LockMembership(ctx context.Context, tenantID int64, userID int) (*Membership, error) {
+	return r.membership(ctx, tenantID, userID, `FOR UPDATE OF m`)
+}
+
+func (r *OrganizationRepository) membership(ctx context.Context, tenantID int64, userID int, lock string) (*Membership, error) {
+	var membership Membership
+	err := r.scoped(ctx, tenantID, userID, func(tx pgx.Tx) error {
+		query := `
+			SELECT` + membershipColumns + `
+			FROM public."Membership" m
+			JOIN public."User" u ON u."UserId" = m."UserId"
+			WHERE m."OrganizationId" = $1
+			  AND m."UserId" = $2
+			` + lock
+
+		return scanMembership(tx.QueryRow(ctx, query, tenantID, userID), &membership)
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("database error in GetMembership", "error", err)
+		}
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// ListForUser returns the user's memberships with their organizations, by organization name
+func (r *OrganizationRepository) ListForUser(ctx context.Context, userID int) ([]Membership, error) {
+	var memberships []Membership
+	err := r.scoped(ctx, 0, userID, func(tx pgx.Tx) error {
+		query := `
+			SELECT` + membershipColumns + `,
+				o."Slug",
+				o."Name",
+				o."CreatedAt",
+				o."UpdatedAt"
+			FROM public."Membership" m
+			JOIN public."User" u ON u."UserId" = m."UserId"
+			JOIN public."Organization" o ON o."OrganizationId" = m."OrganizationId"
+			WHERE m."UserId" = $1
+			ORDER BY o."Name", o."OrganizationId"
+		`
+
+		rows, err := tx.Query(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var membership Membership
+			org := &Organization{}
+
+			err := rows.Scan(
+				&membership.OrganizationID,
+				&membership.UserID,
+				&membership.Username,
+				&membership.Role,
+				&membership.CreatedAt,
+				&membership.UpdatedAt,
+				&org.Slug,
+				&org.Name,
+				&org.CreatedAt,
+				&org.UpdatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			org.OrganizationID = membership.OrganizationID
+			membership.Organization = org
+			memberships = append(memberships, membership)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ListForUser", "error", err)
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+// ListMembers returns a page of the organization's members, oldest first
+func (r *OrganizationRepository) ListMembers(ctx context.Context, tenantID int64, offset, limit int) ([]Membership, int64, error) {
+	var memberships []Membership
+	var totalCount int64
+	err := r.scoped(ctx, tenantID, 0, func(tx pgx.Tx) error {
+		countQuery := `
+			SELECT COUNT(*)
+			FROM public."Membership"
+			WHERE "OrganizationId" = $1
+		`
+
+		if err := tx.QueryRow(ctx, countQuery, tenantID).Scan(&totalCount); err != nil {
+			return err
+		}
+
+		query := `
+			SELECT` + membershipColumns + `
+			FROM public."Membership" m
+			JOIN public."User" u ON u."UserId" = m."UserId"
+			WHERE m."OrganizationId" = $1
+			ORDER BY m."CreatedAt", m."UserId"
+			LIMIT $2 OFFSET $3
+		`
+
+		rows, err := tx.Query(ctx, query, tenantID, limit, offset)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var membership Membership
+			if err := scanMembership(rows, &membership); err != nil {
+				return err
+			}
+			memberships = append(memberships, membership)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ListMembers", "error", err)
+		return nil, 0, err
+	}
+
+	return memberships, totalCount, nil
+}
+
+// LockOwners returns the user IDs of the organization's owners and locks their
+// memberships, so that concurrent changes cannot remove the last owner
+func (r *OrganizationRepository) LockOwners(ctx context.Context, tenantID int64) ([]int, error) {
+	var owners []int
+	err := r.scoped(ctx, tenantID, 0, func(tx pgx.Tx) error {
+		query := `
+			SELECT "UserId"
+			FROM public."Membership"
+			WHERE "OrganizationId" = $1
+			  AND "Role" = $2
+			ORDER BY "UserId"
+			FOR UPDATE
+		`
+
+		rows, err := tx.Query(ctx, query, tenantID, RoleOwner)
+		if err != nil {
+			return err
+		}
+		owners, err = pgx.CollectRows(rows, pgx.RowTo[int])
+		return err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in LockOwners", "error", err)
+		return nil, err
+	}
+
+	return owners, nil
+}
+
+// AddMember inserts a membership, the returned membership lacks the username
+func (r *OrganizationRepository) AddMember(ctx context.Context, tenantID int64, userID int, role string) (*Membership, error) {
+	membership := Membership{OrganizationID: tenantID, UserID: userID, Role: role}
+	err := r.scoped(ctx, tenantID, 0, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO public."Membership" (
+				"OrganizationId",
+				"UserId",
+				"Role"
+			)
+			VALUES ($1, $2, $3)
+			RETURNING "CreatedAt"
+		`
+
+		return tx.QueryRow(ctx, query, tenantID, userID, role).Scan(&membership.CreatedAt)
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("error while adding organization member", "error", err)
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// UpdateRole changes the role of a member
+func (r *OrganizationRepository) UpdateRole(ctx context.Context, tenantID int64, userID int, role string) error {
+	return r.scoped(ctx, tenantID, 0, func(tx pgx.Tx) error {
+		query := `
+			UPDATE public."Membership"
+			SET "Role" = $3,
+				"UpdatedAt" = NOW()
+			WHERE "OrganizationId" = $1
+			  AND "UserId" = $2
+		`
+
+		tag, err := tx.Exec(ctx, query, tenantID, userID, role)
+		if err != nil {
+			logging.FromContext(ctx).Error("error while updating member role", "error", err)
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// RemoveMember deletes the membership of a user
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, tenantID int64, userID int) error {
+	return r.scoped(ctx, tenantID, 0, func(tx pgx.Tx) error {
+		query := `
+			DELETE FROM public."Membership"
+			WHERE "OrganizationId" = $1
+			  AND "UserId" = $2
+		`
+
+		tag, err := tx.Exec(ctx, query, tenantID, userID)
+		if err != nil {
+			logging.FromContext(ctx).Error("error while removing organization member", "error", err)
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// RemoveUsers deletes the memberships of the users in every organization and
+// returns how many were deleted
+func (r *OrganizationRepository) RemoveUsers(ctx context.Context, userIDs []int) (int64, error) {
+	var deleted int64
+	for _, userID := range userIDs {
+		err := r.scoped(ctx, 0, userID, func(tx pgx.Tx) error {
+			tag, err := tx.Exec(ctx, `
+				DELETE FROM public."Membership"
+				WHERE "UserId" = $1
+			`, userID)
+			deleted += tag.RowsAffected()
+			return err
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("error while removing user from organizations", "error", err)
+			return 0, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// IDs returns the IDs of every organization, in order
+func (r *OrganizationRepository) IDs(ctx context.Context) ([]int64, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT "OrganizationId"
+		FROM public."Organization"
+		ORDER BY "OrganizationId"
+	`)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in IDs", "error", err)
+		return nil, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		logging.FromContext(ctx).Error("error scanning organization rows", "error", err)
+		return nil, err
+	}
+	return ids, nil
+}
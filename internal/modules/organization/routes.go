@@ -0,0 +1,41 @@
+package organization
+
+import (
+	"metalcore-api/internal/middleware"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/pii"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func RegisterRoutes(rg *gin.RouterGroup, db *pgxpool.Pool, cipher *pii.Cipher, validate, idempotent, requireAuth, requireTenant gin.HandlerFunc) {
+	// Initialize dependencies (Dependency Injection)
+	repo := NewOrganizationRepository(db)
+	auditService := audit.NewService(audit.NewAuditRepository(db))
+	service := NewService(db, repo, user.NewUserRepository(db, cipher), auditService)
+	handler := NewHandler(service)
+
+	// Organizations of the authenticated user
+	organizationGroup := rg.Group("/organizations", requireAuth, validate)
+	{
+		organizationGroup.POST("", idempotent, handler.Create)
+		organizationGroup.GET("", handler.ListMine)
+	}
+
+	// The organization the request acts in, see middleware.RequireTenant
+	currentGroup := rg.Group("/org", requireAuth, requireTenant)
+	{
+		currentGroup.GET("", handler.GetCurrent)
+		currentGroup.GET("/members", handler.ListMembers)
+	}
+
+	// Membership management, owners and admins only
+	adminGroup := currentGroup.Group("", middleware.RequireTenantRole(RoleOwner, RoleAdmin), validate)
+	{
+		adminGroup.POST("/members", idempotent, handler.AddMember)
+		adminGroup.PATCH("/members/:id", handler.UpdateMember)
+		adminGroup.DELETE("/members/:id", handler.RemoveMember)
+	}
+}
@@ -0,0 +1,77 @@
+package organization
+
+import "time"
+
+// CreateOrganizationRequest represents the HTTP request structure for creating an organization
+type CreateOrganizationRequest struct {
+	Slug string `json:"slug" binding:"required,min=3,max=63,slug"` // Names the organization in subdomains and the tenant header
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// AddMemberRequest represents the HTTP request structure for adding an existing user to the organization
+type AddMemberRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// UpdateMemberRequest represents the HTTP request structure for changing a member's role
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// OrganizationResponse represents an organization with the caller's role in it
+type OrganizationResponse struct {
+	OrganizationID int64      `json:"organization_id"`
+	Slug           string     `json:"slug"`
+	Name           string     `json:"name"`
+	Role           string     `json:"role"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+// MemberResponse represents a member of an organization
+type MemberResponse struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// ToOrganizationResponse converts an organization and the caller's role to the response schema
+func ToOrganizationResponse(org *Organization, role string) *OrganizationResponse {
+	if org == nil {
+		return nil
+	}
+
+	return &OrganizationResponse{
+		OrganizationID: org.OrganizationID,
+		Slug:           org.Slug,
+		Name:           org.Name,
+		Role:           role,
+		CreatedAt:      org.CreatedAt,
+		UpdatedAt:      org.UpdatedAt,
+	}
+}
+
+// ToMemberResponse converts a membership to the response schema
+func ToMemberResponse(membership *Membership) *MemberResponse {
+	if membership == nil {
+		return nil
+	}
+
+	return &MemberResponse{
+		UserID:   membership.UserID,
+		Username: membership.Username,
+		Role:     membership.Role,
+		JoinedAt: membership.CreatedAt,
+	}
+}
+
+// ToMemberListResponse converts memberships to the response schema
+func ToMemberListResponse(memberships []Membership) []*MemberResponse {
+	members := make([]*MemberResponse, len(memberships))
+	for i := range memberships {
+		members[i] = ToMemberResponse(&memberships[i])
+	}
+	return members
+}
@@ -0,0 +1,240 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/tenant"
+	"metalcore-api/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrSlugExists     = errors.New("organization slug already exists")
+	ErrMemberNotFound = errors.New("member not found")
+	ErrMemberExists   = errors.New("user is already a member")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrOwnerRequired  = errors.New("only owners can grant or revoke the owner role")
+	ErrLastOwner      = errors.New("an organization must keep at least one owner")
+)
+
+type Service struct {
+	db    database.DBTX
+	repo  *OrganizationRepository
+	users *user.UserRepository
+	audit *audit.Service
+}
+
+func NewService(db database.DBTX, repo *OrganizationRepository, users *user.UserRepository, audit *audit.Service) *Service {
+	return &Service{db: db, repo: repo, users: users, audit: audit}
+}
+
+// Create creates an organization with the user as its owner
+func (s *Service) Create(ctx context.Context, userID int, payload CreateOrganizationRequest) (*Organization, error) {
+	ctx, span := tracing.Start(ctx, "organization.Service.Create")
+	defer span.End()
+
+	var org *Organization
+	err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		var err error
+		org, err = s.repo.WithTx(tx).Create(ctx, &Organization{Slug: payload.Slug, Name: payload.Name}, userID)
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, audit.ActionOrganizationCreate, audit.TargetOrganization, strconv.FormatInt(org.OrganizationID, 10), nil, org)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrSlugExists
+		}
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// ListForUser returns the user's memberships with their organizations
+func (s *Service) ListForUser(ctx context.Context, userID int) ([]Membership, error) {
+	ctx, span := tracing.Start(ctx, "organization.Service.ListForUser")
+	defer span.End()
+
+	return s.repo.ListForUser(ctx, userID)
+}
+
+// ResolveTenant finds the organization named by key, a slug or an ID, in which the
+// user is a member. tenant.ErrNotFound hides whether the organization exists.
+func (s *Service) ResolveTenant(ctx context.Context, key string, userID int) (*tenant.Tenant, error) {
+	ctx, span := tracing.Start(ctx, "organization.Service.ResolveTenant")
+	defer span.End()
+
+	org, err := s.repo.Resolve(ctx, key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, tenant.ErrNotFound
+		}
+		return nil, err
+	}
+
+	membership, err := s.repo.GetMembership(ctx, org.OrganizationID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, tenant.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &tenant.Tenant{ID: org.OrganizationID, Slug: org.Slug, Role: membership.Role}, nil
+}
+
+// Get returns the organization of the tenant
+func (s *Service) Get(ctx context.Context, t *tenant.Tenant) (*Organization, error) {
+	ctx, span := tracing.Start(ctx, "organization.Service.Get")
+	defer span.End()
+
+	org, err := s.repo.Resolve(ctx, strconv.FormatInt(t.ID, 10))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, tenant.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return org, nil
+}
+
+func (s *Service) ListMembers(ctx context.Context, t *tenant.Tenant, page, pageSize int) ([]Membership, int64, error) {
+	ctx, span := tracing.Start(ctx, "organization.Service.ListMembers")
+	defer span.End()
+
+	offset := (page - 1) * pageSize
+	return s.repo.ListMembers(ctx, t.ID, offset, pageSize)
+}
+
+// AddMember adds an existing user to the tenant's organization. Only owners can add owners.
+func (s *Service) AddMember(ctx context.Context, t *tenant.Tenant, payload AddMemberRequest) (*Membership, error) {
+	ctx, span := tracing.Start(ctx, "organization.Service.AddMember")
+	defer span.End()
+
+	if payload.Role == RoleOwner && t.Role != RoleOwner {
+		return nil, ErrOwnerRequired
+	}
+
+	u, err := s.users.GetByUsername(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	var membership *Membership
+	err = database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		membership, err = s.repo.WithTx(tx).AddMember(ctx, t.ID, u.UserID, payload.Role)
+		if err != nil {
+			return err
+		}
+		membership.Username = u.Username
+		return s.audit.Record(ctx, tx, audit.ActionOrganizationMemberAdd, audit.TargetOrganization, strconv.FormatInt(t.ID, 10), nil, membership)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrMemberExists
+		}
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// UpdateMember changes a member's role. Only owners can grant or revoke the owner
+// role, and the last owner cannot be demoted.
+func (s *Service) UpdateMember(ctx context.Context, t *tenant.Tenant, userID int, payload UpdateMemberRequest) (*Membership, error) {
+	ctx, span := tracing.Start(ctx, "organization.Service.UpdateMember")
+	defer span.End()
+
+	var after Membership
+	err := s.changeMember(ctx, t, userID, func(tx pgx.Tx, before *Membership) error {
+		if (before.Role == RoleOwner || payload.Role == RoleOwner) && t.Role != RoleOwner {
+			return ErrOwnerRequired
+		}
+		if before.Role == RoleOwner && payload.Role != RoleOwner {
+			if err := s.keepOwner(ctx, tx, t, userID); err != nil {
+				return err
+			}
+		}
+
+		if err := s.repo.WithTx(tx).UpdateRole(ctx, t.ID, userID, payload.Role); err != nil {
+			return err
+		}
+		after = *before
+		after.Role = payload.Role
+		return s.audit.Record(ctx, tx, audit.ActionOrganizationMemberUpdate, audit.TargetOrganization, strconv.FormatInt(t.ID, 10), before, &after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &after, nil
+}
+
+// RemoveMember removes a user from the organization. Only owners can remove owners,
+// and the last owner cannot be removed.
+func (s *Service) RemoveMember(ctx context.Context, t *tenant.Tenant, userID int) error {
+	ctx, span := tracing.Start(ctx, "organization.Service.RemoveMember")
+	defer span.End()
+
+	return s.changeMember(ctx, t, userID, func(tx pgx.Tx, before *Membership) error {
+		if before.Role == RoleOwner {
+			if t.Role != RoleOwner {
+				return ErrOwnerRequired
+			}
+			if err := s.keepOwner(ctx, tx, t, userID); err != nil {
+				return err
+			}
+		}
+
+		if err := s.repo.WithTx(tx).RemoveMember(ctx, t.ID, userID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, audit.ActionOrganizationMemberRemove, audit.TargetOrganization, strconv.FormatInt(t.ID, 10), before, nil)
+	})
+}
+
+// changeMember locks the membership and runs change on it in a transaction
+func (s *Service) changeMember(ctx context.Context, t *tenant.Tenant, userID int, change func(tx pgx.Tx, before *Membership) error) error {
+	err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		before, err := s.repo.WithTx(tx).LockMembership(ctx, t.ID, userID)
+		if err != nil {
+			return err
+		}
+		return change(tx, before)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrMemberNotFound
+	}
+	return err
+}
+
+// keepOwner fails with ErrLastOwner when userID is the organization's only owner
+func (s *Service) keepOwner(ctx context.Context, tx pgx.Tx, t *tenant.Tenant, userID int) error {
+	owners, err := s.repo.WithTx(tx).LockOwners(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	if len(owners) == 1 && slices.Contains(owners, userID) {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
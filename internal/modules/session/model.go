@@ -0,0 +1,13 @@
+package session
+
+import "time"
+
+type Session struct {
+	SessionID int64      `db:"SessionId" json:"session_id"`
+	UserID    int        `db:"UserId" json:"user_id"`
+	UserAgent *string    `db:"UserAgent" json:"user_agent,omitempty"`
+	IPAddress *string    `db:"IpAddress" json:"ip_address,omitempty"`
+	CreatedAt time.Time  `db:"CreatedAt" json:"created_at"`
+	ExpiresAt time.Time  `db:"ExpiresAt" json:"expires_at"`
+	RevokedAt *time.Time `db:"RevokedAt" json:"revoked_at,omitempty"`
+}
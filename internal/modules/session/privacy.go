@@ -0,0 +1,29 @@
+package session
+
+import (
+	"context"
+
+	"metalcore-api/internal/database"
+)
+
+// Exporter contributes the user's sessions to personal data exports
+type Exporter struct {
+	repo *SessionRepository
+}
+
+func NewExporter(db database.DBTX) *Exporter {
+	return &Exporter{repo: NewSessionRepository(db)}
+}
+
+func (e *Exporter) Name() string { return "sessions" }
+
+func (e *Exporter) Export(ctx context.Context, userID int) (any, error) {
+	sessions, err := e.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []Session{}
+	}
+	return sessions, nil
+}
@@ -0,0 +1,203 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type SessionRepository struct {
+	db database.DBTX
+}
+
+func NewSessionRepository(db database.DBTX) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// WithTx returns a repository that runs its queries in tx
+func (r *SessionRepository) WithTx(tx pgx.Tx) *SessionRepository {
+	return &SessionRepository{db: tx}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *Session) (*Session, error) {
+	query := `
+		INSERT INTO public."UserSession" (
+			"UserId",
+			"UserAgent",
+			"IpAddress",
+			"ExpiresAt"
+		)
+		VALUES ($1, $2, $3, $4)
+		RETURNING
+			"SessionId",
+			"CreatedAt"
+	`
+
+	err := r.db.QueryRow(
+		ctx,
+		query,
+		session.UserID,
+		session.UserAgent,
+		session.IPAddress,
+		session.ExpiresAt,
+	).Scan(
+		&session.SessionID,
+		&session.CreatedAt,
+	)
+
+	if err != nil {
+		logging.FromContext(ctx).Error("error while creating session", "error", err)
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// IsActive reports whether the session exists, belongs to the user, is not revoked and has not expired
+func (r *SessionRepository) IsActive(ctx context.Context, sessionID int64, userID int) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1
+			FROM public."UserSession"
+			WHERE "SessionId" = $1
+			  AND "UserId" = $2
+			  AND "RevokedAt" IS NULL
+			  AND "ExpiresAt" > NOW()
+		)
+	`
+	var active bool
+
+	err := r.db.QueryRow(ctx, query, sessionID, userID).Scan(&active)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		logging.FromContext(ctx).Error("error while checking session", "error", err)
+		return false, err
+	}
+
+	return active, nil
+}
+
+// RevokeAllExcept revokes every active session of the user other than keepSessionID
+func (r *SessionRepository) RevokeAllExcept(ctx context.Context, userID int, keepSessionID int64) (int64, error) {
+	query := `
+		UPDATE public."UserSession"
+		SET "RevokedAt" = NOW()
+		WHERE "UserId" = $1
+		  AND "SessionId" <> $2
+		  AND "RevokedAt" IS NULL
+	`
+
+	tag, err := r.db.Exec(ctx, query, userID, keepSessionID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while revoking sessions", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// RevokeAll revokes every active session of the user
+func (r *SessionRepository) RevokeAll(ctx context.Context, userID int) (int64, error) {
+	query := `
+		UPDATE public."UserSession"
+		SET "RevokedAt" = NOW()
+		WHERE "UserId" = $1
+		  AND "RevokedAt" IS NULL
+	`
+
+	tag, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while revoking sessions", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteForUsers deletes every session of the users, used before they are purged or anonymized
+func (r *SessionRepository) DeleteForUsers(ctx context.Context, userIDs []int) (int64, error) {
+	query := `
+		DELETE FROM public."UserSession"
+		WHERE "UserId" = ANY($1)
+	`
+
+	tag, err := r.db.Exec(ctx, query, userIDs)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting sessions", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteExpired deletes up to limit sessions that expired or were revoked before
+// the cutoff. With dryRun they are only counted, without limit.
+func (r *SessionRepository) DeleteExpired(ctx context.Context, before time.Time, limit int, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := r.db.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM public."UserSession"
+			WHERE "ExpiresAt" < $1
+			   OR "RevokedAt" < $1
+		`, before).Scan(&count)
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in DeleteExpired (count)", "error", err)
+		}
+		return count, err
+	}
+
+	query := `
+		DELETE FROM public."UserSession"
+		WHERE "SessionId" IN (
+			SELECT "SessionId"
+			FROM public."UserSession"
+			WHERE "ExpiresAt" < $1
+			   OR "RevokedAt" < $1
+			LIMIT $2
+		)
+	`
+
+	tag, err := r.db.Exec(ctx, query, before, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting expired sessions", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ListForUser returns every session of the user, newest first
+func (r *SessionRepository) ListForUser(ctx context.Context, userID int) ([]Session, error) {
+	query := `
+		SELECT
+			"SessionId",
+			"UserId",
+			"UserAgent",
+			"IpAddress",
+			"CreatedAt",
+			"ExpiresAt",
+			"RevokedAt"
+		FROM public."UserSession"
+		WHERE "UserId" = $1
+		ORDER BY "CreatedAt" DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ListForUser", "error", err)
+		return nil, err
+	}
+
+	sessions, err := pgx.CollectRows(rows, pgx.RowToStructByName[Session])
+	if err != nil {
+		logging.FromContext(ctx).Error("error scanning session rows", "error", err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,249 @@
+package user
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of user imports and exports
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Import errors that reject the whole file rather than a row
+var (
+	ErrImportEmpty       = errors.New("import contains no rows")
+	ErrImportTooManyRows = errors.New("import contains too many rows")
+	ErrImportHeader      = errors.New("invalid CSV header")
+)
+
+// csvColumns are the CSV columns of an import, in the order of exports. Username,
+// email, phone and password are required, the names may be left out.
+var csvColumns = []string{"username", "first_name", "last_name", "email", "phone", "password"}
+
+// ImportRow is one user of an import file, Err is set when the row cannot be imported
+type ImportRow struct {
+	Line    int
+	Request CreateUserRequest
+	Err     error
+}
+
+// ImportRowError reports why a row was not imported, Errors is keyed by field
+type ImportRowError struct {
+	Line     int               `json:"line"`
+	Username string            `json:"username,omitempty"`
+	Errors   map[string]string `json:"errors"`
+}
+
+// DecodeImport reads the users of a CSV or NDJSON import. Rows that cannot be
+// decoded are returned with Err set, errors of the file as a whole end decoding.
+func DecodeImport(r io.Reader, format string, maxRows int) ([]ImportRow, error) {
+	var rows []ImportRow
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = decodeCSV(r, maxRows)
+	case FormatNDJSON:
+		rows, err = decodeNDJSON(r, maxRows)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrImportEmpty
+	}
+	return rows, nil
+}
+
+func decodeCSV(r io.Reader, maxRows int) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrImportEmpty
+		}
+		return nil, fmt.Errorf("%w: %v", ErrImportHeader, err)
+	}
+
+	known := make(map[string]bool, len(csvColumns))
+	for _, column := range csvColumns {
+		known[column] = true
+	}
+	index := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !known[column] {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrImportHeader, column)
+		}
+		if _, ok := index[column]; ok {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrImportHeader, column)
+		}
+		index[column] = i
+	}
+	for _, column := range []string{"username", "email", "phone", "password"} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrImportHeader, column)
+		}
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if len(rows) == maxRows {
+			return nil, ErrImportTooManyRows
+		}
+
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return nil, err
+		}
+		if parseErr != nil {
+			rows = append(rows, ImportRow{Line: parseErr.StartLine, Err: parseErr.Err})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			rows = append(rows, ImportRow{Line: line, Err: csv.ErrFieldCount})
+			continue
+		}
+
+		value := func(column string) string {
+			if i, ok := index[column]; ok {
+				return record[i]
+			}
+			return ""
+		}
+		optional := func(column string) *string {
+			if v := value(column); v != "" {
+				return &v
+			}
+			return nil
+		}
+
+		rows = append(rows, ImportRow{
+			Line: line,
+			Request: CreateUserRequest{
+				Username:  value("username"),
+				FirstName: optional("first_name"),
+				LastName:  optional("last_name"),
+				Email:     value("email"),
+				Phone:     optional("phone"),
+				Password:  value("password"),
+			},
+		})
+	}
+}
+
+func decodeNDJSON(r io.Reader, maxRows int) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var rows []ImportRow
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if len(rows) == maxRows {
+			return nil, ErrImportTooManyRows
+		}
+
+		row := ImportRow{Line: line}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		row.Err = decoder.Decode(&row.Request)
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// ExportWriter writes users in an export format
+type ExportWriter interface {
+	Write(user *User) error
+	Flush() error
+}
+
+// NewExportWriter returns a writer of format to w. CSV exports start with a header row.
+func NewExportWriter(w io.Writer, format string) (ExportWriter, error) {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		header := append([]string{"user_id"}, csvColumns[:len(csvColumns)-1]...)
+		header = append(header, "role", "active", "created_at", "updated_at")
+		if err := writer.Write(header); err != nil {
+			return nil, err
+		}
+		return &csvExportWriter{writer: writer}, nil
+	case FormatNDJSON:
+		buffered := bufio.NewWriter(w)
+		return &ndjsonExportWriter{buffered: buffered, encoder: json.NewEncoder(buffered)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvExportWriter) Write(user *User) error {
+	updatedAt := ""
+	if user.UpdatedAt != nil {
+		updatedAt = user.UpdatedAt.Format(time.RFC3339)
+	}
+
+	return w.writer.Write([]string{
+		strconv.Itoa(user.UserID),
+		user.Username,
+		deref(user.FirstName),
+		deref(user.LastName),
+		user.Email,
+		deref(user.Phone),
+		user.Role,
+		strconv.FormatBool(user.Active),
+		user.CreatedAt.Format(time.RFC3339),
+		updatedAt,
+	})
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonExportWriter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (w *ndjsonExportWriter) Write(user *User) error {
+	return w.encoder.Encode(ToUserResponse(user))
+}
+
+func (w *ndjsonExportWriter) Flush() error {
+	return w.buffered.Flush()
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,99 @@
+package user
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/events"
+)
+
+// UserChangedEvent is the payload of events.UserCreated and events.UserUpdated.
+// Events carry no personal data: consumers needing a user's details fetch them
+// from the API, which also keeps them from outliving an erasure.
+type UserChangedEvent struct {
+	UserID  int      `json:"user_id"`
+	Role    string   `json:"role"`
+	Active  bool     `json:"active"`
+	Version int      `json:"version"`
+	Changed []string `json:"changed,omitempty"` // Names of the fields an update changed
+}
+
+// UserDeletedEvent is the payload of events.UserDeleted
+type UserDeletedEvent struct {
+	UserID    int       `json:"user_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// UserErasedEvent is the payload of events.UserErased. Consumers holding copies of
+// the user's personal data are expected to delete them.
+type UserErasedEvent struct {
+	UserID   int       `json:"user_id"`
+	ErasedAt time.Time `json:"erased_at"`
+}
+
+// PublishUserCreated writes a UserCreated event to the outbox within tx
+func PublishUserCreated(ctx context.Context, tx database.DBTX, user *User) error {
+	return events.Publish(ctx, tx, events.AggregateUser, strconv.Itoa(user.UserID), events.UserCreated, UserChangedEvent{
+		UserID:  user.UserID,
+		Role:    user.Role,
+		Active:  user.Active,
+		Version: user.Version,
+	})
+}
+
+// PublishUserUpdated writes a UserUpdated event for the change from before to after
+// to the outbox within tx
+func PublishUserUpdated(ctx context.Context, tx database.DBTX, before, after *User) error {
+	return events.Publish(ctx, tx, events.AggregateUser, strconv.Itoa(after.UserID), events.UserUpdated, UserChangedEvent{
+		UserID:  after.UserID,
+		Role:    after.Role,
+		Active:  after.Active,
+		Version: after.Version,
+		Changed: changedFields(before, after),
+	})
+}
+
+// PublishUserDeleted writes a UserDeleted event to the outbox within tx
+func PublishUserDeleted(ctx context.Context, tx database.DBTX, userID int, deletedAt time.Time) error {
+	return events.Publish(ctx, tx, events.AggregateUser, strconv.Itoa(userID), events.UserDeleted, UserDeletedEvent{
+		UserID:    userID,
+		DeletedAt: deletedAt,
+	})
+}
+
+// PublishUserErased writes a UserErased event to the outbox within tx
+func PublishUserErased(ctx context.Context, tx database.DBTX, userID int, erasedAt time.Time) error {
+	return events.Publish(ctx, tx, events.AggregateUser, strconv.Itoa(userID), events.UserErased, UserErasedEvent{
+		UserID:   userID,
+		ErasedAt: erasedAt,
+	})
+}
+
+// changedFields returns the JSON names of the fields that differ between the users
+func changedFields(before, after *User) []string {
+	var changed []string
+	add := func(field string, differs bool) {
+		if differs {
+			changed = append(changed, field)
+		}
+	}
+
+	add("username", before.Username != after.Username)
+	add("first_name", !equalOptional(before.FirstName, after.FirstName))
+	add("last_name", !equalOptional(before.LastName, after.LastName))
+	add("email", before.Email != after.Email)
+	add("phone", !equalOptional(before.Phone, after.Phone))
+	add("role", before.Role != after.Role)
+	add("active", before.Active != after.Active)
+	add("deleted", (before.DeletedAt == nil) != (after.DeletedAt == nil))
+	return changed
+}
+
+func equalOptional(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -1,22 +1,37 @@
 package user
 
 import (
+	"errors"
+	"fmt"
 	"metalcore-api/internal/common"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/middleware"
+	"metalcore-api/internal/privacy"
+	"metalcore-api/internal/tenant"
+	"mime"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type Handler struct {
 	service *Service
+	exports *privacy.Registry
 }
 
-func NewHandler(service *Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(service *Service, exports *privacy.Registry) *Handler {
+	return &Handler{service: service, exports: exports}
 }
 
 func (h *Handler) GetByID(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+
 	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -25,17 +40,13 @@ func (h *Handler) GetByID(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.GetByID(c.Request.Context(), userID)
+	user, err := h.service.GetMember(c.Request.Context(), t, userID)
 	if err != nil {
 		switch err {
 		case ErrUserNotFound:
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": "User not found",
 			})
-		case ErrUserInactive:
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "User is inactive",
-			})
 		default:
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Internal server error",
@@ -44,24 +55,28 @@ func (h *Handler) GetByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": ToUserResponse(user),
-	})
+	respondUser(c, user)
 }
 
 func (h *Handler) GetAll(c *gin.Context) {
+	t, ok := currentTenant(c)
+	if !ok {
+		return
+	}
+
 	var pagination common.PaginationRequest
 	if err := c.ShouldBindQuery(&pagination); err != nil {
 		c.JSON(http.StatusBadRequest, common.ErrorResponse{
 			Status:  http.StatusBadRequest,
 			Error:   "Invalid pagination parameters",
 			Message: err.Error(),
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
 		})
 		return
 	}
 	page := pagination.GetPage()
 	page_size := pagination.GetPageSize()
-	users, total, err := h.service.GetAll(c.Request.Context(), page, page_size)
+	users, total, err := h.service.GetAll(c.Request.Context(), t, page, page_size)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -83,7 +98,7 @@ func (h *Handler) GetAll(c *gin.Context) {
 func (h *Handler) Create(c *gin.Context) {
 	var payload CreateUserRequest
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		validationErrors := common.FormatValidationErrors(err)
+		validationErrors := common.FormatValidationErrors(err, c.GetHeader("Accept-Language"))
 		c.JSON(http.StatusBadRequest, common.ErrorResponse{
 			Status:  http.StatusBadRequest,
 			Error:   "Validation failed",
@@ -93,7 +108,7 @@ func (h *Handler) Create(c *gin.Context) {
 		return
 	}
 
-	response, err := h.service.Create(c, payload)
+	response, err := h.service.Create(c.Request.Context(), payload)
 	if err != nil {
 		switch err {
 		case ErrUsernameExists:
@@ -102,6 +117,14 @@ func (h *Handler) Create(c *gin.Context) {
 				Error:   "Username already exists",
 				Message: "Please choose a different username",
 			})
+		case ErrEmailExists:
+			c.JSON(http.StatusConflict, common.ErrorResponse{
+				Status:  http.StatusConflict,
+				Error:   "Email already exists",
+				Message: "Please choose a different email",
+			})
+		case common.ErrInvalidPhone:
+			respondInvalidPhone(c)
 		default:
 			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
 				Status:  http.StatusInternalServerError,
@@ -114,7 +137,798 @@ func (h *Handler) Create(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "user has been created successfully.",
+		"data":    ToUserResponse(response),
+	})
+
+}
+
+func (h *Handler) GetMe(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	user, err := h.service.GetByID(c.Request.Context(), principal.UserID)
+	if err != nil {
+		switch err {
+		case ErrUserNotFound:
+			c.JSON(http.StatusNotFound, common.ErrorResponse{
+				Status: http.StatusNotFound,
+				Error:  "User not found",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	respondUser(c, user)
+}
+
+func (h *Handler) UpdateMe(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	version, ok := parseIfMatch(c, false)
+	if !ok {
+		return
+	}
+
+	var payload UpdateProfileRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationErrors := common.FormatValidationErrors(err, c.GetHeader("Accept-Language"))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: validationErrors,
+		})
+		return
+	}
+
+	user, err := h.service.UpdateProfile(c.Request.Context(), principal.UserID, version, payload)
+	if err != nil {
+		switch err {
+		case ErrUserNotFound:
+			c.JSON(http.StatusNotFound, common.ErrorResponse{
+				Status: http.StatusNotFound,
+				Error:  "User not found",
+			})
+		case ErrVersionMismatch:
+			respondVersionMismatch(c)
+		case common.ErrInvalidPhone:
+			respondInvalidPhone(c)
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	c.Header("ETag", common.ETag(user.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "profile has been updated successfully.",
+		"data":    ToUserResponse(user),
+	})
+}
+
+func (h *Handler) DeleteMe(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	response, err := h.service.DeleteSelf(c.Request.Context(), principal.UserID)
+	if err != nil {
+		switch err {
+		case ErrUserNotFound:
+			c.JSON(http.StatusNotFound, common.ErrorResponse{
+				Status: http.StatusNotFound,
+				Error:  "User not found",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "account has been deleted, log in again before restore_before to cancel.",
 		"data":    response,
 	})
+}
+
+func (h *Handler) ChangePassword(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	var payload ChangePasswordRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationErrors := common.FormatValidationErrors(err, c.GetHeader("Accept-Language"))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: validationErrors,
+		})
+		return
+	}
+
+	err := h.service.ChangePassword(c.Request.Context(), principal.UserID, principal.SessionID, payload)
+	if err != nil {
+		switch err {
+		case ErrInvalidPassword:
+			c.JSON(http.StatusForbidden, common.ErrorResponse{
+				Status:  http.StatusForbidden,
+				Error:   "Invalid password",
+				Message: "Current password is incorrect",
+			})
+		case ErrUserNotFound:
+			c.JSON(http.StatusNotFound, common.ErrorResponse{
+				Status: http.StatusNotFound,
+				Error:  "User not found",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "password has been changed successfully, other sessions were signed out.",
+	})
+}
+
+func (h *Handler) ChangeEmail(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	var payload ChangeEmailRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationErrors := common.FormatValidationErrors(err, c.GetHeader("Accept-Language"))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: validationErrors,
+		})
+		return
+	}
+
+	err := h.service.RequestEmailChange(c.Request.Context(), principal.UserID, payload)
+	if err != nil {
+		switch err {
+		case ErrInvalidPassword:
+			c.JSON(http.StatusForbidden, common.ErrorResponse{
+				Status:  http.StatusForbidden,
+				Error:   "Invalid password",
+				Message: "Current password is incorrect",
+			})
+		case ErrEmailExists:
+			c.JSON(http.StatusConflict, common.ErrorResponse{
+				Status:  http.StatusConflict,
+				Error:   "Email already exists",
+				Message: "Please choose a different email",
+			})
+		case ErrEmailUnchanged:
+			c.JSON(http.StatusBadRequest, common.ErrorResponse{
+				Status:  http.StatusBadRequest,
+				Error:   "Email unchanged",
+				Message: "The new email matches the current email",
+			})
+		case ErrUserNotFound:
+			c.JSON(http.StatusNotFound, common.ErrorResponse{
+				Status: http.StatusNotFound,
+				Error:  "User not found",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "a confirmation link has been sent to the new email address.",
+	})
+}
+
+func (h *Handler) ConfirmEmailChange(c *gin.Context) {
+	var payload ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationErrors := common.FormatValidationErrors(err, c.GetHeader("Accept-Language"))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: validationErrors,
+		})
+		return
+	}
+
+	user, err := h.service.ConfirmEmailChange(c.Request.Context(), payload)
+	if err != nil {
+		switch err {
+		case ErrInvalidEmailToken:
+			c.JSON(http.StatusBadRequest, common.ErrorResponse{
+				Status:  http.StatusBadRequest,
+				Error:   "Invalid token",
+				Message: "The confirmation link is invalid or has expired",
+			})
+		case ErrEmailExists:
+			c.JSON(http.StatusConflict, common.ErrorResponse{
+				Status:  http.StatusConflict,
+				Error:   "Email already exists",
+				Message: "The new email is already in use",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "email has been changed successfully.",
+		"data": gin.H{
+			"user_id": user.UserID,
+			"email":   user.Email,
+		},
+	})
+}
+
+func (h *Handler) ExportMe(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	var query ExportRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid export parameters",
+			Message: err.Error(),
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	archive, err := h.exports.Export(c.Request.Context(), principal.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+			Status:  http.StatusInternalServerError,
+			Error:   "Internal server error",
+			Message: "An unexpected error occurred",
+		})
+		return
+	}
+
+	if query.Format == "zip" {
+		filename := fmt.Sprintf("metalcore-export-%d-%s.zip", principal.UserID, archive.ExportedAt.Format("20060102T150405Z"))
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Status(http.StatusOK)
+		if err := archive.WriteZIP(c.Writer); err != nil {
+			// Headers are already sent, the client gets a truncated archive
+			logging.FromContext(c.Request.Context()).Error("error while writing export archive", "error", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": archive,
+	})
+}
+
+func (h *Handler) EraseMe(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, common.ErrorResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	var payload EraseAccountRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationErrors := common.FormatValidationErrors(err, c.GetHeader("Accept-Language"))
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: validationErrors,
+		})
+		return
+	}
+
+	err := h.service.EraseSelf(c.Request.Context(), principal.UserID, payload)
+	if err != nil {
+		switch err {
+		case ErrInvalidPassword:
+			c.JSON(http.StatusForbidden, common.ErrorResponse{
+				Status:  http.StatusForbidden,
+				Error:   "Invalid password",
+				Message: "Current password is incorrect",
+			})
+		case ErrUserNotFound:
+			c.JSON(http.StatusNotFound, common.ErrorResponse{
+				Status: http.StatusNotFound,
+				Error:  "User not found",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "account and personal data have been erased.",
+	})
+}
+
+func (h *Handler) Erase(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user ID",
+		})
+		return
+	}
+
+	err = h.service.Erase(c.Request.Context(), userID)
+	if err != nil {
+		switch err {
+		case ErrUserNotFound:
+			c.JSON(http.StatusNotFound, common.ErrorResponse{
+				Status:  http.StatusNotFound,
+				Error:   "User not found",
+				Message: "The user does not exist or has already been erased",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user personal data has been erased.",
+	})
+}
+
+// Patch updates the given fields of a user, the If-Match header is required
+func (h *Handler) Patch(c *gin.Context) {
+	userID, version, ok := updateTarget(c)
+	if !ok {
+		return
+	}
+
+	var payload UpdateUserRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	user, err := h.service.Patch(c.Request.Context(), userID, version, payload)
+	respondUpdated(c, user, err)
+}
+
+// Replace overwrites the editable fields of a user, the If-Match header is required
+func (h *Handler) Replace(c *gin.Context) {
+	userID, version, ok := updateTarget(c)
+	if !ok {
+		return
+	}
+
+	var payload ReplaceUserRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	user, err := h.service.Replace(c.Request.Context(), userID, version, payload)
+	respondUpdated(c, user, err)
+}
+
+// updateTarget reads the user ID and the required If-Match header of an administrative update
+func updateTarget(c *gin.Context) (int, []int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid user ID",
+		})
+		return 0, nil, false
+	}
+
+	version, ok := parseIfMatch(c, true)
+	return userID, version, ok
+}
+
+func respondValidationError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, common.ErrorResponse{
+		Status:  http.StatusBadRequest,
+		Error:   "Validation failed",
+		Message: "Please check the input fields",
+		Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+	})
+}
+
+// respondInvalidPhone reports a phone number that could not be normalized
+func respondInvalidPhone(c *gin.Context) {
+	c.JSON(http.StatusBadRequest, common.ErrorResponse{
+		Status:  http.StatusBadRequest,
+		Error:   "Validation failed",
+		Message: "Please check the input fields",
+		Details: map[string]string{"phone": "Invalid phone number"},
+	})
+}
+
+func respondUpdated(c *gin.Context, user *User, err error) {
+	if err != nil {
+		switch err {
+		case ErrUserNotFound:
+			c.JSON(http.StatusNotFound, common.ErrorResponse{
+				Status: http.StatusNotFound,
+				Error:  "User not found",
+			})
+		case ErrVersionMismatch:
+			respondVersionMismatch(c)
+		case common.ErrInvalidPhone:
+			respondInvalidPhone(c)
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	c.Header("ETag", common.ETag(user.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user has been updated successfully.",
+		"data":    ToUserResponse(user),
+	})
+}
+
+// respondUser writes the user with its ETag, or 304 Not Modified when it matches If-None-Match
+func respondUser(c *gin.Context, user *User) {
+	etag := common.ETag(user.Version)
+	c.Header("ETag", etag)
+
+	if header := c.GetHeader("If-None-Match"); header != "" && common.ETagMatches(header, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": ToUserResponse(user),
+	})
+}
+
+// currentTenant returns the tenant set by middleware.RequireTenant
+func currentTenant(c *gin.Context) (*tenant.Tenant, bool) {
+	t, ok := tenant.FromContext(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Organization required",
+		})
+	}
+	return t, ok
+}
+
+// parseIfMatch returns the versions accepted by the If-Match header, nil for "*" or when the
+// header is absent and not required. It answers 428 when a required header is missing and
+// 412 when it cannot be parsed or accepts no version.
+func parseIfMatch(c *gin.Context, required bool) ([]int, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if !required {
+			return nil, true
+		}
+		c.JSON(http.StatusPreconditionRequired, common.ErrorResponse{
+			Status:  http.StatusPreconditionRequired,
+			Error:   "Precondition required",
+			Message: "Send the ETag of the user in the If-Match header",
+		})
+		return nil, false
+	}
+
+	versions, ok := common.IfMatchVersions(header)
+	if !ok {
+		respondVersionMismatch(c)
+		return nil, false
+	}
+	return versions, true
+}
+
+func respondVersionMismatch(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, common.ErrorResponse{
+		Status:  http.StatusPreconditionFailed,
+		Error:   "Precondition failed",
+		Message: "The user has been modified, fetch it again and retry",
+	})
+}
+
+// importFormats maps the accepted content types of an import to its format
+var importFormats = map[string]string{
+	"text/csv":             FormatCSV,
+	"application/x-ndjson": FormatNDJSON,
+	"application/jsonl":    FormatNDJSON,
+}
+
+// Import creates users from a CSV or NDJSON body, skipping and reporting invalid rows
+func (h *Handler) Import(c *gin.Context) {
+	var query ImportUsersRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid import parameters",
+			Message: err.Error(),
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	format, ok := importFormats[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, common.ErrorResponse{
+			Status:  http.StatusUnsupportedMediaType,
+			Error:   "Unsupported media type",
+			Message: "Content-Type must be text/csv or application/x-ndjson",
+		})
+		return
+	}
+
+	rows, err := DecodeImport(c.Request.Body, format, ImportMaxRows())
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			c.JSON(http.StatusRequestEntityTooLarge, common.ErrorResponse{
+				Status:  http.StatusRequestEntityTooLarge,
+				Error:   "Request body too large",
+				Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+			})
+		case errors.Is(err, ErrImportTooManyRows):
+			c.JSON(http.StatusRequestEntityTooLarge, common.ErrorResponse{
+				Status:  http.StatusRequestEntityTooLarge,
+				Error:   "Too many rows",
+				Message: fmt.Sprintf("An import may contain at most %d users", ImportMaxRows()),
+			})
+		default:
+			c.JSON(http.StatusBadRequest, common.ErrorResponse{
+				Status:  http.StatusBadRequest,
+				Error:   "Invalid import",
+				Message: err.Error(),
+			})
+		}
+		return
+	}
+
+	for i := range rows {
+		if rows[i].Err == nil {
+			rows[i].Err = binding.Validator.ValidateStruct(&rows[i].Request)
+		}
+	}
+
+	imported, err := h.service.Import(c.Request.Context(), rows, query.DryRun)
+	if err != nil {
+		switch err {
+		case ErrImportConflict:
+			c.JSON(http.StatusConflict, common.ErrorResponse{
+				Status:  http.StatusConflict,
+				Error:   "Import conflict",
+				Message: "Some users were created while importing, retry the import",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Error:   "Internal server error",
+				Message: "An unexpected error occurred",
+			})
+		}
+		return
+	}
+
+	response := ImportUsersResponse{
+		Total:    len(rows),
+		Imported: imported,
+		DryRun:   query.DryRun,
+		Errors:   []ImportRowError{},
+	}
+	for _, row := range rows {
+		if row.Err == nil {
+			continue
+		}
+		response.Failed++
+		response.Errors = append(response.Errors, ImportRowError{
+			Line:     row.Line,
+			Username: row.Request.Username,
+			Errors:   importRowErrors(row.Err, c.GetHeader("Accept-Language")),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": response,
+	})
+}
+
+// importRowErrors describes why a row was rejected, keyed by field
+func importRowErrors(err error, acceptLanguage string) map[string]string {
+	switch err {
+	case ErrUsernameExists:
+		return map[string]string{"username": "Username already exists"}
+	case ErrEmailExists:
+		return map[string]string{"email": "Email already exists"}
+	case common.ErrInvalidPhone:
+		return map[string]string{"phone": "Invalid phone number"}
+	}
+
+	if details := common.FormatValidationErrors(err, acceptLanguage); len(details) > 0 {
+		return details
+	}
+	return map[string]string{"row": err.Error()}
+}
+
+// Export streams every user as CSV or NDJSON
+func (h *Handler) Export(c *gin.Context) {
+	var query ExportUsersRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid export parameters",
+			Message: err.Error(),
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	format, contentType := FormatNDJSON, "application/x-ndjson"
+	if query.Format == FormatCSV {
+		format, contentType = FormatCSV, "text/csv; charset=utf-8"
+	}
 
+	filename := fmt.Sprintf("metalcore-users-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	writer, err := NewExportWriter(c.Writer, format)
+	if err == nil {
+		err = h.service.Export(c.Request.Context(), writer)
+	}
+	if err != nil {
+		// Headers are already sent, the client gets a truncated export
+		logging.FromContext(c.Request.Context()).Error("error while writing user export", "error", err)
+	}
+}
+
+// Batch applies deactivate, reactivate and delete operations to many users. A failed
+// atomic batch is answered with 409 and the results, nothing has been changed.
+func (h *Handler) Batch(c *gin.Context) {
+	var payload BatchUsersRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	if limit := BatchMaxOperations(); len(payload.Operations) > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, common.ErrorResponse{
+			Status:  http.StatusRequestEntityTooLarge,
+			Error:   "Too many operations",
+			Message: fmt.Sprintf("A batch may contain at most %d operations", limit),
+		})
+		return
+	}
+
+	if payload.Mode == "" {
+		payload.Mode = BatchAtomic
+	}
+
+	errs, err := h.service.Batch(c.Request.Context(), payload.Operations, payload.Mode == BatchAtomic)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+			Status:  http.StatusInternalServerError,
+			Error:   "Internal server error",
+			Message: "An unexpected error occurred",
+		})
+		return
+	}
+
+	response := BatchUsersResponse{
+		Mode:    payload.Mode,
+		Results: make([]BatchOperationResult, len(payload.Operations)),
+	}
+	for i, operation := range payload.Operations {
+		result := BatchOperationResult{Op: operation.Op, UserID: operation.UserID, Status: http.StatusOK}
+		switch errs[i] {
+		case nil:
+			response.Succeeded++
+		case ErrUserNotFound:
+			result.Status, result.Error = http.StatusNotFound, "User not found"
+		case ErrBatchRolledBack:
+			result.Status, result.Error = http.StatusConflict, "Rolled back, another operation failed"
+		default:
+			logging.FromContext(c.Request.Context()).Error("error in batch operation", "op", operation.Op, "user_id", operation.UserID, "error", errs[i])
+			result.Status, result.Error = http.StatusInternalServerError, "Internal server error"
+		}
+		if errs[i] != nil {
+			response.Failed++
+		}
+		response.Results[i] = result
+	}
+
+	status := http.StatusOK
+	if payload.Mode == BatchAtomic && response.Failed > 0 {
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"data": response,
+	})
 }
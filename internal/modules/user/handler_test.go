@@ -0,0 +1,108 @@
+package user
+
+import (
+	"metalcore-api/internal/common"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testContext(headers map[string]string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	for name, value := range headers {
+		c.Request.Header.Set(name, value)
+	}
+	return c, w
+}
+
+func TestRespondUserConditionalGet(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"", http.StatusOK},
+		{`"7"`, http.StatusNotModified},
+		{`W/"7"`, http.StatusNotModified},
+		{`"6", "7"`, http.StatusNotModified},
+		{`*`, http.StatusNotModified},
+		{`"6"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		c, w := testContext(map[string]string{"If-None-Match": tt.ifNoneMatch})
+		respondUser(c, &User{UserID: 1, Username: "alice", Version: 7})
+		// gin writes a status without a body once the handlers return
+		c.Writer.WriteHeaderNow()
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("If-None-Match %s: status = %d, want %d", tt.ifNoneMatch, w.Code, tt.wantStatus)
+		}
+		if etag := w.Header().Get("ETag"); etag != `"7"` {
+			t.Errorf("If-None-Match %s: ETag = %s, want \"7\"", tt.ifNoneMatch, etag)
+		}
+		if tt.wantStatus == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: 304 with a body", tt.ifNoneMatch)
+		}
+	}
+}
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		ifMatch    string
+		required   bool
+		want       []int
+		wantOK     bool
+		wantStatus int
+	}{
+		{"missing and optional", "", false, nil, true, 0},
+		{"missing and required", "", true, nil, false, http.StatusPreconditionRequired},
+		{"any version", "*", true, nil, true, 0},
+		{"one version", `"3"`, true, []int{3}, true, 0},
+		{"list of versions", `"3", "4"`, true, []int{3, 4}, true, 0},
+		{"malformed", "3", true, nil, false, http.StatusPreconditionFailed},
+		{"only weak tags", `W/"3"`, false, nil, false, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.ifMatch != "" {
+				headers["If-Match"] = tt.ifMatch
+			}
+			c, w := testContext(headers)
+
+			versions, ok := parseIfMatch(c, tt.required)
+			if ok != tt.wantOK || !slices.Equal(versions, tt.want) {
+				t.Fatalf("parseIfMatch() = %v, %v, want %v, %v", versions, ok, tt.want, tt.wantOK)
+			}
+			if !ok && w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRespondUpdatedVersionMismatch(t *testing.T) {
+	c, w := testContext(nil)
+	respondUpdated(c, nil, ErrVersionMismatch)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d, want 412", w.Code)
+	}
+}
+
+func TestRespondUpdatedInvalidPhone(t *testing.T) {
+	c, w := testContext(nil)
+	respondUpdated(c, nil, common.ErrInvalidPhone)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"phone"`) {
+		t.Errorf("body = %s, want a phone detail", w.Body.String())
+	}
+}
@@ -0,0 +1,25 @@
+package user
+
+import (
+	"context"
+
+	"metalcore-api/internal/mailer"
+)
+
+// SendEmailChangeConfirmationArgs is the job that mails the confirmation link of an
+// email change request. Only the request is referenced: the token is issued when
+// the job runs, so that it is never stored in the queue.
+type SendEmailChangeConfirmationArgs struct {
+	RequestID int64 `json:"request_id"`
+}
+
+func (SendEmailChangeConfirmationArgs) Kind() string {
+	return "user.send_email_change_confirmation"
+}
+
+// SendEmailChangeConfirmationJob returns the handler of SendEmailChangeConfirmationArgs jobs
+func SendEmailChangeConfirmationJob(s *Service, m mailer.Mailer) func(ctx context.Context, args SendEmailChangeConfirmationArgs) error {
+	return func(ctx context.Context, args SendEmailChangeConfirmationArgs) error {
+		return s.SendEmailChangeConfirmation(ctx, m, args.RequestID)
+	}
+}
@@ -2,6 +2,12 @@ package user
 
 import "time"
 
+// Roles a user can hold
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	UserID    int        `db:"UserId" json:"user_id"`
 	Username  string     `db:"Username" json:"username"`
@@ -10,8 +16,21 @@ type User struct {
 	Email     string     `db:"Email" json:"email"`
 	Phone     *string    `db:"Phone" json:"phone,omitempty"`
 	Password  string     `db:"Password" json:"-"` // never expose
+	Role      string     `db:"Role" json:"role"`
 	Active    bool       `db:"Active" json:"active"`
 	CreatedAt time.Time  `db:"CreatedAt" json:"created_at"`
 	UpdatedAt *time.Time `db:"UpdatedAt" json:"updated_at,omitempty"`
 	DeletedAt *time.Time `db:"DeletedAt" json:"deleted_at,omitempty"`
+	Version   int        `db:"Version" json:"-"` // Incremented on every change, the ETag of the user
+}
+
+// EmailChangeRequest is a pending change of a user's email awaiting confirmation from the new address
+type EmailChangeRequest struct {
+	RequestID   int64      `db:"RequestId" json:"request_id"`
+	UserID      int        `db:"UserId" json:"user_id"`
+	NewEmail    string     `db:"NewEmail" json:"new_email"`
+	TokenHash   string     `db:"TokenHash" json:"-"`
+	CreatedAt   time.Time  `db:"CreatedAt" json:"created_at"`
+	ExpiresAt   time.Time  `db:"ExpiresAt" json:"expires_at"`
+	ConfirmedAt *time.Time `db:"ConfirmedAt" json:"confirmed_at,omitempty"`
 }
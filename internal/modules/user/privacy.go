@@ -0,0 +1,42 @@
+package user
+
+import (
+	"context"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/pii"
+)
+
+// ProfileExport is the user's section of a personal data export
+type ProfileExport struct {
+	User                *UserResponse        `json:"user"`
+	EmailChangeRequests []EmailChangeRequest `json:"email_change_requests"`
+}
+
+// Exporter contributes the user's profile and email changes to personal data exports
+type Exporter struct {
+	repo *UserRepository
+}
+
+func NewExporter(db database.DBTX, cipher *pii.Cipher) *Exporter {
+	return &Exporter{repo: NewUserRepository(db, cipher)}
+}
+
+func (e *Exporter) Name() string { return "profile" }
+
+func (e *Exporter) Export(ctx context.Context, userID int) (any, error) {
+	user, err := e.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := e.repo.ListEmailChangeRequests(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []EmailChangeRequest{}
+	}
+
+	return ProfileExport{User: ToUserResponse(user), EmailChangeRequests: requests}, nil
+}
@@ -3,18 +3,41 @@ package user
 import (
 	"context"
 	"errors"
-	"log"
+	"time"
+
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/pii"
+	"metalcore-api/internal/tenant"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Personal data columns encrypted with the repository's cipher. The names are bound
+// to the ciphertexts and blind indexes, they must not change.
+const (
+	fieldFirstName = "Firstname"
+	fieldLastName  = "Lastname"
+	fieldEmail     = "Email"
+	fieldPhone     = "Phone"
+	fieldNewEmail  = "NewEmail" // EmailChangeRequest
 )
 
 type UserRepository struct {
-	db *pgxpool.Pool
+	db     database.DBTX
+	cipher *pii.Cipher
+}
+
+// NewUserRepository returns a repository encrypting names, email and phone with
+// cipher. A nil cipher stores them in plaintext.
+func NewUserRepository(db database.DBTX, cipher *pii.Cipher) *UserRepository {
+	return &UserRepository{db: db, cipher: cipher}
 }
 
-func NewUserRepository(db *pgxpool.Pool) *UserRepository {
-	return &UserRepository{db: db}
+// WithTx returns a repository that runs its queries in tx
+func (r *UserRepository) WithTx(tx pgx.Tx) *UserRepository {
+	return &UserRepository{db: tx, cipher: r.cipher}
 }
 
 func (r *UserRepository) GetByID(ctx context.Context, userID int) (*User, error) {
@@ -27,10 +50,12 @@ func (r *UserRepository) GetByID(ctx context.Context, userID int) (*User, error)
 			"Email",
 			"Phone",
 			"Password",
+			"Role",
 			"Active",
 			"CreatedAt",
 			"UpdatedAt",
-			"DeletedAt"
+			"DeletedAt",
+			"Version"
 		FROM public."User"
 		WHERE "UserId" = $1
 		  AND "DeletedAt" IS NULL
@@ -47,19 +72,25 @@ func (r *UserRepository) GetByID(ctx context.Context, userID int) (*User, error)
 		&user.Email,
 		&user.Phone,
 		&user.Password,
+		&user.Role,
 		&user.Active,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.DeletedAt,
+		&user.Version,
 	)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			log.Printf("User not found with ID: %d", userID)
+			logging.FromContext(ctx).Info("user not found", "user_id", userID)
 			return nil, errors.New("user not found")
 		}
 		// Log the actual error for debugging
-		log.Printf("Database error in GetByID: %v", err)
+		logging.FromContext(ctx).Error("database error in GetByID", "error", err)
+		return nil, err
+	}
+
+	if err := r.openPII(ctx, &user); err != nil {
 		return nil, err
 	}
 
@@ -77,7 +108,8 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*U
 			"Phone",
 			"Active",
 			"CreatedAt",
-			"UpdatedAt"
+			"UpdatedAt",
+			"Version"
 		FROM public."User"
 		WHERE "Username" = $1
 		  AND "DeletedAt" IS NULL
@@ -95,53 +127,81 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*U
 		&user.Active,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Version,
 	)
 
 	if err != nil {
-		log.Println("error while fetching user with username", err)
+		logging.FromContext(ctx).Error("error while fetching user with username", "error", err)
 		return nil, err
 	}
 
-	return &user, nil
-}
-
-// UsernameExists checks if a username exists regardless of active status or deletion
-func (r *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
-	query := `
-		SELECT EXISTS(
-			SELECT 1
-			FROM public."User"
-			WHERE "Username" = $1
-		)
-	`
-	var exists bool
-
-	err := r.db.QueryRow(ctx, query, username).Scan(&exists)
-	if err != nil {
-		log.Println("error while checking username existence:", err)
-		return false, err
+	if err := r.openPII(ctx, &user); err != nil {
+		return nil, err
 	}
 
-	return exists, nil
+	return &user, nil
 }
 
-func (r *UserRepository) GetAll(ctx context.Context, offset, limit int) ([]User, int64, error) {
-	// Get total count
-	var totalCount int64
-	countQuery := `
-		SELECT COUNT(*)
+// GetByEmail returns an active user by email, including the password hash for authentication.
+// Users soft deleted within restorableWithin are returned too so that logging in can restore them.
+func (r *UserRepository) GetByEmail(ctx context.Context, email string, restorableWithin time.Duration) (*User, error) {
+	email = common.NormalizeEmail(email)
+	query := `
+		SELECT
+			"UserId",
+			"Username",
+			"Firstname",
+			"Lastname",
+			"Email",
+			"Phone",
+			"Password",
+			"Role",
+			"Active",
+			"CreatedAt",
+			"UpdatedAt",
+			"DeletedAt",
+			"Version"
 		FROM public."User"
-		WHERE "DeletedAt" IS NULL
+		WHERE ("EmailIndex" = $3 OR ("EmailIndex" IS NULL AND LOWER("Email") = $1))
 		  AND "Active" = True
+		  AND ("DeletedAt" IS NULL OR "DeletedAt" > NOW() - $2::INTERVAL)
 	`
 
-	err := r.db.QueryRow(ctx, countQuery).Scan(&totalCount)
+	var user User
+
+	err := r.db.QueryRow(ctx, query, email, restorableWithin, r.emailIndex(email)).Scan(
+		&user.UserID,
+		&user.Username,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.Phone,
+		&user.Password,
+		&user.Role,
+		&user.Active,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.DeletedAt,
+		&user.Version,
+	)
+
 	if err != nil {
-		log.Printf("Database error in GetAllRepo (count): %v", err)
-		return nil, 0, err
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("database error in GetByEmail", "error", err)
+		}
+		return nil, err
+	}
+
+	if err := r.openPII(ctx, &user); err != nil {
+		return nil, err
 	}
 
-	// Get paginated data
+	return &user, nil
+}
+
+// LockByID returns a user regardless of deletion and locks the row until the end of
+// the transaction, so that it can serve as the "before" state of an audited change
+func (r *UserRepository) LockByID(ctx context.Context, userID int) (*User, error) {
 	query := `
 		SELECT
 			"UserId",
@@ -151,31 +211,203 @@ func (r *UserRepository) GetAll(ctx context.Context, offset, limit int) ([]User,
 			"Email",
 			"Phone",
 			"Password",
+			"Role",
 			"Active",
 			"CreatedAt",
 			"UpdatedAt",
-			"DeletedAt"
+			"DeletedAt",
+			"Version"
 		FROM public."User"
-		WHERE "DeletedAt" IS NULL
-		  AND "Active" = True
-		ORDER BY "CreatedAt" DESC
-		LIMIT $1 OFFSET $2
+		WHERE "UserId" = $1
+		FOR UPDATE
 	`
 
-	rows, err := r.db.Query(ctx, query, limit, offset)
+	var user User
+
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&user.UserID,
+		&user.Username,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.Phone,
+		&user.Password,
+		&user.Role,
+		&user.Active,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.DeletedAt,
+		&user.Version,
+	)
+
 	if err != nil {
-		log.Printf("Database error in GetAll: %v", err)
-		return nil, 0, err
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("database error in LockByID", "error", err)
+		}
+		return nil, err
 	}
 
-	defer rows.Close()
+	if err := r.openPII(ctx, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// UsernameExists checks if a username exists regardless of active status or deletion
+func (r *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1
+			FROM public."User"
+			WHERE "Username" = $1
+		)
+	`
+	var exists bool
+
+	err := r.db.QueryRow(ctx, query, username).Scan(&exists)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while checking username existence", "error", err)
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// scoped runs fn in a transaction, or a savepoint of the current one, in which
+// row-level security limits users to the members of tenantID
+func (r *UserRepository) scoped(ctx context.Context, tenantID int64, fn func(tx pgx.Tx) error) error {
+	return database.WithTx(ctx, r.db, func(tx pgx.Tx) error {
+		if err := tenant.Scope(ctx, tx, tenantID, 0); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
 
+// GetAll returns a page of the active members of the organization tenantID, newest first
+func (r *UserRepository) GetAll(ctx context.Context, tenantID int64, offset, limit int) ([]User, int64, error) {
 	var users []User
+	var totalCount int64
+	err := r.scoped(ctx, tenantID, func(tx pgx.Tx) error {
+		// Get total count
+		countQuery := `
+			SELECT COUNT(*)
+			FROM public."User" u
+			JOIN public."Membership" m ON m."UserId" = u."UserId"
+			WHERE m."OrganizationId" = $1
+			  AND u."DeletedAt" IS NULL
+			  AND u."Active" = True
+		`
 
-	for rows.Next() {
-		var user User
+		if err := tx.QueryRow(ctx, countQuery, tenantID).Scan(&totalCount); err != nil {
+			logging.FromContext(ctx).Error("database error in GetAll (count)", "error", err)
+			return err
+		}
 
-		err := rows.Scan(
+		// Get paginated data
+		query := `
+			SELECT
+				u."UserId",
+				u."Username",
+				u."Firstname",
+				u."Lastname",
+				u."Email",
+				u."Phone",
+				u."Password",
+				u."Role",
+				u."Active",
+				u."CreatedAt",
+				u."UpdatedAt",
+				u."DeletedAt",
+				u."Version"
+			FROM public."User" u
+			JOIN public."Membership" m ON m."UserId" = u."UserId"
+			WHERE m."OrganizationId" = $1
+			  AND u."DeletedAt" IS NULL
+			  AND u."Active" = True
+			ORDER BY u."CreatedAt" DESC, u."UserId" DESC
+			LIMIT $2 OFFSET $3
+		`
+
+		rows, err := tx.Query(ctx, query, tenantID, limit, offset)
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in GetAll", "error", err)
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var user User
+
+			err := rows.Scan(
+				&user.UserID,
+				&user.Username,
+				&user.FirstName,
+				&user.LastName,
+				&user.Email,
+				&user.Phone,
+				&user.Password,
+				&user.Role,
+				&user.Active,
+				&user.CreatedAt,
+				&user.UpdatedAt,
+				&user.DeletedAt,
+				&user.Version,
+			)
+			if err != nil {
+				logging.FromContext(ctx).Error("error scanning user row", "error", err)
+				return err
+			}
+			if err := r.openPII(ctx, &user); err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+
+		if err := rows.Err(); err != nil {
+			logging.FromContext(ctx).Error("error iterating rows", "error", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, totalCount, nil
+}
+
+// GetMember returns the active user userID if they are a member of the organization
+// tenantID, pgx.ErrNoRows otherwise
+func (r *UserRepository) GetMember(ctx context.Context, tenantID int64, userID int) (*User, error) {
+	var user User
+	err := r.scoped(ctx, tenantID, func(tx pgx.Tx) error {
+		query := `
+			SELECT
+				u."UserId",
+				u."Username",
+				u."Firstname",
+				u."Lastname",
+				u."Email",
+				u."Phone",
+				u."Password",
+				u."Role",
+				u."Active",
+				u."CreatedAt",
+				u."UpdatedAt",
+				u."DeletedAt",
+				u."Version"
+			FROM public."User" u
+			JOIN public."Membership" m ON m."UserId" = u."UserId"
+			WHERE m."OrganizationId" = $1
+			  AND u."UserId" = $2
+			  AND u."DeletedAt" IS NULL
+			  AND u."Active" = True
+		`
+
+		return tx.QueryRow(ctx, query, tenantID, userID).Scan(
 			&user.UserID,
 			&user.Username,
 			&user.FirstName,
@@ -183,27 +415,33 @@ func (r *UserRepository) GetAll(ctx context.Context, offset, limit int) ([]User,
 			&user.Email,
 			&user.Phone,
 			&user.Password,
+			&user.Role,
 			&user.Active,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
+			&user.Version,
 		)
-		if err != nil {
-			log.Printf("Error scanning user row: %v", err)
-			return nil, 0, err
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("database error in GetMember", "error", err)
 		}
-		users = append(users, user)
+		return nil, err
 	}
 
-	if err = rows.Err(); err != nil {
-		log.Printf("Error iterating rows: %v", err)
-		return nil, 0, err
+	if err := r.openPII(ctx, &user); err != nil {
+		return nil, err
 	}
 
-	return users, totalCount, nil
+	return &user, nil
 }
 
 func (r *UserRepository) Create(ctx context.Context, user *User) (*User, error) {
+	sealed, err := r.sealPII(ctx, user)
+	if err != nil {
+		return nil, err
+	}
 
 	query := `
 		INSERT INTO public."User" (
@@ -213,35 +451,1075 @@ func (r *UserRepository) Create(ctx context.Context, user *User) (*User, error)
 			"Email",
 			"Phone",
 			"Password",
-			"Active"
+			"Active",
+			"EmailIndex",
+			"PhoneIndex"
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING
 			"UserId",
+			"Role",
 			"CreatedAt",
-			"UpdatedAt"
+			"UpdatedAt",
+			"Version"
 	`
 
-	err := r.db.QueryRow(
+	err = r.db.QueryRow(
 		ctx,
 		query,
 		user.Username,
-		user.FirstName,
-		user.LastName,
-		user.Email,
-		user.Phone,
+		sealed.FirstName,
+		sealed.LastName,
+		sealed.Email,
+		sealed.Phone,
 		user.Password,
 		user.Active,
+		sealed.EmailIndex,
+		sealed.PhoneIndex,
 	).Scan(
 		&user.UserID,
+		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Version,
 	)
 
 	if err != nil {
-		log.Println("error while creating user:", err)
+		logging.FromContext(ctx).Error("error while creating user", "error", err)
 		return nil, err
 	}
 
 	return user, nil
 }
+
+// CreateMany inserts users with COPY and fills in their generated columns. Must run
+// in a transaction, as the generated columns are looked up by username afterwards.
+func (r *UserRepository) CreateMany(ctx context.Context, users []*User) error {
+	rows := make([][]any, 0, len(users))
+	usernames := make([]string, 0, len(users))
+	byUsername := make(map[string]*User, len(users))
+	for _, user := range users {
+		sealed, err := r.sealPII(ctx, user)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []any{
+			user.Username,
+			sealed.FirstName,
+			sealed.LastName,
+			sealed.Email,
+			sealed.Phone,
+			user.Password,
+			user.Active,
+			sealed.EmailIndex,
+			sealed.PhoneIndex,
+		})
+		usernames = append(usernames, user.Username)
+		byUsername[user.Username] = user
+	}
+
+	_, err := r.db.CopyFrom(
+		ctx,
+		pgx.Identifier{"public", "User"},
+		[]string{"Username", "Firstname", "Lastname", "Email", "Phone", "Password", "Active", "EmailIndex", "PhoneIndex"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while copying users", "error", err)
+		return err
+	}
+
+	query := `
+		SELECT
+			"UserId",
+			"Username",
+			"Role",
+			"CreatedAt",
+			"UpdatedAt",
+			"Version"
+		FROM public."User"
+		WHERE "Username" = ANY($1)
+	`
+
+	dbRows, err := r.db.Query(ctx, query, usernames)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in CreateMany", "error", err)
+		return err
+	}
+
+	defer dbRows.Close()
+
+	for dbRows.Next() {
+		var created User
+
+		err := dbRows.Scan(
+			&created.UserID,
+			&created.Username,
+			&created.Role,
+			&created.CreatedAt,
+			&created.UpdatedAt,
+			&created.Version,
+		)
+		if err != nil {
+			logging.FromContext(ctx).Error("error scanning user row", "error", err)
+			return err
+		}
+
+		user := byUsername[created.Username]
+		user.UserID = created.UserID
+		user.Role = created.Role
+		user.CreatedAt = created.CreatedAt
+		user.UpdatedAt = created.UpdatedAt
+		user.Version = created.Version
+	}
+
+	if err = dbRows.Err(); err != nil {
+		logging.FromContext(ctx).Error("error iterating rows", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// ExistingUsernames returns which of the usernames are taken, regardless of active status or deletion
+func (r *UserRepository) ExistingUsernames(ctx context.Context, usernames []string) (map[string]bool, error) {
+	query := `
+		SELECT "Username"
+		FROM public."User"
+		WHERE "Username" = ANY($1)
+	`
+
+	rows, err := r.db.Query(ctx, query, usernames)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ExistingUsernames", "error", err)
+		return nil, err
+	}
+
+	taken, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		logging.FromContext(ctx).Error("error scanning username rows", "error", err)
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(taken))
+	for _, username := range taken {
+		existing[username] = true
+	}
+	return existing, nil
+}
+
+// ExistingEmails returns which of the emails are used, regardless of active status or deletion
+func (r *UserRepository) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	normalized := make([]string, 0, len(emails))
+	indexes := make([][]byte, 0, len(emails))
+	for _, email := range emails {
+		normalized = append(normalized, common.NormalizeEmail(email))
+		if index := r.emailIndex(email); index != nil {
+			indexes = append(indexes, index)
+		}
+	}
+
+	query := `
+		SELECT "Email"
+		FROM public."User"
+		WHERE "EmailIndex" = ANY($2)
+		   OR ("EmailIndex" IS NULL AND LOWER("Email") = ANY($1))
+	`
+
+	rows, err := r.db.Query(ctx, query, normalized, indexes)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ExistingEmails", "error", err)
+		return nil, err
+	}
+
+	used, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		logging.FromContext(ctx).Error("error scanning email rows", "error", err)
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(used))
+	for _, email := range used {
+		if err := r.cipher.DecryptPtr(fieldEmail, &email); err != nil {
+			logging.FromContext(ctx).Error("error while decrypting email", "error", err)
+			return nil, err
+		}
+		existing[common.NormalizeEmail(email)] = true
+	}
+	return existing, nil
+}
+
+// Each calls fn with every user that is not deleted, in ID order, while the rows are
+// read from the database. It stops at the first error returned by fn.
+func (r *UserRepository) Each(ctx context.Context, fn func(*User) error) error {
+	query := `
+		SELECT
+			"UserId",
+			"Username",
+			"Firstname",
+			"Lastname",
+			"Email",
+			"Phone",
+			"Role",
+			"Active",
+			"CreatedAt",
+			"UpdatedAt",
+			"Version"
+		FROM public."User"
+		WHERE "DeletedAt" IS NULL
+		ORDER BY "UserId"
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in Each", "error", err)
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(
+			&user.UserID,
+			&user.Username,
+			&user.FirstName,
+			&user.LastName,
+			&user.Email,
+			&user.Phone,
+			&user.Role,
+			&user.Active,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.Version,
+		)
+		if err != nil {
+			logging.FromContext(ctx).Error("error scanning user row", "error", err)
+			return err
+		}
+		if err := r.openPII(ctx, &user); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("error iterating rows", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces the stored password hash of a user
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
+	query := `
+		UPDATE public."User"
+		SET "Password" = $2,
+			"Version" = "Version" + 1,
+			"UpdatedAt" = NOW()
+		WHERE "UserId" = $1
+	`
+
+	_, err := r.db.Exec(ctx, query, userID, passwordHash)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while updating user password", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// EmailExists checks if an email is used by any user regardless of active status or deletion
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1
+			FROM public."User"
+			WHERE "EmailIndex" = $2
+			   OR ("EmailIndex" IS NULL AND LOWER("Email") = $1)
+		)
+	`
+	var exists bool
+
+	err := r.db.QueryRow(ctx, query, common.NormalizeEmail(email), r.emailIndex(email)).Scan(&exists)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while checking email existence", "error", err)
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// CreateEmailChangeRequest stores a pending email change, the new address is
+// encrypted like the user's email
+func (r *UserRepository) CreateEmailChangeRequest(ctx context.Context, request *EmailChangeRequest) (*EmailChangeRequest, error) {
+	newEmail, err := r.cipher.Encrypt(fieldNewEmail, request.NewEmail)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while encrypting new email", "error", err)
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO public."EmailChangeRequest" (
+			"UserId",
+			"NewEmail",
+			"TokenHash",
+			"ExpiresAt"
+		)
+		VALUES ($1, $2, $3, $4)
+		RETURNING
+			"RequestId",
+			"CreatedAt"
+	`
+
+	err = r.db.QueryRow(
+		ctx,
+		query,
+		request.UserID,
+		newEmail,
+		request.TokenHash,
+		request.ExpiresAt,
+	).Scan(
+		&request.RequestID,
+		&request.CreatedAt,
+	)
+
+	if err != nil {
+		logging.FromContext(ctx).Error("error while creating email change request", "error", err)
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// ReissueEmailChangeToken replaces the token of a pending, unexpired request and
+// returns the request with the username of its user. Returns pgx.ErrNoRows when the
+// request was confirmed, expired or its user deleted.
+func (r *UserRepository) ReissueEmailChangeToken(ctx context.Context, requestID int64, tokenHash string) (*EmailChangeRequest, string, error) {
+	query := `
+		UPDATE public."EmailChangeRequest" r
+		SET "TokenHash" = $2
+		FROM public."User" u
+		WHERE r."RequestId" = $1
+		  AND u."UserId" = r."UserId"
+		  AND r."ConfirmedAt" IS NULL
+		  AND r."ExpiresAt" > NOW()
+		  AND u."DeletedAt" IS NULL
+		RETURNING
+			r."RequestId",
+			r."UserId",
+			r."NewEmail",
+			r."CreatedAt",
+			r."ExpiresAt",
+			u."Username"
+	`
+
+	var request EmailChangeRequest
+	var username string
+
+	err := r.db.QueryRow(ctx, query, requestID, tokenHash).Scan(
+		&request.RequestID,
+		&request.UserID,
+		&request.NewEmail,
+		&request.CreatedAt,
+		&request.ExpiresAt,
+		&username,
+	)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("error while reissuing email change token", "error", err)
+		}
+		return nil, "", err
+	}
+	request.TokenHash = tokenHash
+
+	if request.NewEmail, err = r.cipher.Decrypt(fieldNewEmail, request.NewEmail); err != nil {
+		logging.FromContext(ctx).Error("error while decrypting new email", "error", err)
+		return nil, "", err
+	}
+
+	return &request, username, nil
+}
+
+// ConfirmEmailChange swaps the user's email for the pending request matching tokenHash
+// and returns the email it replaced. Returns pgx.ErrNoRows when no pending, unexpired
+// request matches and ErrEmailExists when the new address was taken in the meantime.
+func (r *UserRepository) ConfirmEmailChange(ctx context.Context, tokenHash string) (*User, string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while starting transaction", "error", err)
+		return nil, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var request EmailChangeRequest
+	var oldEmail string
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+			r."RequestId",
+			r."UserId",
+			r."NewEmail",
+			u."Email"
+		FROM public."EmailChangeRequest" r
+		JOIN public."User" u ON u."UserId" = r."UserId"
+		WHERE r."TokenHash" = $1
+		  AND r."ConfirmedAt" IS NULL
+		  AND r."ExpiresAt" > NOW()
+		  AND u."DeletedAt" IS NULL
+		FOR UPDATE
+	`, tokenHash).Scan(
+		&request.RequestID,
+		&request.UserID,
+		&request.NewEmail,
+		&oldEmail,
+	)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("database error in ConfirmEmailChange (lookup)", "error", err)
+		}
+		return nil, "", err
+	}
+	oldEmail, err = r.cipher.Decrypt(fieldEmail, oldEmail)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while decrypting user email", "error", err)
+		return nil, "", err
+	}
+	request.NewEmail, err = r.cipher.Decrypt(fieldNewEmail, request.NewEmail)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while decrypting new email", "error", err)
+		return nil, "", err
+	}
+
+	request.NewEmail = common.NormalizeEmail(request.NewEmail)
+	newEmailIndex := r.emailIndex(request.NewEmail)
+	newEmail, err := r.cipher.Encrypt(fieldEmail, request.NewEmail)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while encrypting user email", "error", err)
+		return nil, "", err
+	}
+
+	var taken bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1
+			FROM public."User"
+			WHERE ("EmailIndex" = $3 OR ("EmailIndex" IS NULL AND LOWER("Email") = $1))
+			  AND "UserId" <> $2
+		)
+	`, request.NewEmail, request.UserID, newEmailIndex).Scan(&taken)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ConfirmEmailChange (exists)", "error", err)
+		return nil, "", err
+	}
+	if taken {
+		return nil, "", ErrEmailExists
+	}
+
+	var user User
+	err = tx.QueryRow(ctx, `
+		UPDATE public."User"
+		SET "Email" = $2,
+			"EmailIndex" = $3,
+			"Version" = "Version" + 1,
+			"UpdatedAt" = NOW()
+		WHERE "UserId" = $1
+		RETURNING
+			"UserId",
+			"Username"
+	`, request.UserID, newEmail, newEmailIndex).Scan(
+		&user.UserID,
+		&user.Username,
+	)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ConfirmEmailChange (update)", "error", err)
+		return nil, "", err
+	}
+	user.Email = request.NewEmail
+
+	// Confirm this request and expire any other pending ones for the user
+	_, err = tx.Exec(ctx, `
+		UPDATE public."EmailChangeRequest"
+		SET "ConfirmedAt" = CASE WHEN "RequestId" = $2 THEN NOW() ELSE NULL END,
+			"ExpiresAt" = LEAST("ExpiresAt", NOW())
+		WHERE "UserId" = $1
+		  AND "ConfirmedAt" IS NULL
+	`, request.UserID, request.RequestID)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ConfirmEmailChange (confirm)", "error", err)
+		return nil, "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logging.FromContext(ctx).Error("database error in ConfirmEmailChange (commit)", "error", err)
+		return nil, "", err
+	}
+
+	return &user, oldEmail, nil
+}
+
+// Update writes the editable fields of user if its version is still expectedVersion,
+// then sets the new version and update time on user. pgx.ErrNoRows means the user
+// was deleted or changed in the meantime.
+func (r *UserRepository) Update(ctx context.Context, user *User, expectedVersion int) error {
+	sealed, err := r.sealPII(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE public."User"
+		SET "Firstname" = $3,
+			"Lastname" = $4,
+			"Phone" = $5,
+			"PhoneIndex" = $6,
+			"Role" = $7,
+			"Active" = $8,
+			"Version" = "Version" + 1,
+			"UpdatedAt" = NOW()
+		WHERE "UserId" = $1
+		  AND "Version" = $2
+		  AND "DeletedAt" IS NULL
+		RETURNING
+			"Version",
+			"UpdatedAt"
+	`
+
+	err = r.db.QueryRow(
+		ctx,
+		query,
+		user.UserID,
+		expectedVersion,
+		sealed.FirstName,
+		sealed.LastName,
+		sealed.Phone,
+		sealed.PhoneIndex,
+		user.Role,
+		user.Active,
+	).Scan(
+		&user.Version,
+		&user.UpdatedAt,
+	)
+
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("error while updating user", "error", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UpdateProfile updates the self-editable fields of a user, nil fields are left unchanged.
+// A non-nil expectedVersion must match the current version of the user.
+func (r *UserRepository) UpdateProfile(ctx context.Context, userID int, expectedVersion *int, payload UpdateProfileRequest) (*User, error) {
+	sealed, err := r.sealPII(ctx, &User{FirstName: payload.FirstName, LastName: payload.LastName, Phone: payload.Phone})
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE public."User"
+		SET "Firstname" = COALESCE($2, "Firstname"),
+			"Lastname" = COALESCE($3, "Lastname"),
+			"Phone" = COALESCE($4, "Phone"),
+			"PhoneIndex" = COALESCE($5, "PhoneIndex"),
+			"Version" = "Version" + 1,
+			"UpdatedAt" = NOW()
+		WHERE "UserId" = $1
+		  AND "Version" = COALESCE($6, "Version")
+		  AND "DeletedAt" IS NULL
+		  AND "Active" = True
+		RETURNING
+			"UserId",
+			"Username",
+			"Firstname",
+			"Lastname",
+			"Email",
+			"Phone",
+			"Role",
+			"Active",
+			"CreatedAt",
+			"UpdatedAt",
+			"Version"
+	`
+
+	var user User
+
+	err = r.db.QueryRow(
+		ctx,
+		query,
+		userID,
+		sealed.FirstName,
+		sealed.LastName,
+		sealed.Phone,
+		sealed.PhoneIndex,
+		expectedVersion,
+	).Scan(
+		&user.UserID,
+		&user.Username,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.Phone,
+		&user.Role,
+		&user.Active,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Version,
+	)
+
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("error while updating user profile", "error", err)
+		}
+		return nil, err
+	}
+
+	if err := r.openPII(ctx, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// SoftDelete marks a user as deleted and returns the deletion time
+func (r *UserRepository) SoftDelete(ctx context.Context, userID int) (time.Time, error) {
+	query := `
+		UPDATE public."User"
+		SET "DeletedAt" = NOW(),
+			"Version" = "Version" + 1,
+			"UpdatedAt" = NOW()
+		WHERE "UserId" = $1
+		  AND "DeletedAt" IS NULL
+		RETURNING "DeletedAt"
+	`
+
+	var deletedAt time.Time
+
+	err := r.db.QueryRow(ctx, query, userID).Scan(&deletedAt)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("error while soft deleting user", "error", err)
+		}
+		return time.Time{}, err
+	}
+
+	return deletedAt, nil
+}
+
+// Restore clears the soft deletion of a user
+func (r *UserRepository) Restore(ctx context.Context, userID int) error {
+	query := `
+		UPDATE public."User"
+		SET "DeletedAt" = NULL,
+			"Version" = "Version" + 1,
+			"UpdatedAt" = NOW()
+		WHERE "UserId" = $1
+	`
+
+	_, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while restoring user", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// CountDeletedBefore counts users soft deleted before the cutoff that are not anonymized yet
+func (r *UserRepository) CountDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM public."User"
+		WHERE "DeletedAt" < $1
+		  AND "AnonymizedAt" IS NULL
+	`
+
+	var count int64
+
+	err := r.db.QueryRow(ctx, query, before).Scan(&count)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in CountDeletedBefore", "error", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// LockDeletedBefore locks up to limit users soft deleted before the cutoff that are
+// not anonymized yet and returns their IDs. Run it in a transaction.
+func (r *UserRepository) LockDeletedBefore(ctx context.Context, before time.Time, limit int) ([]int, error) {
+	query := `
+		SELECT "UserId"
+		FROM public."User"
+		WHERE "DeletedAt" < $1
+		  AND "AnonymizedAt" IS NULL
+		ORDER BY "UserId"
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := r.db.Query(ctx, query, before, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in LockDeletedBefore", "error", err)
+		return nil, err
+	}
+
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		logging.FromContext(ctx).Error("error scanning user IDs", "error", err)
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// Anonymize replaces the personal data of the users with placeholders while
+// keeping their rows, so that references to them stay valid
+func (r *UserRepository) Anonymize(ctx context.Context, userIDs []int) (int64, error) {
+	query := `
+		UPDATE public."User"
+		SET "Username" = 'deleted-' || "UserId",
+			"Email" = 'deleted-' || "UserId" || '@anonymized.invalid',
+			"Firstname" = NULL,
+			"Lastname" = NULL,
+			"Phone" = NULL,
+			"EmailIndex" = NULL,
+			"PhoneIndex" = NULL,
+			"Password" = '',
+			"Active" = False,
+			"DeletedAt" = COALESCE("DeletedAt", NOW()),
+			"AnonymizedAt" = NOW(),
+			"Version" = "Version" + 1,
+			"UpdatedAt" = NOW()
+		WHERE "UserId" = ANY($1)
+		  AND "AnonymizedAt" IS NULL
+	`
+
+	tag, err := r.db.Exec(ctx, query, userIDs)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while anonymizing users", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Identifiers returns the usernames and decrypted emails of the users, which the
+// data they left in other tables is found by
+func (r *UserRepository) Identifiers(ctx context.Context, userIDs []int) ([]string, error) {
+	query := `
+		SELECT
+			"Username",
+			"Email"
+		FROM public."User"
+		WHERE "UserId" = ANY($1)
+	`
+
+	rows, err := r.db.Query(ctx, query, userIDs)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in Identifiers", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identifiers []string
+	for rows.Next() {
+		var username, email string
+		if err := rows.Scan(&username, &email); err != nil {
+			logging.FromContext(ctx).Error("error scanning user row", "error", err)
+			return nil, err
+		}
+		if email, err = r.cipher.Decrypt(fieldEmail, email); err != nil {
+			logging.FromContext(ctx).Error("error while decrypting user email", "error", err)
+			return nil, err
+		}
+		identifiers = append(identifiers, username, email)
+	}
+
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("error iterating rows", "error", err)
+		return nil, err
+	}
+
+	return identifiers, nil
+}
+
+// Purge hard deletes the users. Rows referencing them must be deleted first.
+func (r *UserRepository) Purge(ctx context.Context, userIDs []int) (int64, error) {
+	query := `
+		DELETE FROM public."User"
+		WHERE "UserId" = ANY($1)
+	`
+
+	tag, err := r.db.Exec(ctx, query, userIDs)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while purging users", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteEmailChangeRequestsForUsers deletes every email change request of the users
+func (r *UserRepository) DeleteEmailChangeRequestsForUsers(ctx context.Context, userIDs []int) (int64, error) {
+	query := `
+		DELETE FROM public."EmailChangeRequest"
+		WHERE "UserId" = ANY($1)
+	`
+
+	tag, err := r.db.Exec(ctx, query, userIDs)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting email change requests", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteStaleEmailChangeRequests deletes up to limit email change requests that
+// expired before the cutoff. With dryRun they are only counted, without limit.
+func (r *UserRepository) DeleteStaleEmailChangeRequests(ctx context.Context, before time.Time, limit int, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := r.db.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM public."EmailChangeRequest"
+			WHERE "ExpiresAt" < $1
+		`, before).Scan(&count)
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in DeleteStaleEmailChangeRequests (count)", "error", err)
+		}
+		return count, err
+	}
+
+	query := `
+		DELETE FROM public."EmailChangeRequest"
+		WHERE "RequestId" IN (
+			SELECT "RequestId"
+			FROM public."EmailChangeRequest"
+			WHERE "ExpiresAt" < $1
+			LIMIT $2
+		)
+	`
+
+	tag, err := r.db.Exec(ctx, query, before, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting stale email change requests", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ListEmailChangeRequests returns every email change request of the user, newest first
+func (r *UserRepository) ListEmailChangeRequests(ctx context.Context, userID int) ([]EmailChangeRequest, error) {
+	query := `
+		SELECT
+			"RequestId",
+			"UserId",
+			"NewEmail",
+			"TokenHash",
+			"CreatedAt",
+			"ExpiresAt",
+			"ConfirmedAt"
+		FROM public."EmailChangeRequest"
+		WHERE "UserId" = $1
+		ORDER BY "CreatedAt" DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ListEmailChangeRequests", "error", err)
+		return nil, err
+	}
+
+	requests, err := pgx.CollectRows(rows, pgx.RowToStructByName[EmailChangeRequest])
+	if err != nil {
+		logging.FromContext(ctx).Error("error scanning email change request rows", "error", err)
+		return nil, err
+	}
+
+	for i := range requests {
+		if requests[i].NewEmail, err = r.cipher.Decrypt(fieldNewEmail, requests[i].NewEmail); err != nil {
+			logging.FromContext(ctx).Error("error while decrypting new email", "error", err)
+			return nil, err
+		}
+	}
+
+	return requests, nil
+}
+
+// sealedPII holds the stored form of a user's personal fields
+type sealedPII struct {
+	FirstName  *string
+	LastName   *string
+	Email      string
+	Phone      *string
+	EmailIndex []byte
+	PhoneIndex []byte
+}
+
+// sealPII encrypts the personal fields of user and computes their blind indexes
+func (r *UserRepository) sealPII(ctx context.Context, user *User) (*sealedPII, error) {
+	var sealed sealedPII
+
+	var errs []error
+	var err error
+	sealed.FirstName, err = r.cipher.EncryptPtr(fieldFirstName, user.FirstName)
+	errs = append(errs, err)
+	sealed.LastName, err = r.cipher.EncryptPtr(fieldLastName, user.LastName)
+	errs = append(errs, err)
+	sealed.Email, err = r.cipher.Encrypt(fieldEmail, common.NormalizeEmail(user.Email))
+	errs = append(errs, err)
+	sealed.Phone, err = r.cipher.EncryptPtr(fieldPhone, user.Phone)
+	errs = append(errs, err)
+
+	if err := errors.Join(errs...); err != nil {
+		logging.FromContext(ctx).Error("error while encrypting user", "error", err)
+		return nil, err
+	}
+
+	sealed.EmailIndex = r.emailIndex(user.Email)
+	sealed.PhoneIndex = r.cipher.BlindIndexPtr(fieldPhone, user.Phone)
+	return &sealed, nil
+}
+
+// emailIndex returns the blind index of the normalized email
+func (r *UserRepository) emailIndex(email string) []byte {
+	return r.cipher.BlindIndex(fieldEmail, common.NormalizeEmail(email))
+}
+
+// openPII decrypts the personal fields of a scanned user in place
+func (r *UserRepository) openPII(ctx context.Context, user *User) error {
+	err := errors.Join(
+		r.cipher.DecryptPtr(fieldFirstName, user.FirstName),
+		r.cipher.DecryptPtr(fieldLastName, user.LastName),
+		r.cipher.DecryptPtr(fieldEmail, &user.Email),
+		r.cipher.DecryptPtr(fieldPhone, user.Phone),
+	)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while decrypting user", "user_id", user.UserID, "error", err)
+	}
+	return err
+}
+
+// ReencryptBatch re-encrypts with the active key the personal fields of up to limit
+// users after afterID that are plaintext or sealed with a retired key, fills in
+// missing blind indexes and normalizes emails. It returns the last user ID visited, 0 when there are no
+// more users, and how many users were, or with dryRun would be, updated.
+func (r *UserRepository) ReencryptBatch(ctx context.Context, afterID, limit int, dryRun bool) (int, int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while starting transaction", "error", err)
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	type storedUser struct {
+		User
+		EmailIndex []byte
+		PhoneIndex []byte
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT
+			"UserId",
+			"Firstname",
+			"Lastname",
+			"Email",
+			"Phone",
+			"EmailIndex",
+			"PhoneIndex"
+		FROM public."User"
+		WHERE "UserId" > $1
+		ORDER BY "UserId"
+		LIMIT $2
+		FOR UPDATE
+	`, afterID, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ReencryptBatch", "error", err)
+		return 0, 0, err
+	}
+
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storedUser, error) {
+		var user storedUser
+		err := row.Scan(
+			&user.UserID,
+			&user.FirstName,
+			&user.LastName,
+			&user.Email,
+			&user.Phone,
+			&user.EmailIndex,
+			&user.PhoneIndex,
+		)
+		return user, err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("error scanning user rows", "error", err)
+		return 0, 0, err
+	}
+	if len(users) == 0 {
+		return 0, 0, nil
+	}
+
+	updated := 0
+	for _, stored := range users {
+		// Emails stored or indexed before they were normalized are rewritten too
+		email, err := r.cipher.Decrypt(fieldEmail, stored.Email)
+		if err != nil {
+			logging.FromContext(ctx).Error("error while decrypting user email", "user_id", stored.UserID, "error", err)
+			return 0, 0, err
+		}
+		stale := r.cipher.NeedsReencrypt(stored.Email) || stored.EmailIndex == nil ||
+			email != common.NormalizeEmail(email) ||
+			(stored.Phone != nil && stored.PhoneIndex == nil)
+		for _, value := range []*string{stored.FirstName, stored.LastName, stored.Phone} {
+			stale = stale || (value != nil && r.cipher.NeedsReencrypt(*value))
+		}
+		if !stale {
+			continue
+		}
+		updated++
+		if dryRun {
+			continue
+		}
+
+		user := stored.User
+		if err := r.openPII(ctx, &user); err != nil {
+			return 0, 0, err
+		}
+		sealed, err := r.sealPII(ctx, &user)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE public."User"
+			SET "Firstname" = $2,
+				"Lastname" = $3,
+				"Email" = $4,
+				"Phone" = $5,
+				"EmailIndex" = $6,
+				"PhoneIndex" = $7
+			WHERE "UserId" = $1
+		`, user.UserID, sealed.FirstName, sealed.LastName, sealed.Email, sealed.Phone, sealed.EmailIndex, sealed.PhoneIndex)
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in ReencryptBatch (update)", "error", err)
+			return 0, 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logging.FromContext(ctx).Error("database error in ReencryptBatch (commit)", "error", err)
+		return 0, 0, err
+	}
+
+	return users[len(users)-1].UserID, updated, nil
+}
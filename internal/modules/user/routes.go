@@ -1,23 +1,61 @@
 package user
 
 import (
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/session"
+	"metalcore-api/internal/password"
+	"metalcore-api/internal/pii"
+	"metalcore-api/internal/privacy"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func RegisterRoutes(rg *gin.RouterGroup, db *pgxpool.Pool) {
+func RegisterRoutes(rg *gin.RouterGroup, db *pgxpool.Pool, hasher *password.Hasher, cipher *pii.Cipher, exports *privacy.Registry, validate, idempotent, requireAuth, requireTenant gin.HandlerFunc, requireAdmin ...gin.HandlerFunc) {
 	// Initialize dependencies (Dependency Injection)
-	repo := NewUserRepository(db)
-	service := NewService(repo)
-	handler := NewHandler(service)
+	repo := NewUserRepository(db, cipher)
+	sessions := session.NewSessionRepository(db)
+	auditService := audit.NewService(audit.NewAuditRepository(db))
+	service := NewService(db, repo, sessions, auditService, exports, hasher)
+	handler := NewHandler(service, exports)
 
-	// Register routes
+	// Register routes, request bodies are validated once the caller is authorized,
+	// creating requests can be retried safely with an Idempotency-Key
 	userGroup := rg.Group("/users")
 	{
-		userGroup.GET("/:id", handler.GetByID)
-		userGroup.GET("/", handler.GetAll)
-		userGroup.POST("/", handler.Create)
-		// userGroup.PUT("/:id", handler.Update)
+		userGroup.POST("/", validate, idempotent, handler.Create)
+		userGroup.POST("/email/confirm", validate, handler.ConfirmEmailChange)
 		// userGroup.DELETE("/:id", handler.Delete)
 	}
+
+	// Routes acting on the authenticated user
+	meGroup := userGroup.Group("/me", requireAuth, validate)
+	{
+		meGroup.GET("", handler.GetMe)
+		meGroup.PATCH("", handler.UpdateMe)
+		meGroup.DELETE("", handler.DeleteMe)
+		meGroup.POST("/password", handler.ChangePassword)
+		meGroup.POST("/email", handler.ChangeEmail)
+		meGroup.GET("/export", handler.ExportMe)
+		meGroup.POST("/erase", handler.EraseMe)
+	}
+
+	// Members of the organization resolved from the request
+	memberGroup := userGroup.Group("", requireAuth, requireTenant)
+	{
+		memberGroup.GET("/:id", handler.GetByID)
+		memberGroup.GET("/", handler.GetAll)
+	}
+
+	// Administration, requireAdmin includes authentication
+	adminGroup := userGroup.Group("", requireAdmin...)
+	adminGroup.Use(validate)
+	{
+		adminGroup.POST("/import", idempotent, handler.Import)
+		adminGroup.POST("/batch", idempotent, handler.Batch)
+		adminGroup.GET("/export", handler.Export)
+		adminGroup.PUT("/:id", handler.Replace)
+		adminGroup.PATCH("/:id", handler.Patch)
+		adminGroup.POST("/:id/erase", handler.Erase)
+	}
 }
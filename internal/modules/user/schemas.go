@@ -11,30 +11,144 @@ type UserResponse struct {
 	LastName  *string    `json:"last_name,omitempty"`
 	Email     string     `json:"email"`
 	Phone     *string    `json:"phone,omitempty"`
+	Role      string     `json:"role,omitempty"`
 	Active    bool       `json:"active"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Version   int        `json:"version"` // Send back as If-Match, quoted, to update the user
 }
 
 // CreateUserRequest represents the HTTP request structure for creating a user
 type CreateUserRequest struct {
-	Username  string  `json:"username" binding:"required,min=3,max=50"`
+	Username  string  `json:"username" binding:"required,min=3,max=50,username"`
 	FirstName *string `json:"first_name" binding:"omitempty,max=100"`
 	LastName  *string `json:"last_name" binding:"omitempty,max=100"`
 	Email     string  `json:"email" binding:"required,email"`
-	Phone     *string `json:"phone" binding:"required,min=10,max=13"`
-	Password  string  `json:"password" binding:"required,min=8"`
+	Phone     *string `json:"phone" binding:"required,e164"`
+	Password  string  `json:"password" binding:"required,min=8,password_strength"`
 }
 
-// UpdateUserRequest represents the HTTP request structure for updating a user
+// UpdateUserRequest represents the HTTP request structure for updating a user, nil fields are left unchanged
+// Email is changed through the confirmation flow of ChangeEmailRequest instead
 type UpdateUserRequest struct {
 	FirstName *string `json:"first_name" binding:"omitempty,max=100"`
 	LastName  *string `json:"last_name" binding:"omitempty,max=100"`
-	Email     *string `json:"email" binding:"omitempty,email"`
-	Phone     *string `json:"phone" binding:"omitempty"`
+	Phone     *string `json:"phone" binding:"omitempty,e164"`
+	Role      *string `json:"role" binding:"omitempty,oneof=user admin"`
 	Active    *bool   `json:"active" binding:"omitempty"`
 }
 
+// ReplaceUserRequest represents the HTTP request structure for replacing the editable fields of a user,
+// omitted names and phone are cleared
+type ReplaceUserRequest struct {
+	FirstName *string `json:"first_name" binding:"omitempty,max=100"`
+	LastName  *string `json:"last_name" binding:"omitempty,max=100"`
+	Phone     *string `json:"phone" binding:"omitempty,e164"`
+	Role      string  `json:"role" binding:"required,oneof=user admin"`
+	Active    *bool   `json:"active" binding:"required"`
+}
+
+// UpdateProfileRequest represents the HTTP request structure for a user updating their own profile
+// It is the self-editable subset of UpdateUserRequest, Active can only be changed by admins
+type UpdateProfileRequest struct {
+	FirstName *string `json:"first_name" binding:"omitempty,max=100"`
+	LastName  *string `json:"last_name" binding:"omitempty,max=100"`
+	Phone     *string `json:"phone" binding:"omitempty,e164"`
+}
+
+// DeleteAccountResponse represents the HTTP response structure for a scheduled account deletion
+type DeleteAccountResponse struct {
+	DeletedAt     time.Time `json:"deleted_at"`
+	RestoreBefore time.Time `json:"restore_before"` // Logging in before this time cancels the deletion
+}
+
+// EraseAccountRequest represents the HTTP request structure for erasing the current user's account
+type EraseAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ExportRequest represents the query parameters for exporting the current user's data
+type ExportRequest struct {
+	Format string `form:"format" binding:"omitempty,oneof=json zip"` // Defaults to json
+}
+
+// ImportUsersRequest represents the query parameters for importing users
+type ImportUsersRequest struct {
+	DryRun bool `form:"dry_run"` // Only check the rows, nothing is created
+}
+
+// ImportUsersResponse reports the outcome of an import, rows with errors were not imported
+type ImportUsersResponse struct {
+	Total    int              `json:"total"`
+	Imported int              `json:"imported"` // Would be imported on a dry run
+	Failed   int              `json:"failed"`
+	DryRun   bool             `json:"dry_run"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ExportUsersRequest represents the query parameters for exporting users
+type ExportUsersRequest struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv ndjson"` // Defaults to ndjson
+}
+
+// Operations of a user batch
+const (
+	BatchDeactivate = "deactivate"
+	BatchReactivate = "reactivate"
+	BatchDelete     = "delete"
+)
+
+// Modes of a user batch
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+// BatchOperation is one action of a user batch
+type BatchOperation struct {
+	Op     string `json:"op" binding:"required,oneof=deactivate reactivate delete"`
+	UserID int    `json:"user_id" binding:"required,min=1"`
+}
+
+// BatchUsersRequest represents the HTTP request structure for applying operations to many users
+type BatchUsersRequest struct {
+	Mode       string           `json:"mode" binding:"omitempty,oneof=atomic best_effort"` // Defaults to atomic
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,dive"`
+}
+
+// BatchOperationResult is the outcome of one operation, Status is the HTTP status it would have on its own
+type BatchOperationResult struct {
+	Op     string `json:"op"`
+	UserID int    `json:"user_id"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchUsersResponse reports the outcome of a batch, in the order of its operations
+type BatchUsersResponse struct {
+	Mode      string                 `json:"mode"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+	Results   []BatchOperationResult `json:"results"`
+}
+
+// ChangePasswordRequest represents the HTTP request structure for changing the current user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,password_strength,nefield=CurrentPassword"`
+}
+
+// ChangeEmailRequest represents the HTTP request structure for requesting an email change
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"new_email" binding:"required,email"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+// ConfirmEmailChangeRequest represents the HTTP request structure for confirming an email change
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
 // ToUserResponse converts a User model to UserResponse schema
 func ToUserResponse(user *User) *UserResponse {
 	if user == nil {
@@ -48,27 +162,19 @@ func ToUserResponse(user *User) *UserResponse {
 		LastName:  user.LastName,
 		Email:     user.Email,
 		Phone:     user.Phone,
+		Role:      user.Role,
 		Active:    user.Active,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+		Version:   user.Version,
 	}
 }
 
 // ToUserListResponse converts a slice of User models to UserResponse schemas
 func ToUserListResponse(users []User) []UserResponse {
 	responses := make([]UserResponse, len(users))
-	for i, user := range users {
-		responses[i] = UserResponse{
-			UserID:    user.UserID,
-			Username:  user.Username,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			Email:     user.Email,
-			Phone:     user.Phone,
-			Active:    user.Active,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		}
+	for i := range users {
+		responses[i] = *ToUserResponse(&users[i])
 	}
 	return responses
 }
@@ -0,0 +1,12 @@
+package user
+
+import "testing"
+
+func TestToUserListResponseMatchesUserResponse(t *testing.T) {
+	users := []User{{UserID: 1, Username: "alice", Role: RoleAdmin, Active: true, Version: 3}}
+
+	got := ToUserListResponse(users)
+	if len(got) != 1 || got[0] != *ToUserResponse(&users[0]) {
+		t.Errorf("ToUserListResponse() = %+v, want %+v", got, *ToUserResponse(&users[0]))
+	}
+}
@@ -2,26 +2,87 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/idempotency"
+	"metalcore-api/internal/jobs"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/mailer"
+	"metalcore-api/internal/metrics"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/session"
+	"metalcore-api/internal/password"
+	"metalcore-api/internal/privacy"
+	"metalcore-api/internal/tenant"
+	"metalcore-api/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 var (
-	ErrUserNotFound   = errors.New("user not found")
-	ErrUserInactive   = errors.New("user is inactive")
-	ErrUsernameExists = errors.New("username already exists")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserInactive      = errors.New("user is inactive")
+	ErrUsernameExists    = errors.New("username already exists")
+	ErrEmailExists       = errors.New("email already exists")
+	ErrInvalidPassword   = errors.New("current password is incorrect")
+	ErrInvalidEmailToken = errors.New("email change token is invalid or expired")
+	ErrEmailUnchanged    = errors.New("new email matches the current email")
+	ErrVersionMismatch   = errors.New("user has been modified since it was read")
+	ErrImportConflict    = errors.New("users were created concurrently, retry the import")
+	ErrBatchRolledBack   = errors.New("rolled back, another operation of the batch failed")
+)
+
+const (
+	emailChangeTokenTTL   = 24 * time.Hour
+	emailChangeTokenBytes = 32
 )
 
 type Service struct {
-	repo *UserRepository
+	db       database.DBTX
+	repo     *UserRepository
+	sessions *session.SessionRepository
+	audit    *audit.Service
+	exports  *privacy.Registry
+	hasher   *password.Hasher
 }
 
-func NewService(repo *UserRepository) *Service {
-	return &Service{repo: repo}
+// NewService returns the user service. Erasing users also erases the data of the
+// Erasers in exports, which may be nil.
+func NewService(db database.DBTX, repo *UserRepository, sessions *session.SessionRepository, audit *audit.Service, exports *privacy.Registry, hasher *password.Hasher) *Service {
+	return &Service{db: db, repo: repo, sessions: sessions, audit: audit, exports: exports, hasher: hasher}
+}
+
+// WithTx returns a copy of the service running its queries in tx. Transactions the
+// methods start become savepoints of tx.
+func (s *Service) WithTx(tx pgx.Tx) *Service {
+	return &Service{
+		db:       tx,
+		repo:     s.repo.WithTx(tx),
+		sessions: s.sessions.WithTx(tx),
+		audit:    s.audit,
+		exports:  s.exports,
+		hasher:   s.hasher,
+	}
 }
 
 func (s *Service) GetByID(ctx context.Context, userID int) (*User, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.GetByID")
+	defer span.End()
+
 	user, err := s.repo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, ErrUserNotFound
@@ -35,8 +96,29 @@ func (s *Service) GetByID(ctx context.Context, userID int) (*User, error) {
 	return user, nil
 }
 
-func (s *Service) GetAll(ctx context.Context, page, page_size int) ([]User, int64, error) {
-	users, total_count, err := s.repo.GetAll(ctx, page, page_size)
+// GetMember returns the active user userID if they are a member of the tenant's organization
+func (s *Service) GetMember(ctx context.Context, t *tenant.Tenant, userID int) (*User, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.GetMember")
+	defer span.End()
+
+	user, err := s.repo.GetMember(ctx, t.ID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetAll returns a page of the active members of the tenant's organization
+func (s *Service) GetAll(ctx context.Context, t *tenant.Tenant, page, pageSize int) ([]User, int64, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.GetAll")
+	defer span.End()
+
+	offset := (page - 1) * pageSize
+	users, total_count, err := s.repo.GetAll(ctx, t.ID, offset, pageSize)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -47,6 +129,8 @@ func (s *Service) GetAll(ctx context.Context, page, page_size int) ([]User, int6
 }
 
 func (s *Service) Create(ctx context.Context, payload CreateUserRequest) (*User, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Create")
+	defer span.End()
 
 	exists, err := s.repo.UsernameExists(ctx, payload.Username)
 	if err != nil {
@@ -57,10 +141,21 @@ func (s *Service) Create(ctx context.Context, payload CreateUserRequest) (*User,
 		return nil, ErrUsernameExists
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword(
-		[]byte(payload.Password),
-		bcrypt.DefaultCost,
-	)
+	exists, err = s.repo.EmailExists(ctx, payload.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return nil, ErrEmailExists
+	}
+
+	hashedPassword, err := s.hasher.Hash(payload.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	phone, err := normalizePhone(payload.Phone)
 	if err != nil {
 		return nil, err
 	}
@@ -69,16 +164,877 @@ func (s *Service) Create(ctx context.Context, payload CreateUserRequest) (*User,
 		Username:  payload.Username,
 		FirstName: payload.FirstName,
 		LastName:  payload.LastName,
-		Email:     payload.Email,
-		Phone:     payload.Phone,
-		Password:  string(hashedPassword),
+		Email:     common.NormalizeEmail(payload.Email),
+		Phone:     phone,
+		Password:  hashedPassword,
 		Active:    true,
 	}
 
-	createdUser, err := s.repo.Create(ctx, user)
+	var createdUser *User
+	err = database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		createdUser, err = s.repo.WithTx(tx).Create(ctx, user)
+		if err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, tx, audit.ActionUserCreate, audit.TargetUser, strconv.Itoa(createdUser.UserID), nil, createdUser); err != nil {
+			return err
+		}
+		return PublishUserCreated(ctx, tx, createdUser)
+	})
 	if err != nil {
+		// The email was taken between the check and the insert
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "UX_User_EmailIndex" {
+			return nil, ErrEmailExists
+		}
 		return nil, err
 	}
+	metrics.UsersCreated.Inc()
 
 	return createdUser, nil
 }
+
+// ImportMaxRows is the largest number of users a single import may contain
+func ImportMaxRows() int {
+	return config.GetEnvInt("USER_IMPORT_MAX_ROWS", 10000)
+}
+
+// Import creates the users of the rows that have no Err, in one transaction. Rows whose
+// username or email is taken, by an existing user or an earlier row, get Err set and are
+// skipped. Passwords are hashed by USER_IMPORT_HASH_WORKERS goroutines. With dryRun the
+// rows are only checked. It returns how many users were, or would be, created.
+func (s *Service) Import(ctx context.Context, rows []ImportRow, dryRun bool) (int, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Import")
+	defer span.End()
+
+	var usernames, emails []string
+	for i := range rows {
+		if rows[i].Err == nil {
+			rows[i].Request.Email = common.NormalizeEmail(rows[i].Request.Email)
+			usernames = append(usernames, rows[i].Request.Username)
+			emails = append(emails, rows[i].Request.Email)
+		}
+	}
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+
+	takenUsernames, err := s.repo.ExistingUsernames(ctx, usernames)
+	if err != nil {
+		return 0, err
+	}
+	takenEmails, err := s.repo.ExistingEmails(ctx, emails)
+	if err != nil {
+		return 0, err
+	}
+
+	var valid []*ImportRow
+	for i := range rows {
+		row := &rows[i]
+		if row.Err != nil {
+			continue
+		}
+
+		switch {
+		case takenUsernames[row.Request.Username]:
+			row.Err = ErrUsernameExists
+		case takenEmails[row.Request.Email]:
+			row.Err = ErrEmailExists
+		}
+		if row.Err != nil {
+			continue
+		}
+		takenUsernames[row.Request.Username] = true
+		takenEmails[row.Request.Email] = true
+
+		if row.Request.Phone, row.Err = normalizePhone(row.Request.Phone); row.Err != nil {
+			continue
+		}
+		valid = append(valid, row)
+	}
+	if dryRun || len(valid) == 0 {
+		return len(valid), nil
+	}
+
+	users, err := s.hashImport(valid)
+	if err != nil {
+		return 0, err
+	}
+
+	err = database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		if err := s.repo.WithTx(tx).CreateMany(ctx, users); err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := s.audit.Record(ctx, tx, audit.ActionUserCreate, audit.TargetUser, strconv.Itoa(user.UserID), nil, user); err != nil {
+				return err
+			}
+			if err := PublishUserCreated(ctx, tx, user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return 0, ErrImportConflict
+		}
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	metrics.UsersCreated.Add(float64(len(users)))
+
+	return len(users), nil
+}
+
+// hashImport hashes the passwords of the rows in a bounded worker pool and returns the users to create
+func (s *Service) hashImport(rows []*ImportRow) ([]*User, error) {
+	users := make([]*User, len(rows))
+	errs := make([]error, len(rows))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range max(1, config.GetEnvInt("USER_IMPORT_HASH_WORKERS", runtime.NumCPU())) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				request := rows[i].Request
+				hashedPassword, err := s.hasher.Hash(request.Password)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				users[i] = &User{
+					Username:  request.Username,
+					FirstName: request.FirstName,
+					LastName:  request.LastName,
+					Email:     request.Email,
+					Phone:     request.Phone,
+					Password:  hashedPassword,
+					Active:    true,
+				}
+			}
+		}()
+	}
+
+	for i := range rows {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Export writes every user that is not deleted to w while reading them from the database
+func (s *Service) Export(ctx context.Context, w ExportWriter) error {
+	ctx, span := tracing.Start(ctx, "user.Service.Export")
+	defer span.End()
+
+	if err := s.repo.Each(ctx, w.Write); err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return w.Flush()
+}
+
+// BatchMaxOperations is the largest number of operations in a single batch
+func BatchMaxOperations() int {
+	return config.GetEnvInt("USER_BATCH_MAX_OPERATIONS", 100)
+}
+
+// Batch applies the operations in order and returns the error of each, nil when it
+// succeeded. Atomic batches run in one transaction and stop at the first failure,
+// which rolls back the whole batch: every other operation then fails with
+// ErrBatchRolledBack. Otherwise each operation is applied on its own. The returned
+// error is set when an atomic batch could not be committed.
+func (s *Service) Batch(ctx context.Context, operations []BatchOperation, atomic bool) ([]error, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Batch")
+	defer span.End()
+
+	results := make([]error, len(operations))
+	if !atomic {
+		for i, operation := range operations {
+			results[i] = s.apply(ctx, operation)
+		}
+		return results, nil
+	}
+
+	failed := -1
+	err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		service := s.WithTx(tx)
+		for i, operation := range operations {
+			if err := service.apply(ctx, operation); err != nil {
+				results[i] = err
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+	if failed >= 0 {
+		for i := range results {
+			if i != failed {
+				results[i] = ErrBatchRolledBack
+			}
+		}
+		return results, nil
+	}
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// apply runs a batch operation through the method of the same single-user action
+func (s *Service) apply(ctx context.Context, operation BatchOperation) error {
+	var err error
+	switch operation.Op {
+	case BatchDeactivate, BatchReactivate:
+		active := operation.Op == BatchReactivate
+		_, err = s.Patch(ctx, operation.UserID, nil, UpdateUserRequest{Active: &active})
+	case BatchDelete:
+		_, err = s.Delete(ctx, operation.UserID)
+	default:
+		err = fmt.Errorf("unknown batch operation %q", operation.Op)
+	}
+	return err
+}
+
+// DeletionGracePeriod is how long a self-deleted account can still be restored by logging in
+func DeletionGracePeriod() time.Duration {
+	return config.GetEnvDuration("USER_DELETION_GRACE_PERIOD", 30*24*time.Hour)
+}
+
+// UpdateProfile updates the user's own profile. A non-nil ifMatch must contain the current
+// version of the user, otherwise ErrVersionMismatch is returned.
+func (s *Service) UpdateProfile(ctx context.Context, userID int, ifMatch []int, payload UpdateProfileRequest) (*User, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.UpdateProfile")
+	defer span.End()
+
+	phone, err := normalizePhone(payload.Phone)
+	if err != nil {
+		return nil, err
+	}
+	payload.Phone = phone
+
+	var user *User
+	err = database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		before, err := repo.LockByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if ifMatch != nil && !slices.Contains(ifMatch, before.Version) {
+			return ErrVersionMismatch
+		}
+
+		user, err = repo.UpdateProfile(ctx, userID, &before.Version, payload)
+		if err != nil {
+			return err
+		}
+
+		// UpdateProfile does not return these, carry them over so they do not show up as changes
+		user.Password = before.Password
+		user.DeletedAt = before.DeletedAt
+
+		if err := s.audit.Record(ctx, tx, audit.ActionUserUpdateProfile, audit.TargetUser, strconv.Itoa(userID), before, user); err != nil {
+			return err
+		}
+		return PublishUserUpdated(ctx, tx, before, user)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Patch applies the non-nil fields of payload to a user on behalf of an administrator
+func (s *Service) Patch(ctx context.Context, userID int, ifMatch []int, payload UpdateUserRequest) (*User, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Patch")
+	defer span.End()
+
+	phone, err := normalizePhone(payload.Phone)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, userID, ifMatch, func(user *User) {
+		if payload.FirstName != nil {
+			user.FirstName = payload.FirstName
+		}
+		if payload.LastName != nil {
+			user.LastName = payload.LastName
+		}
+		if phone != nil {
+			user.Phone = phone
+		}
+		if payload.Role != nil {
+			user.Role = *payload.Role
+		}
+		if payload.Active != nil {
+			user.Active = *payload.Active
+		}
+	})
+}
+
+// Replace overwrites the editable fields of a user on behalf of an administrator
+func (s *Service) Replace(ctx context.Context, userID int, ifMatch []int, payload ReplaceUserRequest) (*User, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Replace")
+	defer span.End()
+
+	phone, err := normalizePhone(payload.Phone)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, userID, ifMatch, func(user *User) {
+		user.FirstName = payload.FirstName
+		user.LastName = payload.LastName
+		user.Phone = phone
+		user.Role = payload.Role
+		user.Active = *payload.Active
+	})
+}
+
+// update locks the user, checks that ifMatch contains its version (nil matches any) and
+// writes the changes made by apply. Deactivated users are signed out of all sessions.
+func (s *Service) update(ctx context.Context, userID int, ifMatch []int, apply func(*User)) (*User, error) {
+	var user *User
+	err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		before, err := repo.LockByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if before.DeletedAt != nil {
+			return pgx.ErrNoRows
+		}
+		if ifMatch != nil && !slices.Contains(ifMatch, before.Version) {
+			return ErrVersionMismatch
+		}
+
+		after := *before
+		apply(&after)
+		if err := repo.Update(ctx, &after, before.Version); err != nil {
+			return err
+		}
+
+		if err := s.audit.Record(ctx, tx, audit.ActionUserUpdate, audit.TargetUser, strconv.Itoa(userID), before, &after); err != nil {
+			return err
+		}
+		if err := PublishUserUpdated(ctx, tx, before, &after); err != nil {
+			return err
+		}
+
+		if signsOut(before, &after) {
+			if _, err := s.sessions.WithTx(tx).RevokeAll(ctx, userID); err != nil {
+				return err
+			}
+		}
+
+		user = &after
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// signsOut reports whether an update ends the user's sessions: deactivated users are
+// signed out, and so are users whose role changed, since access tokens carry the role
+// they were issued with
+func signsOut(before, after *User) bool {
+	return (before.Active && !after.Active) || before.Role != after.Role
+}
+
+// DeleteSelf soft deletes the user's own account, see Delete
+func (s *Service) DeleteSelf(ctx context.Context, userID int) (*DeleteAccountResponse, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.DeleteSelf")
+	defer span.End()
+
+	return s.Delete(ctx, userID)
+}
+
+// Delete soft deletes a user and signs out all of their sessions. The account can be
+// restored by logging in within DeletionGracePeriod.
+func (s *Service) Delete(ctx context.Context, userID int) (*DeleteAccountResponse, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Delete")
+	defer span.End()
+
+	var deletedAt time.Time
+	err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		before, err := repo.LockByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		deletedAt, err = repo.SoftDelete(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		after := *before
+		after.DeletedAt = &deletedAt
+
+		if err := s.audit.Record(ctx, tx, audit.ActionUserDelete, audit.TargetUser, strconv.Itoa(userID), before, &after); err != nil {
+			return err
+		}
+		return PublishUserDeleted(ctx, tx, userID, deletedAt)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	metrics.UsersDeleted.Inc()
+
+	if _, err := s.sessions.RevokeAll(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return &DeleteAccountResponse{
+		DeletedAt:     deletedAt,
+		RestoreBefore: deletedAt.Add(DeletionGracePeriod()),
+	}, nil
+}
+
+// ChangePassword re-authenticates the user with their current password, stores the
+// new password and revokes every session other than the one making the request
+func (s *Service) ChangePassword(ctx context.Context, userID int, sessionID int64, payload ChangePasswordRequest) error {
+	ctx, span := tracing.Start(ctx, "user.Service.ChangePassword")
+	defer span.End()
+
+	user, err := s.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.hasher.Verify(payload.CurrentPassword, user.Password); err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return ErrInvalidPassword
+		}
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(payload.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	// The hash itself is never written to the audit log, only the fact that it changed
+	err = database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		if err := s.repo.WithTx(tx).UpdatePassword(ctx, userID, hashedPassword); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, audit.ActionUserPasswordChange, audit.TargetUser, strconv.Itoa(userID), nil, nil)
+	})
+	if err != nil {
+		return err
+	}
+	metrics.PasswordChanges.Inc()
+
+	if _, err := s.sessions.RevokeAllExcept(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RequestEmailChange re-authenticates the user and sends a confirmation token to the
+// new address. The email is only changed once the token is confirmed.
+func (s *Service) RequestEmailChange(ctx context.Context, userID int, payload ChangeEmailRequest) error {
+	ctx, span := tracing.Start(ctx, "user.Service.RequestEmailChange")
+	defer span.End()
+
+	user, err := s.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.hasher.Verify(payload.CurrentPassword, user.Password); err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return ErrInvalidPassword
+		}
+		return err
+	}
+
+	payload.NewEmail = common.NormalizeEmail(payload.NewEmail)
+	if payload.NewEmail == common.NormalizeEmail(user.Email) {
+		return ErrEmailUnchanged
+	}
+
+	exists, err := s.repo.EmailExists(ctx, payload.NewEmail)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrEmailExists
+	}
+
+	// The token is issued by the job that mails it, so that it is never stored in
+	// the queue. Until then the request holds the hash of a token nobody knows.
+	_, placeholderHash, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	// The confirmation email is queued with the request so that it is sent exactly when the request exists
+	err = database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		request, err := s.repo.WithTx(tx).CreateEmailChangeRequest(ctx, &EmailChangeRequest{
+			UserID:    userID,
+			NewEmail:  payload.NewEmail,
+			TokenHash: placeholderHash,
+			ExpiresAt: time.Now().Add(emailChangeTokenTTL),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = jobs.Enqueue(ctx, tx, SendEmailChangeConfirmationArgs{RequestID: request.RequestID}, jobs.WithPriority(10), jobs.WithReference(jobReference(userID)))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	metrics.EmailChanges.WithLabelValues("requested").Inc()
+
+	return nil
+}
+
+// SendEmailChangeConfirmation issues a new token for the pending email change request
+// and mails its confirmation link to the new address. Links mailed before stop
+// working. Requests that were confirmed or expired in the meantime are skipped.
+func (s *Service) SendEmailChangeConfirmation(ctx context.Context, m mailer.Mailer, requestID int64) error {
+	ctx, span := tracing.Start(ctx, "user.Service.SendEmailChangeConfirmation")
+	defer span.End()
+
+	rawToken, tokenHash, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	request, username, err := s.repo.ReissueEmailChangeToken(ctx, requestID, tokenHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Info("email change request is no longer pending, confirmation not sent", "request_id", requestID)
+			return nil
+		}
+		return err
+	}
+
+	confirmURL := fmt.Sprintf("%s/confirm-email?token=%s", config.GetEnv("APP_BASE_URL", "http://localhost:8090"), rawToken)
+	return m.Send(ctx, mailer.Message{
+		To:      request.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your new email address by opening the link below within %s:\n\n%s\n\nIf you did not request this change, you can ignore this email.\n",
+			username, emailChangeTokenTTL, confirmURL,
+		),
+	})
+}
+
+// ConfirmEmailChange applies the pending email change for the token and notifies the previous address
+func (s *Service) ConfirmEmailChange(ctx context.Context, payload ConfirmEmailChangeRequest) (*User, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.ConfirmEmailChange")
+	defer span.End()
+
+	var user *User
+	var oldEmail string
+	err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		changed, previous, err := repo.ConfirmEmailChange(ctx, hashToken(payload.Token))
+		if err != nil {
+			return err
+		}
+		oldEmail = previous
+
+		err = s.audit.Record(
+			ctx,
+			tx,
+			audit.ActionUserEmailChange,
+			audit.TargetUser,
+			strconv.Itoa(changed.UserID),
+			map[string]string{"email": oldEmail},
+			map[string]string{"email": changed.Email},
+		)
+		if err != nil {
+			return err
+		}
+
+		user, err = repo.LockByID(ctx, changed.UserID)
+		if err != nil {
+			return err
+		}
+		before := *user
+		before.Email = oldEmail
+		if err := PublishUserUpdated(ctx, tx, &before, user); err != nil {
+			return err
+		}
+
+		return sendEmail(ctx, tx, user.UserID, mailer.Message{
+			To:      oldEmail,
+			Subject: "Your email address was changed",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nThe email address on your account was changed to %s.\n\nIf you did not make this change, please contact support immediately.\n",
+				user.Username, user.Email,
+			),
+		})
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidEmailToken
+		}
+		return nil, err
+	}
+	metrics.EmailChanges.WithLabelValues("confirmed").Inc()
+
+	return user, nil
+}
+
+// Retention modes for soft deleted users past their retention period
+const (
+	RetentionAnonymize = "anonymize"
+	RetentionPurge     = "purge"
+)
+
+// EraseDeleted anonymizes or purges, depending on mode, the users soft deleted before
+// the cutoff, in batches of batchSize. Their sessions and pending email changes are
+// deleted and the snapshots in their audit entries are redacted. It returns how many
+// users were erased, or with dryRun how many would be.
+func (s *Service) EraseDeleted(ctx context.Context, before time.Time, mode string, batchSize int, dryRun bool) (int64, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.EraseDeleted")
+	defer span.End()
+
+	if dryRun {
+		return s.repo.CountDeletedBefore(ctx, before)
+	}
+
+	action := audit.ActionUserAnonymize
+	if mode == RetentionPurge {
+		action = audit.ActionUserPurge
+	}
+
+	var total int64
+	for {
+		var erased int64
+		err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+			userIDs, err := s.repo.WithTx(tx).LockDeletedBefore(ctx, before, batchSize)
+			if err != nil || len(userIDs) == 0 {
+				return err
+			}
+
+			erased, err = s.eraseUsers(ctx, tx, userIDs, mode, action)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += erased
+		if erased < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// Erase anonymizes the user right away, on request of the user or an administrator.
+// The row is kept so that references to it stay valid, but every personal field is
+// cleared, sessions and pending email changes are deleted and the snapshots in the
+// user's audit entries are redacted.
+func (s *Service) Erase(ctx context.Context, userID int) error {
+	ctx, span := tracing.Start(ctx, "user.Service.Erase")
+	defer span.End()
+
+	err := database.WithTx(ctx, s.db, func(tx pgx.Tx) error {
+		if _, err := s.repo.WithTx(tx).LockByID(ctx, userID); err != nil {
+			return err
+		}
+
+		erased, err := s.eraseUsers(ctx, tx, []int{userID}, RetentionAnonymize, audit.ActionUserErase)
+		if err != nil {
+			return err
+		}
+		if erased == 0 {
+			// Already anonymized
+			return pgx.ErrNoRows
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	metrics.UsersErased.Inc()
+	return nil
+}
+
+// EraseSelf re-authenticates the user with their password and erases their account
+func (s *Service) EraseSelf(ctx context.Context, userID int, payload EraseAccountRequest) error {
+	ctx, span := tracing.Start(ctx, "user.Service.EraseSelf")
+	defer span.End()
+
+	user, err := s.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.hasher.Verify(payload.Password, user.Password); err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return ErrInvalidPassword
+		}
+		return err
+	}
+
+	return s.Erase(ctx, userID)
+}
+
+// eraseUsers anonymizes or purges the locked users within tx, records action in the
+// audit log and publishes a UserErased event for each. It returns how many were erased.
+func (s *Service) eraseUsers(ctx context.Context, tx pgx.Tx, userIDs []int, mode, action string) (int64, error) {
+	repo := s.repo.WithTx(tx)
+
+	if _, err := s.sessions.WithTx(tx).DeleteForUsers(ctx, userIDs); err != nil {
+		return 0, err
+	}
+	if _, err := repo.DeleteEmailChangeRequestsForUsers(ctx, userIDs); err != nil {
+		return 0, err
+	}
+	// Memberships and invitations, before the users' emails are cleared
+	if err := s.exports.Erase(ctx, tx, userIDs); err != nil {
+		return 0, err
+	}
+
+	// Emails queued or sent to the users, and stored responses that could replay
+	// their data. Events and webhook deliveries hold no personal data.
+	references := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		references[i] = jobReference(userID)
+	}
+	if _, err := jobs.DeleteByReference(ctx, tx, references); err != nil {
+		return 0, err
+	}
+	identifiers, err := repo.Identifiers(ctx, userIDs)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := idempotency.NewStore(tx).DeleteMentioning(ctx, identifiers); err != nil {
+		return 0, err
+	}
+
+	var erased int64
+	if mode == RetentionPurge {
+		erased, err = repo.Purge(ctx, userIDs)
+	} else {
+		erased, err = repo.Anonymize(ctx, userIDs)
+	}
+	if err != nil || erased == 0 {
+		return 0, err
+	}
+
+	erasedAt := time.Now()
+	for _, userID := range userIDs {
+		targetID := strconv.Itoa(userID)
+		if err := s.audit.RedactTarget(ctx, tx, audit.TargetUser, targetID); err != nil {
+			return 0, err
+		}
+		if err := s.audit.Record(ctx, tx, action, audit.TargetUser, targetID, nil, nil); err != nil {
+			return 0, err
+		}
+		if err := PublishUserErased(ctx, tx, userID, erasedAt); err != nil {
+			return 0, err
+		}
+	}
+
+	return erased, nil
+}
+
+// PurgeStaleEmailChangeRequests deletes email change requests that expired before the
+// cutoff and returns how many were deleted, or with dryRun how many would be
+func (s *Service) PurgeStaleEmailChangeRequests(ctx context.Context, before time.Time, batchSize int, dryRun bool) (int64, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.PurgeStaleEmailChangeRequests")
+	defer span.End()
+
+	if dryRun {
+		return s.repo.DeleteStaleEmailChangeRequests(ctx, before, batchSize, true)
+	}
+
+	var total int64
+	for {
+		deleted, err := s.repo.DeleteStaleEmailChangeRequests(ctx, before, batchSize, false)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		if deleted < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// sendEmail queues msg to the user for background delivery within tx
+func sendEmail(ctx context.Context, tx pgx.Tx, userID int, msg mailer.Message) error {
+	_, err := jobs.Enqueue(ctx, tx, mailer.SendEmailArgs{Message: msg}, jobs.WithPriority(10), jobs.WithReference(jobReference(userID)))
+	return err
+}
+
+// jobReference references the user in the jobs about it, which erasure deletes
+func jobReference(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+// normalizePhone stores phone numbers in E.164 so that equal numbers compare equal
+func normalizePhone(phone *string) (*string, error) {
+	if phone == nil {
+		return nil, nil
+	}
+
+	normalized, err := common.NormalizePhone(*phone)
+	if err != nil {
+		return nil, err
+	}
+	return &normalized, nil
+}
+
+// generateToken returns a random URL-safe token and the hex SHA-256 hash that is stored in its place
+func generateToken() (string, string, error) {
+	raw := make([]byte, emailChangeTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
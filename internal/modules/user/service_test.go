@@ -0,0 +1,23 @@
+package user
+
+import "testing"
+
+func TestUpdateSignsOut(t *testing.T) {
+	tests := []struct {
+		name   string
+		before User
+		after  User
+		want   bool
+	}{
+		{"profile change", User{Role: RoleAdmin, Active: true}, User{Role: RoleAdmin, Active: true}, false},
+		{"deactivated", User{Role: RoleUser, Active: true}, User{Role: RoleUser, Active: false}, true},
+		{"reactivated", User{Role: RoleUser, Active: false}, User{Role: RoleUser, Active: true}, false},
+		{"demoted", User{Role: RoleAdmin, Active: true}, User{Role: RoleUser, Active: true}, true},
+		{"promoted", User{Role: RoleUser, Active: true}, User{Role: RoleAdmin, Active: true}, true},
+	}
+	for _, tt := range tests {
+		if got := signsOut(&tt.before, &tt.after); got != tt.want {
+			t.Errorf("%s: signsOut() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
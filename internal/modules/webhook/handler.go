@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"metalcore-api/internal/common"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateEndpoint(c *gin.Context) {
+	var payload CreateEndpointRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	endpoint, err := h.service.CreateEndpoint(c.Request.Context(), payload)
+	if err != nil {
+		h.endpointError(c, err)
+		return
+	}
+
+	response := ToEndpointResponse(endpoint)
+	response.Secret = endpoint.Secret
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "webhook endpoint has been created, store the secret now as it is not shown again.",
+		"data":    response,
+	})
+}
+
+func (h *Handler) ListEndpoints(c *gin.Context) {
+	var pagination common.PaginationRequest
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid pagination parameters",
+			Message: err.Error(),
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	endpoints, total, err := h.service.ListEndpoints(c.Request.Context(), pagination.GetPage(), pagination.GetPageSize())
+	if err != nil {
+		h.endpointError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, common.PaginatedResponse{
+		Data:       ToEndpointListResponse(endpoints),
+		Pagination: common.NewPaginationMetadata(&pagination, total),
+	})
+}
+
+func (h *Handler) GetEndpoint(c *gin.Context) {
+	endpointID, ok := idParam(c, "Invalid endpoint ID")
+	if !ok {
+		return
+	}
+
+	endpoint, err := h.service.GetEndpoint(c.Request.Context(), endpointID)
+	if err != nil {
+		h.endpointError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": ToEndpointResponse(endpoint),
+	})
+}
+
+func (h *Handler) UpdateEndpoint(c *gin.Context) {
+	endpointID, ok := idParam(c, "Invalid endpoint ID")
+	if !ok {
+		return
+	}
+
+	var payload UpdateEndpointRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Validation failed",
+			Message: "Please check the input fields",
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	endpoint, rotated, err := h.service.UpdateEndpoint(c.Request.Context(), endpointID, payload)
+	if err != nil {
+		h.endpointError(c, err)
+		return
+	}
+
+	response := ToEndpointResponse(endpoint)
+	if rotated {
+		response.Secret = endpoint.Secret
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "webhook endpoint has been updated.",
+		"data":    response,
+	})
+}
+
+func (h *Handler) DeleteEndpoint(c *gin.Context) {
+	endpointID, ok := idParam(c, "Invalid endpoint ID")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteEndpoint(c.Request.Context(), endpointID); err != nil {
+		h.endpointError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "webhook endpoint has been deleted.",
+	})
+}
+
+func (h *Handler) ListDeliveries(c *gin.Context) {
+	var filter ListDeliveriesRequest
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid query parameters",
+			Message: err.Error(),
+			Details: common.FormatValidationErrors(err, c.GetHeader("Accept-Language")),
+		})
+		return
+	}
+
+	deliveries, total, err := h.service.ListDeliveries(c.Request.Context(), filter)
+	if err != nil {
+		h.endpointError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, common.PaginatedResponse{
+		Data:       ToDeliveryListResponse(deliveries),
+		Pagination: common.NewPaginationMetadata(&filter.PaginationRequest, total),
+	})
+}
+
+func (h *Handler) ReplayDelivery(c *gin.Context) {
+	deliveryID, ok := idParam(c, "Invalid delivery ID")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.service.ReplayDelivery(c.Request.Context(), deliveryID)
+	if err != nil {
+		h.endpointError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "webhook delivery has been queued again.",
+		"data":    ToDeliveryResponse(delivery),
+	})
+}
+
+// endpointError maps service errors to responses
+func (h *Handler) endpointError(c *gin.Context, err error) {
+	switch err {
+	case ErrEndpointNotFound:
+		c.JSON(http.StatusNotFound, common.ErrorResponse{
+			Status: http.StatusNotFound,
+			Error:  "Webhook endpoint not found",
+		})
+	case ErrDeliveryNotFound:
+		c.JSON(http.StatusNotFound, common.ErrorResponse{
+			Status: http.StatusNotFound,
+			Error:  "Webhook delivery not found",
+		})
+	case ErrInvalidURL:
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid URL",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{
+			Status:  http.StatusInternalServerError,
+			Error:   "Internal server error",
+			Message: "An unexpected error occurred",
+		})
+	}
+}
+
+func idParam(c *gin.Context, message string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{
+			Status: http.StatusBadRequest,
+			Error:  message,
+		})
+		return 0, false
+	}
+	return id, true
+}
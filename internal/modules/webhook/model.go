@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// Endpoint is a registered receiver of webhook deliveries
+type Endpoint struct {
+	EndpointID  int64      `db:"EndpointId" json:"endpoint_id"`
+	URL         string     `db:"Url" json:"url"`
+	Secret      string     `db:"Secret" json:"-"` // only returned when the endpoint is created
+	Events      []string   `db:"Events" json:"events"`
+	Description *string    `db:"Description" json:"description,omitempty"`
+	Active      bool       `db:"Active" json:"active"`
+	CreatedAt   time.Time  `db:"CreatedAt" json:"created_at"`
+	UpdatedAt   *time.Time `db:"UpdatedAt" json:"updated_at,omitempty"`
+	DeletedAt   *time.Time `db:"DeletedAt" json:"deleted_at,omitempty"`
+}
+
+// Subscribes reports whether the endpoint wants events of eventType. An
+// endpoint without event filters receives every event.
+func (e *Endpoint) Subscribes(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, filter := range e.Events {
+		if filter == eventType || filter == AllEvents {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is one event queued for one endpoint, together with the outcome of its last attempt
+type Delivery struct {
+	DeliveryID     int64           `db:"DeliveryId" json:"delivery_id"`
+	EndpointID     int64           `db:"EndpointId" json:"endpoint_id"`
+	EventID        int64           `db:"EventId" json:"event_id"`
+	EventType      string          `db:"EventType" json:"event_type"`
+	Payload        json.RawMessage `db:"Payload" json:"payload"`
+	Status         string          `db:"Status" json:"status"`
+	Attempts       int             `db:"Attempts" json:"attempts"`
+	NextAttemptAt  time.Time       `db:"NextAttemptAt" json:"next_attempt_at"`
+	LastStatusCode *int            `db:"LastStatusCode" json:"last_status_code,omitempty"`
+	LastError      *string         `db:"LastError" json:"last_error,omitempty"`
+	CreatedAt      time.Time       `db:"CreatedAt" json:"created_at"`
+	UpdatedAt      *time.Time      `db:"UpdatedAt" json:"updated_at,omitempty"`
+	DeliveredAt    *time.Time      `db:"DeliveredAt" json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,519 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type WebhookRepository struct {
+	db database.DBTX
+}
+
+func NewWebhookRepository(db database.DBTX) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// WithTx returns a repository that runs its queries in tx
+func (r *WebhookRepository) WithTx(tx pgx.Tx) *WebhookRepository {
+	return &WebhookRepository{db: tx}
+}
+
+const endpointColumns = `
+			"EndpointId",
+			"Url",
+			"Secret",
+			"Events",
+			"Description",
+			"Active",
+			"CreatedAt",
+			"UpdatedAt",
+			"DeletedAt"`
+
+func scanEndpoint(row pgx.Row, endpoint *Endpoint) error {
+	return row.Scan(
+		&endpoint.EndpointID,
+		&endpoint.URL,
+		&endpoint.Secret,
+		&endpoint.Events,
+		&endpoint.Description,
+		&endpoint.Active,
+		&endpoint.CreatedAt,
+		&endpoint.UpdatedAt,
+		&endpoint.DeletedAt,
+	)
+}
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, endpoint *Endpoint) (*Endpoint, error) {
+	query := `
+		INSERT INTO public."WebhookEndpoint" (
+			"Url",
+			"Secret",
+			"Events",
+			"Description",
+			"Active"
+		)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING` + endpointColumns
+
+	var created Endpoint
+
+	err := scanEndpoint(r.db.QueryRow(
+		ctx,
+		query,
+		endpoint.URL,
+		endpoint.Secret,
+		endpoint.Events,
+		endpoint.Description,
+		endpoint.Active,
+	), &created)
+
+	if err != nil {
+		logging.FromContext(ctx).Error("error while creating webhook endpoint", "error", err)
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (r *WebhookRepository) GetEndpoint(ctx context.Context, endpointID int64) (*Endpoint, error) {
+	query := `
+		SELECT` + endpointColumns + `
+		FROM public."WebhookEndpoint"
+		WHERE "EndpointId" = $1
+		  AND "DeletedAt" IS NULL
+	`
+
+	var endpoint Endpoint
+
+	err := scanEndpoint(r.db.QueryRow(ctx, query, endpointID), &endpoint)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("database error in GetEndpoint", "error", err)
+		}
+		return nil, err
+	}
+
+	return &endpoint, nil
+}
+
+func (r *WebhookRepository) ListEndpoints(ctx context.Context, offset, limit int) ([]Endpoint, int64, error) {
+	var totalCount int64
+	countQuery := `
+		SELECT COUNT(*)
+		FROM public."WebhookEndpoint"
+		WHERE "DeletedAt" IS NULL
+	`
+
+	err := r.db.QueryRow(ctx, countQuery).Scan(&totalCount)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ListEndpoints (count)", "error", err)
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT` + endpointColumns + `
+		FROM public."WebhookEndpoint"
+		WHERE "DeletedAt" IS NULL
+		ORDER BY "EndpointId"
+		LIMIT $1 OFFSET $2
+	`
+
+	endpoints, err := r.queryEndpoints(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return endpoints, totalCount, nil
+}
+
+// ActiveEndpoints returns every endpoint that currently receives deliveries
+func (r *WebhookRepository) ActiveEndpoints(ctx context.Context) ([]Endpoint, error) {
+	query := `
+		SELECT` + endpointColumns + `
+		FROM public."WebhookEndpoint"
+		WHERE "DeletedAt" IS NULL
+		  AND "Active" = True
+	`
+
+	return r.queryEndpoints(ctx, query)
+}
+
+func (r *WebhookRepository) queryEndpoints(ctx context.Context, query string, args ...any) ([]Endpoint, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error while listing webhook endpoints", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []Endpoint
+	for rows.Next() {
+		var endpoint Endpoint
+		if err := scanEndpoint(rows, &endpoint); err != nil {
+			logging.FromContext(ctx).Error("error scanning webhook endpoint row", "error", err)
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("error iterating rows", "error", err)
+		return nil, err
+	}
+
+	return endpoints, nil
+}
+
+// UpdateEndpoint updates an endpoint, nil fields are left unchanged
+func (r *WebhookRepository) UpdateEndpoint(ctx context.Context, endpointID int64, payload UpdateEndpointRequest, secret *string) (*Endpoint, error) {
+	query := `
+		UPDATE public."WebhookEndpoint"
+		SET "Url" = COALESCE($2, "Url"),
+			"Events" = COALESCE($3, "Events"),
+			"Description" = COALESCE($4, "Description"),
+			"Active" = COALESCE($5, "Active"),
+			"Secret" = COALESCE($6, "Secret"),
+			"UpdatedAt" = NOW()
+		WHERE "EndpointId" = $1
+		  AND "DeletedAt" IS NULL
+		RETURNING` + endpointColumns
+
+	var events []string
+	if payload.Events != nil {
+		events = *payload.Events
+		if events == nil {
+			events = []string{}
+		}
+	}
+
+	var endpoint Endpoint
+
+	err := scanEndpoint(r.db.QueryRow(
+		ctx,
+		query,
+		endpointID,
+		payload.URL,
+		events,
+		payload.Description,
+		payload.Active,
+		secret,
+	), &endpoint)
+
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("error while updating webhook endpoint", "error", err)
+		}
+		return nil, err
+	}
+
+	return &endpoint, nil
+}
+
+// DeleteEndpoint soft deletes an endpoint so that its delivery log is kept
+func (r *WebhookRepository) DeleteEndpoint(ctx context.Context, endpointID int64) error {
+	query := `
+		UPDATE public."WebhookEndpoint"
+		SET "DeletedAt" = NOW(),
+			"Active" = False,
+			"UpdatedAt" = NOW()
+		WHERE "EndpointId" = $1
+		  AND "DeletedAt" IS NULL
+	`
+
+	tag, err := r.db.Exec(ctx, query, endpointID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting webhook endpoint", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// CreateDelivery queues an event for an endpoint. Queuing the same event for the
+// same endpoint again is a no-op, so redelivered outbox events are not sent twice.
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *Delivery) error {
+	query := `
+		INSERT INTO public."WebhookDelivery" (
+			"EndpointId",
+			"EventId",
+			"EventType",
+			"Payload"
+		)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT ("EndpointId", "EventId") DO NOTHING
+	`
+
+	_, err := r.db.Exec(ctx, query, delivery.EndpointID, delivery.EventID, delivery.EventType, delivery.Payload)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while creating webhook delivery", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+const deliveryColumns = `
+			d."DeliveryId",
+			d."EndpointId",
+			d."EventId",
+			d."EventType",
+			d."Payload",
+			d."Status",
+			d."Attempts",
+			d."NextAttemptAt",
+			d."LastStatusCode",
+			d."LastError",
+			d."CreatedAt",
+			d."UpdatedAt",
+			d."DeliveredAt"`
+
+func deliveryFields(delivery *Delivery) []any {
+	return []any{
+		&delivery.DeliveryID,
+		&delivery.EndpointID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+		&delivery.DeliveredAt,
+	}
+}
+
+// dueDelivery is a claimed delivery together with the endpoint it is sent to
+type dueDelivery struct {
+	Delivery Delivery
+	Endpoint Endpoint
+}
+
+// ClaimDue leases up to limit due deliveries of active endpoints: they stay pending
+// but are not due again before the lease expires. The claim commits right away, the
+// deliveries are sent outside any transaction and each attempt is recorded on its own.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]dueDelivery, error) {
+	query := `
+		WITH due AS (
+			SELECT d."DeliveryId"
+			FROM public."WebhookDelivery" d
+			JOIN public."WebhookEndpoint" e ON e."EndpointId" = d."EndpointId"
+			WHERE d."Status" = 'pending'
+			  AND d."NextAttemptAt" <= NOW()
+			  AND e."Active" = True
+			  AND e."DeletedAt" IS NULL
+			ORDER BY d."NextAttemptAt", d."DeliveryId"
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		), d AS (
+			UPDATE public."WebhookDelivery" claimed
+			SET "NextAttemptAt" = NOW() + $2::INTERVAL
+			FROM due
+			WHERE claimed."DeliveryId" = due."DeliveryId"
+			RETURNING claimed.*
+		)
+		SELECT` + deliveryColumns + `,
+			e."Url",
+			e."Secret"
+		FROM d
+		JOIN public."WebhookEndpoint" e ON e."EndpointId" = d."EndpointId"
+		ORDER BY d."DeliveryId"
+	`
+
+	rows, err := r.db.Query(ctx, query, limit, lease)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ClaimDue", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []dueDelivery
+	for rows.Next() {
+		var item dueDelivery
+		fields := append(deliveryFields(&item.Delivery), &item.Endpoint.URL, &item.Endpoint.Secret)
+		if err := rows.Scan(fields...); err != nil {
+			logging.FromContext(ctx).Error("error scanning webhook delivery row", "error", err)
+			return nil, err
+		}
+		item.Endpoint.EndpointID = item.Delivery.EndpointID
+		due = append(due, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("error iterating rows", "error", err)
+		return nil, err
+	}
+
+	return due, nil
+}
+
+// RecordAttempt stores the outcome of a delivery attempt made under the lease that
+// expires at leasedUntil. It returns pgx.ErrNoRows when the lease was lost, because
+// it expired and the delivery was claimed again, or the delivery was replayed.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, deliveryID int64, leasedUntil time.Time, status string, statusCode *int, lastError *string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE public."WebhookDelivery"
+		SET "Status" = $3,
+			"Attempts" = "Attempts" + 1,
+			"LastStatusCode" = $4,
+			"LastError" = $5,
+			"NextAttemptAt" = $6,
+			"DeliveredAt" = CASE WHEN $3 = 'succeeded' THEN NOW() ELSE "DeliveredAt" END,
+			"UpdatedAt" = NOW()
+		WHERE "DeliveryId" = $1
+		  AND "Status" = 'pending'
+		  AND "NextAttemptAt" = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, deliveryID, leasedUntil, status, statusCode, lastError, nextAttemptAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while recording webhook delivery attempt", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, filter ListDeliveriesRequest) ([]Delivery, int64, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.EndpointID != nil {
+		addCondition(`d."EndpointId" = $%d`, *filter.EndpointID)
+	}
+	if filter.Status != "" {
+		addCondition(`d."Status" = $%d`, filter.Status)
+	}
+	if filter.EventType != "" {
+		addCondition(`d."EventType" = $%d`, filter.EventType)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var totalCount int64
+	countQuery := `SELECT COUNT(*) FROM public."WebhookDelivery" d ` + where
+
+	err := r.db.QueryRow(ctx, countQuery, args...).Scan(&totalCount)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ListDeliveries (count)", "error", err)
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT`+deliveryColumns+`
+		FROM public."WebhookDelivery" d
+		%s
+		ORDER BY d."DeliveryId" DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+
+	rows, err := r.db.Query(ctx, query, append(args, filter.GetLimit(), filter.GetOffset())...)
+	if err != nil {
+		logging.FromContext(ctx).Error("database error in ListDeliveries", "error", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var delivery Delivery
+		if err := rows.Scan(deliveryFields(&delivery)...); err != nil {
+			logging.FromContext(ctx).Error("error scanning webhook delivery row", "error", err)
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("error iterating rows", "error", err)
+		return nil, 0, err
+	}
+
+	return deliveries, totalCount, nil
+}
+
+// ResetDelivery puts a delivery back in the queue with a fresh attempt budget.
+// Returns pgx.ErrNoRows when the delivery does not exist.
+func (r *WebhookRepository) ResetDelivery(ctx context.Context, deliveryID int64) (*Delivery, error) {
+	query := `
+		UPDATE public."WebhookDelivery" d
+		SET "Status" = 'pending',
+			"Attempts" = 0,
+			"NextAttemptAt" = NOW(),
+			"UpdatedAt" = NOW()
+		WHERE d."DeliveryId" = $1
+		RETURNING` + deliveryColumns
+
+	var delivery Delivery
+
+	err := r.db.QueryRow(ctx, query, deliveryID).Scan(deliveryFields(&delivery)...)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logging.FromContext(ctx).Error("error while resetting webhook delivery", "error", err)
+		}
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// DeleteFinishedDeliveries deletes up to limit deliveries that succeeded or were
+// dead-lettered before before, or only counts them in dry run mode
+func (r *WebhookRepository) DeleteFinishedDeliveries(ctx context.Context, before time.Time, limit int, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := r.db.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM public."WebhookDelivery"
+			WHERE "Status" IN ('succeeded', 'dead')
+			  AND "UpdatedAt" < $1
+		`, before).Scan(&count)
+		if err != nil {
+			logging.FromContext(ctx).Error("database error in DeleteFinishedDeliveries (count)", "error", err)
+		}
+		return count, err
+	}
+
+	query := `
+		DELETE FROM public."WebhookDelivery"
+		WHERE "DeliveryId" IN (
+			SELECT "DeliveryId"
+			FROM public."WebhookDelivery"
+			WHERE "Status" IN ('succeeded', 'dead')
+			  AND "UpdatedAt" < $1
+			LIMIT $2
+		)
+	`
+
+	tag, err := r.db.Exec(ctx, query, before, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while deleting finished webhook deliveries", "error", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
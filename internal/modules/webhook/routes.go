@@ -0,0 +1,26 @@
+package webhook
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func RegisterRoutes(rg *gin.RouterGroup, db *pgxpool.Pool, validate, idempotent gin.HandlerFunc, requireAdmin ...gin.HandlerFunc) {
+	// Initialize dependencies (Dependency Injection)
+	repo := NewWebhookRepository(db)
+	service := NewService(repo)
+	handler := NewHandler(service)
+
+	// Register routes, webhooks are managed by administrators
+	webhookGroup := rg.Group("/webhooks", requireAdmin...)
+	webhookGroup.Use(validate)
+	{
+		webhookGroup.POST("/endpoints", idempotent, handler.CreateEndpoint)
+		webhookGroup.GET("/endpoints", handler.ListEndpoints)
+		webhookGroup.GET("/endpoints/:id", handler.GetEndpoint)
+		webhookGroup.PATCH("/endpoints/:id", handler.UpdateEndpoint)
+		webhookGroup.DELETE("/endpoints/:id", handler.DeleteEndpoint)
+		webhookGroup.GET("/deliveries", handler.ListDeliveries)
+		webhookGroup.POST("/deliveries/:id/replay", handler.ReplayDelivery)
+	}
+}
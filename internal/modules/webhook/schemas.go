@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"encoding/json"
+	"metalcore-api/internal/common"
+	"time"
+)
+
+// AllEvents subscribes an endpoint to every event type
+const AllEvents = "*"
+
+// CreateEndpointRequest represents the HTTP request structure for registering a webhook endpoint
+type CreateEndpointRequest struct {
+	URL         string   `json:"url" binding:"required,url,max=2048"`
+	Events      []string `json:"events" binding:"omitempty,dive,oneof=* user.created user.updated user.deleted user.erased"` // Empty receives every event
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Active      *bool    `json:"active"` // Defaults to true
+}
+
+// UpdateEndpointRequest represents the HTTP request structure for updating a webhook endpoint,
+// omitted fields are left unchanged
+type UpdateEndpointRequest struct {
+	URL          *string   `json:"url" binding:"omitempty,url,max=2048"`
+	Events       *[]string `json:"events" binding:"omitempty,dive,oneof=* user.created user.updated user.deleted user.erased"`
+	Description  *string   `json:"description" binding:"omitempty,max=255"`
+	Active       *bool     `json:"active"`
+	RotateSecret bool      `json:"rotate_secret"` // Generates a new signing secret, returned in the response
+}
+
+// EndpointResponse represents the HTTP response structure for a webhook endpoint
+type EndpointResponse struct {
+	EndpointID  int64      `json:"endpoint_id"`
+	URL         string     `json:"url"`
+	Events      []string   `json:"events"`
+	Description *string    `json:"description,omitempty"`
+	Active      bool       `json:"active"`
+	Secret      string     `json:"secret,omitempty"` // Only set when created or rotated
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// ListDeliveriesRequest represents the query parameters for the delivery log
+type ListDeliveriesRequest struct {
+	common.PaginationRequest
+	EndpointID *int64 `form:"endpoint_id" binding:"omitempty,min=1"`
+	Status     string `form:"status" binding:"omitempty,oneof=pending succeeded dead"`
+	EventType  string `form:"event_type" binding:"omitempty,max=100"`
+}
+
+// DeliveryResponse represents the HTTP response structure for a webhook delivery
+type DeliveryResponse struct {
+	DeliveryID     int64           `json:"delivery_id"`
+	EndpointID     int64           `json:"endpoint_id"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// envelope is the JSON body POSTed to endpoints
+type envelope struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// ToEndpointResponse converts an Endpoint model to an EndpointResponse schema
+func ToEndpointResponse(endpoint *Endpoint) *EndpointResponse {
+	if endpoint == nil {
+		return nil
+	}
+
+	events := endpoint.Events
+	if events == nil {
+		events = []string{}
+	}
+
+	return &EndpointResponse{
+		EndpointID:  endpoint.EndpointID,
+		URL:         endpoint.URL,
+		Events:      events,
+		Description: endpoint.Description,
+		Active:      endpoint.Active,
+		CreatedAt:   endpoint.CreatedAt,
+		UpdatedAt:   endpoint.UpdatedAt,
+	}
+}
+
+// ToEndpointListResponse converts a slice of Endpoint models to EndpointResponse schemas
+func ToEndpointListResponse(endpoints []Endpoint) []EndpointResponse {
+	responses := make([]EndpointResponse, len(endpoints))
+	for i := range endpoints {
+		responses[i] = *ToEndpointResponse(&endpoints[i])
+	}
+	return responses
+}
+
+// ToDeliveryResponse converts a Delivery model to a DeliveryResponse schema
+func ToDeliveryResponse(delivery *Delivery) *DeliveryResponse {
+	if delivery == nil {
+		return nil
+	}
+
+	return &DeliveryResponse{
+		DeliveryID:     delivery.DeliveryID,
+		EndpointID:     delivery.EndpointID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+}
+
+// ToDeliveryListResponse converts a slice of Delivery models to DeliveryResponse schemas
+func ToDeliveryListResponse(deliveries []Delivery) []DeliveryResponse {
+	responses := make([]DeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		responses[i] = *ToDeliveryResponse(&deliveries[i])
+	}
+	return responses
+}
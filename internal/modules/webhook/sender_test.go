@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// receiver is a local webhook endpoint that verifies signatures like a partner would
+type receiver struct {
+	secret   string
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+	verified []error
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	r.verified = append(r.verified, Verify(r.secret, req.Header.Get(SignatureHeader), body, 5*time.Minute, time.Now()))
+	w.WriteHeader(r.status)
+}
+
+func testDelivery() *Delivery {
+	payload, _ := json.Marshal(envelope{
+		ID:         42,
+		Type:       "user.created",
+		OccurredAt: time.Now(),
+		Data:       json.RawMessage(`{"user_id":7}`),
+	})
+	return &Delivery{DeliveryID: 9, EndpointID: 3, EventID: 42, EventType: "user.created", Payload: payload}
+}
+
+func TestSenderSignsDelivery(t *testing.T) {
+	recv := &receiver{secret: "whsec_test", status: http.StatusNoContent}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	delivery := testDelivery()
+	status, err := NewSender(time.Second).Send(context.Background(), &Endpoint{URL: server.URL, Secret: "whsec_test"}, delivery)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Fatalf("Send() status = %d, want %d", status, http.StatusNoContent)
+	}
+
+	if len(recv.requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(recv.requests))
+	}
+	if recv.verified[0] != nil {
+		t.Fatalf("receiver could not verify the signature: %v", recv.verified[0])
+	}
+
+	req := recv.requests[0]
+	if got := req.Header.Get(DeliveryHeader); got != "9" {
+		t.Errorf("%s = %q, want %q", DeliveryHeader, got, "9")
+	}
+	if got := req.Header.Get(EventHeader); got != "user.created" {
+		t.Errorf("%s = %q, want %q", EventHeader, got, "user.created")
+	}
+	if string(recv.bodies[0]) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", recv.bodies[0], delivery.Payload)
+	}
+}
+
+func TestSenderSignatureFailsWithOtherSecret(t *testing.T) {
+	recv := &receiver{secret: "whsec_receiver", status: http.StatusOK}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	_, err := NewSender(time.Second).Send(context.Background(), &Endpoint{URL: server.URL, Secret: "whsec_sender"}, testDelivery())
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !errors.Is(recv.verified[0], ErrInvalidSignature) {
+		t.Fatalf("receiver verification = %v, want ErrInvalidSignature", recv.verified[0])
+	}
+}
+
+func TestSenderReportsFailedDelivery(t *testing.T) {
+	recv := &receiver{secret: "whsec_test", status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	status, err := NewSender(time.Second).Send(context.Background(), &Endpoint{URL: server.URL, Secret: "whsec_test"}, testDelivery())
+	if err == nil {
+		t.Fatal("Send() error = nil, want an error for a 503 response")
+	}
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("Send() status = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSenderReportsUnreachableEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	status, err := NewSender(time.Second).Send(context.Background(), &Endpoint{URL: url, Secret: "whsec_test"}, testDelivery())
+	if err == nil {
+		t.Fatal("Send() error = nil, want a connection error")
+	}
+	if status != 0 {
+		t.Fatalf("Send() status = %d, want 0", status)
+	}
+}
+
+func TestNextStateRetriesWithBackoffThenDeadLetters(t *testing.T) {
+	config := WorkerConfig{MaxAttempts: 4, BaseBackoff: time.Second, MaxBackoff: 3 * time.Second}
+	now := time.Unix(1700000000, 0)
+	failure := errors.New("endpoint responded with status 500")
+
+	tests := []struct {
+		attempts int
+		status   string
+		delay    time.Duration
+	}{
+		{1, StatusPending, time.Second},
+		{2, StatusPending, 2 * time.Second},
+		{3, StatusPending, 3 * time.Second},
+		{4, StatusDead, 0},
+	}
+
+	for _, tt := range tests {
+		status, next := nextState(failure, tt.attempts, config, now)
+		if status != tt.status || next.Sub(now) != tt.delay {
+			t.Errorf("attempt %d: got (%s, %s), want (%s, %s)", tt.attempts, status, next.Sub(now), tt.status, tt.delay)
+		}
+	}
+
+	if status, _ := nextState(nil, 3, config, now); status != StatusSucceeded {
+		t.Errorf("successful attempt: status = %s, want %s", status, StatusSucceeded)
+	}
+}
+
+func TestEndpointSubscribes(t *testing.T) {
+	tests := []struct {
+		events    []string
+		eventType string
+		want      bool
+	}{
+		{nil, "user.created", true},
+		{[]string{"user.created"}, "user.created", true},
+		{[]string{"user.created"}, "user.deleted", false},
+		{[]string{AllEvents}, "user.deleted", true},
+	}
+
+	for _, tt := range tests {
+		endpoint := &Endpoint{Events: tt.events}
+		if got := endpoint.Subscribes(tt.eventType); got != tt.want {
+			t.Errorf("Subscribes(%q) with filters %v = %v, want %v", tt.eventType, tt.events, got, tt.want)
+		}
+	}
+}
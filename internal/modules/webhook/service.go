@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"metalcore-api/internal/events"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidURL       = errors.New("webhook URL must be an absolute http or https URL")
+)
+
+const secretBytes = 32
+
+type Service struct {
+	repo *WebhookRepository
+}
+
+func NewService(repo *WebhookRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// CreateEndpoint registers an endpoint with a freshly generated signing secret.
+// The returned endpoint is the only place the secret is exposed.
+func (s *Service) CreateEndpoint(ctx context.Context, payload CreateEndpointRequest) (*Endpoint, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Service.CreateEndpoint")
+	defer span.End()
+
+	if err := validateURL(payload.URL); err != nil {
+		return nil, err
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+
+	eventTypes := payload.Events
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	return s.repo.CreateEndpoint(ctx, &Endpoint{
+		URL:         payload.URL,
+		Secret:      secret,
+		Events:      eventTypes,
+		Description: payload.Description,
+		Active:      active,
+	})
+}
+
+func (s *Service) GetEndpoint(ctx context.Context, endpointID int64) (*Endpoint, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Service.GetEndpoint")
+	defer span.End()
+
+	endpoint, err := s.repo.GetEndpoint(ctx, endpointID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrEndpointNotFound
+		}
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+func (s *Service) ListEndpoints(ctx context.Context, page, pageSize int) ([]Endpoint, int64, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Service.ListEndpoints")
+	defer span.End()
+
+	offset := (page - 1) * pageSize
+	return s.repo.ListEndpoints(ctx, offset, pageSize)
+}
+
+// UpdateEndpoint applies the changes in payload. When the secret is rotated the
+// returned endpoint carries the new secret, otherwise its Secret must not be exposed.
+func (s *Service) UpdateEndpoint(ctx context.Context, endpointID int64, payload UpdateEndpointRequest) (*Endpoint, bool, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Service.UpdateEndpoint")
+	defer span.End()
+
+	if payload.URL != nil {
+		if err := validateURL(*payload.URL); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var secret *string
+	if payload.RotateSecret {
+		generated, err := generateSecret()
+		if err != nil {
+			return nil, false, err
+		}
+		secret = &generated
+	}
+
+	endpoint, err := s.repo.UpdateEndpoint(ctx, endpointID, payload, secret)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, ErrEndpointNotFound
+		}
+		return nil, false, err
+	}
+
+	return endpoint, secret != nil, nil
+}
+
+func (s *Service) DeleteEndpoint(ctx context.Context, endpointID int64) error {
+	ctx, span := tracing.Start(ctx, "webhook.Service.DeleteEndpoint")
+	defer span.End()
+
+	if err := s.repo.DeleteEndpoint(ctx, endpointID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrEndpointNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Enqueue queues a delivery of event for every active endpoint subscribed to it.
+// It is subscribed to the in-process event bus, so it runs once the outbox
+// dispatcher delivers the event, and may run more than once for the same event.
+func (s *Service) Enqueue(ctx context.Context, event events.Event) error {
+	ctx, span := tracing.Start(ctx, "webhook.Service.Enqueue")
+	defer span.End()
+
+	endpoints, err := s.repo.ActiveEndpoints(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(envelope{
+		ID:         event.EventID,
+		Type:       event.EventType,
+		OccurredAt: event.OccurredAt,
+		Data:       event.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event.EventType) {
+			continue
+		}
+
+		err := s.repo.CreateDelivery(ctx, &Delivery{
+			EndpointID: endpoint.EndpointID,
+			EventID:    event.EventID,
+			EventType:  event.EventType,
+			Payload:    body,
+		})
+		if err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Debug("webhook delivery queued", "endpoint_id", endpoint.EndpointID, "event_id", event.EventID)
+	}
+
+	return nil
+}
+
+func (s *Service) ListDeliveries(ctx context.Context, filter ListDeliveriesRequest) ([]Delivery, int64, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Service.ListDeliveries")
+	defer span.End()
+
+	return s.repo.ListDeliveries(ctx, filter)
+}
+
+// ReplayDelivery queues a delivery again, whatever its current status
+func (s *Service) ReplayDelivery(ctx context.Context, deliveryID int64) (*Delivery, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Service.ReplayDelivery")
+	defer span.End()
+
+	delivery, err := s.repo.ResetDelivery(ctx, deliveryID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+func validateURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// generateSecret returns a random signing secret shared with the receiver
+func generateSecret() (string, error) {
+	raw := make([]byte, secretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(raw), nil
+}
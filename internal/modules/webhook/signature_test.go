@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyAcceptsSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1,"type":"user.created"}`)
+
+	header := Sign("whsec_test", now, body)
+	if err := Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1,"type":"user.created"}`)
+	header := Sign("whsec_test", now, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+	}{
+		{"modified body", "whsec_test", header, []byte(`{"id":2,"type":"user.created"}`)},
+		{"wrong secret", "whsec_other", header, body},
+		{"modified timestamp", "whsec_test", "t=1700000060" + header[len("t=1700000000"):], body},
+		{"missing signature", "whsec_test", "t=1700000000", body},
+		{"empty header", "whsec_test", "", body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute, now)
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("Verify() = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsReplay(t *testing.T) {
+	sentAt := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	header := Sign("whsec_test", sentAt, body)
+
+	err := Verify("whsec_test", header, body, 5*time.Minute, sentAt.Add(6*time.Minute))
+	if !errors.Is(err, ErrSignatureExpired) {
+		t.Fatalf("Verify() = %v, want ErrSignatureExpired", err)
+	}
+}
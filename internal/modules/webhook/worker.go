@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/metrics"
+	"metalcore-api/internal/retry"
+	"metalcore-api/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// WorkerConfig controls how pending deliveries are sent and retried
+type WorkerConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	Lease        time.Duration // How long a claimed delivery is reserved for its attempt
+}
+
+// WorkerConfigFromEnv reads the WEBHOOK_* variables
+func WorkerConfigFromEnv() WorkerConfig {
+	return WorkerConfig{
+		PollInterval: config.GetEnvDuration("WEBHOOK_POLL_INTERVAL", time.Second),
+		BatchSize:    config.GetEnvInt("WEBHOOK_BATCH_SIZE", 50),
+		MaxAttempts:  config.GetEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		BaseBackoff:  config.GetEnvDuration("WEBHOOK_BASE_BACKOFF", 30*time.Second),
+		MaxBackoff:   config.GetEnvDuration("WEBHOOK_MAX_BACKOFF", 6*time.Hour),
+		Timeout:      config.GetEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		Lease:        config.GetEnvDuration("WEBHOOK_LEASE", 5*time.Minute),
+	}
+}
+
+// Worker sends pending deliveries to their endpoints
+type Worker struct {
+	db     database.DBTX
+	repo   *WebhookRepository
+	sender *Sender
+	config WorkerConfig
+}
+
+func NewWorker(db database.DBTX, config WorkerConfig) *Worker {
+	return &Worker{
+		db:     db,
+		repo:   NewWebhookRepository(db),
+		sender: NewSender(config.Timeout),
+		config: config,
+	}
+}
+
+// Run polls for due deliveries until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	logger.Info("webhook worker started", "poll_interval", w.config.PollInterval)
+
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			claimed, err := w.DeliverBatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Error("error while delivering webhooks", "error", err)
+			}
+			if err != nil || claimed < w.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("webhook worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverBatch leases one batch of due deliveries, sends them and returns how many
+// were claimed. No transaction is open while they are sent, and each attempt is
+// recorded on its own, so a failure to record one does not undo the others.
+func (w *Worker) DeliverBatch(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "webhook.Worker.DeliverBatch")
+	defer span.End()
+
+	due, err := w.repo.ClaimDue(ctx, w.config.BatchSize, w.config.Lease)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+
+	var errs []error
+	for i := range due {
+		if err := w.deliver(ctx, &due[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		tracing.RecordError(span, err)
+	}
+
+	return len(due), err
+}
+
+func (w *Worker) deliver(ctx context.Context, item *dueDelivery) error {
+	ctx = logging.With(ctx, "delivery_id", item.Delivery.DeliveryID, "endpoint_id", item.Endpoint.EndpointID)
+
+	statusCode, sendErr := w.sender.Send(ctx, &item.Endpoint, &item.Delivery)
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	attempts := item.Delivery.Attempts + 1
+	status, nextAttemptAt := nextState(sendErr, attempts, w.config, time.Now())
+
+	var lastError *string
+	if sendErr != nil {
+		message := sendErr.Error()
+		lastError = &message
+	}
+
+	switch status {
+	case StatusSucceeded:
+		metrics.WebhookDeliveries.WithLabelValues("succeeded").Inc()
+	case StatusDead:
+		metrics.WebhookDeliveries.WithLabelValues("dead").Inc()
+		logging.FromContext(ctx).Error("webhook delivery moved to dead letter", "attempts", attempts, "error", sendErr)
+	default:
+		metrics.WebhookDeliveries.WithLabelValues("retried").Inc()
+		logging.FromContext(ctx).Warn("webhook delivery failed, retrying", "attempts", attempts, "next_attempt_at", nextAttemptAt, "error", sendErr)
+	}
+
+	err := w.repo.RecordAttempt(ctx, item.Delivery.DeliveryID, item.Delivery.NextAttemptAt, status, code, lastError, nextAttemptAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The outcome of the run that holds the delivery now wins
+		logging.FromContext(ctx).Warn("webhook delivery lease lost, attempt not recorded", "status", status)
+		return nil
+	}
+	return err
+}
+
+// nextState returns the status of a delivery after an attempt and when it is due
+// again: retried with exponential backoff until MaxAttempts, then dead-lettered
+func nextState(sendErr error, attempts int, config WorkerConfig, now time.Time) (string, time.Time) {
+	if sendErr == nil {
+		return StatusSucceeded, now
+	}
+	if attempts >= config.MaxAttempts {
+		return StatusDead, now
+	}
+	return StatusPending, now.Add(retry.Backoff(config.BaseBackoff, config.MaxBackoff, attempts))
+}
@@ -0,0 +1,204 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Document is an OpenAPI 3.1 document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+
+	schemaTypes map[string]reflect.Type // component name -> Go type, to detect name clashes
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// BearerAuth is the name of the JWT bearer security scheme
+const BearerAuth = "bearerAuth"
+
+// New creates an empty document with the bearer security scheme registered
+func New(title, version, description string) *Document {
+	return &Document{
+		OpenAPI: "3.1.0",
+		Info: Info{
+			Title:       title,
+			Version:     version,
+			Description: description,
+		},
+		Paths: map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				BearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		schemaTypes: map[string]reflect.Type{},
+	}
+}
+
+// Route describes one endpoint. Path uses gin syntax (e.g. /users/:id).
+type Route struct {
+	Method    string
+	Path      string
+	Summary   string
+	Tags      []string
+	Auth      bool         // Requires a bearer token
+	Params    []*Parameter // Path and header parameters, path parameters default to strings
+	Query     any          // Struct bound with ShouldBindQuery, described by its form tags
+	Body      any          // Struct bound with ShouldBindJSON
+	Responses map[int]*Response
+}
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// PathFromGin converts a gin route path to an OpenAPI path template
+func PathFromGin(path string) string {
+	return ginParam.ReplaceAllString(path, "{$1}")
+}
+
+// Add registers a route as an operation of the document
+func (d *Document) Add(route Route) {
+	path := PathFromGin(route.Path)
+	method := strings.ToLower(route.Method)
+
+	op := &Operation{
+		Tags:        route.Tags,
+		Summary:     route.Summary,
+		OperationID: operationID(route.Method, path),
+		Parameters:  route.Params,
+		Responses:   map[string]*Response{},
+	}
+
+	// Every path template variable must be declared, default to string
+	for _, match := range ginParam.FindAllStringSubmatch(route.Path, -1) {
+		if !hasParam(op.Parameters, match[1], "path") {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, d.QueryParameters(route.Query)...)
+	}
+
+	if route.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: d.Schema(route.Body)}},
+		}
+	}
+
+	if route.Auth {
+		op.Security = []map[string][]string{{BearerAuth: {}}}
+	}
+
+	for status, response := range route.Responses {
+		op.Responses[strconv.Itoa(status)] = response
+	}
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = map[string]*Operation{}
+	}
+	d.Paths[path][method] = op
+}
+
+// Has reports whether the document describes the gin route
+func (d *Document) Has(method, ginPath string) bool {
+	_, ok := d.Paths[PathFromGin(ginPath)][strings.ToLower(method)]
+	return ok
+}
+
+// JSONResponse describes a response with a JSON body
+func JSONResponse(description string, schema *Schema) *Response {
+	return &Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+func hasParam(params []*Parameter, name, in string) bool {
+	for _, param := range params {
+		if param.Name == name && param.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// operationID derives a stable identifier such as "get_api_v1_users_id"
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.Split(path, "/") {
+		part = strings.Trim(part, "{}")
+		if part == "" {
+			continue
+		}
+		b.WriteByte('_')
+		b.WriteString(strings.ReplaceAll(part, "-", "_"))
+	}
+	return b.String()
+}
+
+// Operation returns the operation documented for the gin route, if any
+func (d *Document) Operation(method, ginPath string) (*Operation, bool) {
+	op, ok := d.Paths[PathFromGin(ginPath)][strings.ToLower(method)]
+	return op, ok
+}
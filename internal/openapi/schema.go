@@ -0,0 +1,368 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema 2020-12 used by the document
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"` // string, or []string to add "null"
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema returns a schema for the Go value's type. Named structs are registered
+// as components and referenced.
+func (d *Document) Schema(v any) *Schema {
+	return d.schemaForType(reflect.TypeOf(v))
+}
+
+// Object returns an inline object schema with the given required properties
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// Array returns an array schema of items
+func Array(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// String returns a plain string schema
+func String() *Schema {
+	return &Schema{Type: "string"}
+}
+
+// Integer returns a plain integer schema
+func Integer() *Schema {
+	return &Schema{Type: "integer"}
+}
+
+func (d *Document) schemaForType(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	schema := d.baseSchema(t)
+	if nullable {
+		return withNull(schema)
+	}
+	return schema
+}
+
+func (d *Document) baseSchema(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return Array(d.schemaForType(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaForType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t, "json")
+		}
+		return d.componentRef(t)
+	default:
+		// interface{} and anything else accepts any JSON value
+		return &Schema{}
+	}
+}
+
+// componentRef registers a named struct under components/schemas and returns a reference to it
+func (d *Document) componentRef(t reflect.Type) *Schema {
+	name := t.Name()
+	if existing, ok := d.schemaTypes[name]; ok && existing != t {
+		// Same type name in different packages, qualify with the package name
+		name = pkgName(t) + name
+	}
+
+	if _, ok := d.schemaTypes[name]; !ok {
+		d.schemaTypes[name] = t
+		// Placeholder first so that recursive types terminate
+		d.Components.Schemas[name] = &Schema{}
+		*d.Components.Schemas[name] = *d.structSchema(t, "json")
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// structSchema builds an object schema from the fields' tag (json or form) and binding tags
+func (d *Document) structSchema(t reflect.Type, tagName string) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for _, field := range fields(t, tagName) {
+		property := d.schemaForType(field.Type)
+		required := applyBinding(property, field.Type, field.Tag.Get("binding"))
+		if required {
+			// Required pointers are only pointers to detect absence, null is rejected
+			property = withoutNull(property)
+		}
+
+		schema.Properties[field.Name] = property
+		if required {
+			schema.Required = append(schema.Required, field.Name)
+		}
+	}
+
+	return schema
+}
+
+type namedField struct {
+	Name string
+	reflect.StructField
+}
+
+// fields returns the serialized fields of a struct, flattening embedded structs
+func fields(t reflect.Type, tagName string) []namedField {
+	var result []namedField
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get(tagName)
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			result = append(result, fields(field.Type, tagName)...)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		result = append(result, namedField{Name: name, StructField: field})
+	}
+
+	return result
+}
+
+// QueryParameters describes a struct bound with ShouldBindQuery as query parameters
+func (d *Document) QueryParameters(v any) []*Parameter {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var params []*Parameter
+	for _, field := range fields(t, "form") {
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		schema := d.baseSchema(fieldType)
+		required := applyBinding(schema, fieldType, field.Tag.Get("binding"))
+		params = append(params, &Parameter{
+			Name:     field.Name,
+			In:       "query",
+			Required: required,
+			Schema:   schema,
+		})
+	}
+
+	return params
+}
+
+// applyBinding translates validator binding rules into schema constraints and
+// reports whether the field is required
+func applyBinding(schema *Schema, t reflect.Type, binding string) bool {
+	if binding == "" {
+		return false
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	target := schema
+	if len(schema.OneOf) > 0 {
+		target = schema.OneOf[0]
+	}
+
+	required, elements := false, false
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "required":
+			required = required || !elements
+		case "dive":
+			// Rules after dive apply to the elements of a slice
+			if target.Items == nil {
+				return required
+			}
+			elements = true
+			target = target.Items
+			t = t.Elem()
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+		case "email":
+			target.Format = "email"
+		case "url", "uri":
+			target.Format = "uri"
+		case "uuid":
+			target.Format = "uuid"
+		case "username":
+			target.Pattern = "^[A-Za-z0-9][A-Za-z0-9._-]*$"
+		case "alpha":
+			target.Pattern = "^[A-Za-z]*$"
+		case "alphanum":
+			target.Pattern = "^[A-Za-z0-9]*$"
+		case "numeric":
+			target.Pattern = "^[-+]?[0-9]*\\.?[0-9]+$"
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				target.Enum = append(target.Enum, enumValue(t, value))
+			}
+		case "len":
+			applyBound(target, t, param, true, true)
+		case "min", "gte":
+			applyBound(target, t, param, true, false)
+		case "max", "lte":
+			applyBound(target, t, param, false, true)
+		case "gt":
+			if value, err := strconv.ParseFloat(param, 64); err == nil && isNumber(t) {
+				target.ExclusiveMinimum = &value
+			}
+		case "lt":
+			if value, err := strconv.ParseFloat(param, 64); err == nil && isNumber(t) {
+				target.ExclusiveMaximum = &value
+			}
+		}
+	}
+
+	return required
+}
+
+// applyBound sets a lower and/or upper bound, interpreted as a length for strings and slices
+func applyBound(schema *Schema, t reflect.Type, param string, lower, upper bool) {
+	switch {
+	case t.Kind() == reflect.String:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		if lower {
+			schema.MinLength = &n
+		}
+		if upper {
+			schema.MaxLength = &n
+		}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		if lower {
+			schema.MinItems = &n
+		}
+		if upper {
+			schema.MaxItems = &n
+		}
+	case isNumber(t):
+		value, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		if lower {
+			schema.Minimum = &value
+		}
+		if upper {
+			schema.Maximum = &value
+		}
+	}
+}
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func enumValue(t reflect.Type, value string) any {
+	if isNumber(t) {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// withNull allows null in addition to the schema
+func withNull(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{OneOf: []*Schema{schema, {Type: "null"}}}
+	}
+	if typeName, ok := schema.Type.(string); ok {
+		schema.Type = []string{typeName, "null"}
+	}
+	return schema
+}
+
+// withoutNull reverts withNull
+func withoutNull(schema *Schema) *Schema {
+	if len(schema.OneOf) == 2 && schema.OneOf[1].Type == "null" {
+		return schema.OneOf[0]
+	}
+	if types, ok := schema.Type.([]string); ok && len(types) == 2 && types[1] == "null" {
+		schema.Type = types[0]
+	}
+	return schema
+}
+
+func pkgName(t reflect.Type) string {
+	path := t.PkgPath()
+	name := path[strings.LastIndex(path, "/")+1:]
+	if name == "" {
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,160 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Resolve follows a component reference
+func (d *Document) Resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// IssueKind classifies a validation issue
+type IssueKind string
+
+const (
+	IssueType    IssueKind = "type"    // The value has the wrong JSON type
+	IssueUnknown IssueKind = "unknown" // The property is not declared by the schema
+)
+
+// Issue is a mismatch between a JSON value and its schema
+type Issue struct {
+	Path     string    // JSON path, e.g. "username" or "items[0].name", "body" for the root
+	Kind     IssueKind // What is wrong
+	Expected string    // The expected JSON type for IssueType
+}
+
+// ValidateJSON checks a decoded JSON value (decoded with UseNumber) against the
+// schema's types. When strict is set, properties the schema does not declare are
+// reported as well. Value constraints are left to the binding validator.
+func (d *Document) ValidateJSON(schema *Schema, value any, strict bool) []Issue {
+	var issues []Issue
+	d.validate(schema, value, "", strict, &issues)
+	return issues
+}
+
+func (d *Document) validate(schema *Schema, value any, path string, strict bool, issues *[]Issue) {
+	schema = d.Resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	if len(schema.OneOf) > 0 {
+		for _, alternative := range schema.OneOf {
+			var candidate []Issue
+			d.validate(alternative, value, path, strict, &candidate)
+			if len(candidate) == 0 {
+				return
+			}
+		}
+		// Report against the first, non-null alternative
+		d.validate(schema.OneOf[0], value, path, strict, issues)
+		return
+	}
+
+	types := schemaTypes(schema)
+	if len(types) == 0 {
+		return
+	}
+
+	if !matchesAny(types, value) {
+		*issues = append(*issues, Issue{Path: fieldName(path), Kind: IssueType, Expected: types[0]})
+		return
+	}
+
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, child := range typed {
+			childPath := joinPath(path, key)
+			property, ok := schema.Properties[key]
+			if !ok {
+				if schema.AdditionalProperties != nil {
+					d.validate(schema.AdditionalProperties, child, childPath, strict, issues)
+				} else if strict && schema.Properties != nil {
+					*issues = append(*issues, Issue{Path: childPath, Kind: IssueUnknown})
+				}
+				continue
+			}
+			d.validate(property, child, childPath, strict, issues)
+		}
+	case []any:
+		if schema.Items == nil {
+			return
+		}
+		for i, child := range typed {
+			d.validate(schema.Items, child, fmt.Sprintf("%s[%d]", path, i), strict, issues)
+		}
+	}
+}
+
+func schemaTypes(schema *Schema) []string {
+	switch typed := schema.Type.(type) {
+	case string:
+		return []string{typed}
+	case []string:
+		// Non-null types first so that they are the ones reported
+		types := append([]string{}, typed...)
+		sort.SliceStable(types, func(i, j int) bool { return types[j] == "null" })
+		return types
+	}
+	return nil
+}
+
+func matchesAny(types []string, value any) bool {
+	for _, t := range types {
+		if matches(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(schemaType string, value any) bool {
+	switch schemaType {
+	case "null":
+		return value == nil
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := number.Int64()
+		return err == nil
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	}
+	return true
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func fieldName(path string) string {
+	if path == "" {
+		return "body"
+	}
+	return path
+}
@@ -0,0 +1,153 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Params holds the cost parameters for argon2id
+type Argon2Params struct {
+	Memory      uint32 // Memory in KiB
+	Iterations  uint32 // Number of passes over the memory
+	Parallelism uint8  // Number of lanes
+	SaltLength  uint32 // Salt length in bytes
+	KeyLength   uint32 // Derived key length in bytes
+}
+
+// DefaultArgon2Params follows the OWASP baseline recommendation for argon2id
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2id hashes passwords in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Argon2id struct {
+	params Argon2Params
+}
+
+// Bounds of the argon2id cost parameters. Memory is at least 8 KiB per lane as
+// required by the algorithm, and at most 4 GiB.
+const (
+	minArgon2MemoryPerLane = 8
+	maxArgon2Memory        = 4 * 1024 * 1024
+	maxArgon2Parallelism   = 255
+)
+
+// Validate reports parameters argon2id cannot run with
+func (p Argon2Params) Validate() error {
+	switch {
+	case p.Iterations < 1:
+		return fmt.Errorf("argon2id iterations must be at least 1, got %d", p.Iterations)
+	case p.Parallelism < 1:
+		return fmt.Errorf("argon2id parallelism must be between 1 and %d, got %d", maxArgon2Parallelism, p.Parallelism)
+	case p.Memory < minArgon2MemoryPerLane*uint32(p.Parallelism) || p.Memory > maxArgon2Memory:
+		return fmt.Errorf("argon2id memory must be between %d and %d KiB, got %d", minArgon2MemoryPerLane*uint32(p.Parallelism), maxArgon2Memory, p.Memory)
+	case p.SaltLength < 8 || p.KeyLength < 16:
+		return fmt.Errorf("argon2id salt and key must be at least 8 and 16 bytes, got %d and %d", p.SaltLength, p.KeyLength)
+	}
+	return nil
+}
+
+func NewArgon2id(params Argon2Params) *Argon2id {
+	return &Argon2id{params: params}
+}
+
+func (a *Argon2id) Name() string {
+	return AlgorithmArgon2id
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism, a.params.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		a.params.Memory,
+		a.params.Iterations,
+		a.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2id) Verify(password, encoded string) error {
+	params, version, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+	if version != argon2.Version {
+		return ErrUnknownFormat
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (a *Argon2id) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (a *Argon2id) Outdated(encoded string) bool {
+	params, version, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return version != argon2.Version ||
+		params.Memory != a.params.Memory ||
+		params.Iterations != a.params.Iterations ||
+		params.Parallelism != a.params.Parallelism ||
+		uint32(len(salt)) != a.params.SaltLength ||
+		uint32(len(key)) != a.params.KeyLength
+}
+
+// decodeArgon2id parses a PHC formatted argon2id hash
+func decodeArgon2id(encoded string) (params Argon2Params, version int, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, 0, nil, nil, ErrUnknownFormat
+	}
+
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, 0, nil, nil, ErrUnknownFormat
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, 0, nil, nil, ErrUnknownFormat
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, 0, nil, nil, ErrUnknownFormat
+	}
+
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, 0, nil, nil, ErrUnknownFormat
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	// Stored parameters argon2 would panic on, or take unbounded memory for
+	if params.Validate() != nil {
+		return params, 0, nil, nil, ErrUnknownFormat
+	}
+
+	return params, version, salt, key, nil
+}
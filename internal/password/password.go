@@ -0,0 +1,137 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"metalcore-api/internal/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	ErrMismatch      = errors.New("password does not match")
+	ErrUnknownFormat = errors.New("unrecognized password hash format")
+)
+
+// Scheme is a single password hashing algorithm with a fixed set of parameters.
+// Encoded hashes are self-describing so that a scheme can tell whether it
+// produced a hash and whether that hash used its current parameters.
+type Scheme interface {
+	// Name returns the algorithm identifier, e.g. "argon2id"
+	Name() string
+	// Hash returns the encoded hash of the password
+	Hash(password string) (string, error)
+	// Verify returns ErrMismatch if the password does not match the encoded hash
+	Verify(password, encoded string) error
+	// Recognizes reports whether the encoded hash was produced by this algorithm
+	Recognizes(encoded string) bool
+	// Outdated reports whether the encoded hash uses different parameters than the scheme
+	Outdated(encoded string) bool
+}
+
+// Hasher hashes new passwords with a preferred scheme and verifies hashes
+// produced by any of the registered schemes
+type Hasher struct {
+	preferred Scheme
+	schemes   []Scheme
+
+	dummyOnce sync.Once
+	dummy     string
+}
+
+// NewHasher creates a hasher that hashes with preferred and can also verify legacy schemes
+func NewHasher(preferred Scheme, legacy ...Scheme) *Hasher {
+	return &Hasher{
+		preferred: preferred,
+		schemes:   append([]Scheme{preferred}, legacy...),
+	}
+}
+
+// NewHasherFromEnv builds a hasher from PASSWORD_HASH_ALGORITHM and the
+// algorithm-specific cost variables. Both algorithms are always registered so
+// that existing hashes keep verifying after the preferred algorithm changes.
+// Out of range costs are rejected rather than clamped.
+func NewHasherFromEnv() (*Hasher, error) {
+	memory := config.GetEnvInt("ARGON2_MEMORY_KIB", int(DefaultArgon2Params.Memory))
+	iterations := config.GetEnvInt("ARGON2_ITERATIONS", int(DefaultArgon2Params.Iterations))
+	parallelism := config.GetEnvInt("ARGON2_PARALLELISM", int(DefaultArgon2Params.Parallelism))
+	// Checked before the conversions below, which would wrap e.g. a parallelism of 256 to 0
+	if memory < 0 || memory > maxArgon2Memory || iterations < 0 || iterations > math.MaxUint32 || parallelism < 0 || parallelism > maxArgon2Parallelism {
+		return nil, fmt.Errorf("argon2id parameters out of range: memory=%d KiB, iterations=%d, parallelism=%d", memory, iterations, parallelism)
+	}
+	params := Argon2Params{
+		Memory:      uint32(memory),
+		Iterations:  uint32(iterations),
+		Parallelism: uint8(parallelism),
+		SaltLength:  DefaultArgon2Params.SaltLength,
+		KeyLength:   DefaultArgon2Params.KeyLength,
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	argon := NewArgon2id(params)
+
+	cost := config.GetEnvInt("BCRYPT_COST", bcrypt.DefaultCost)
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+	}
+	bc := NewBcrypt(cost)
+
+	switch algorithm := config.GetEnv("PASSWORD_HASH_ALGORITHM", AlgorithmArgon2id); algorithm {
+	case AlgorithmArgon2id:
+		return NewHasher(argon, bc), nil
+	case AlgorithmBcrypt:
+		return NewHasher(bc, argon), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", algorithm)
+	}
+}
+
+// Hash hashes the password with the preferred scheme
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify checks the password against the encoded hash. needsRehash is true when
+// the password matched but the hash was produced by a non-preferred algorithm or
+// with outdated parameters, in which case the caller should store a fresh hash.
+func (h *Hasher) Verify(password, encoded string) (needsRehash bool, err error) {
+	for _, scheme := range h.schemes {
+		if !scheme.Recognizes(encoded) {
+			continue
+		}
+
+		if err := scheme.Verify(password, encoded); err != nil {
+			return false, err
+		}
+
+		return scheme.Name() != h.preferred.Name() || scheme.Outdated(encoded), nil
+	}
+
+	return false, ErrUnknownFormat
+}
+
+// VerifyDummy verifies the password against a hash of the preferred scheme and
+// discards the result. Callers that found no account run it so that unknown
+// accounts cannot be told apart from wrong passwords by the response time.
+func (h *Hasher) VerifyDummy(password string) {
+	h.dummyOnce.Do(func() {
+		dummy, err := h.preferred.Hash("dummy password")
+		if err != nil {
+			return
+		}
+		h.dummy = dummy
+	})
+	if h.dummy == "" {
+		return
+	}
+	_, _ = h.Verify(password, h.dummy)
+}
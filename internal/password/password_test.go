@@ -0,0 +1,156 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheapArgon2Params keeps the tests fast, they are not meant to be secure
+var cheapArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func mustHash(t *testing.T, scheme Scheme, password string) string {
+	t.Helper()
+	encoded, err := scheme.Hash(password)
+	if err != nil {
+		t.Fatalf("%s.Hash() = %v", scheme.Name(), err)
+	}
+	return encoded
+}
+
+func TestArgon2idEncoding(t *testing.T) {
+	argon := NewArgon2id(cheapArgon2Params)
+	encoded := mustHash(t, argon, "correct horse")
+
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("Hash() = %q, want the PHC format with the parameters", encoded)
+	}
+	params, version, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		t.Fatalf("decodeArgon2id() = %v", err)
+	}
+	if params != cheapArgon2Params || version != 19 || len(salt) != 16 || len(key) != 32 {
+		t.Fatalf("decodeArgon2id() = %+v, v=%d, %d byte salt, %d byte key", params, version, len(salt), len(key))
+	}
+	if encoded == mustHash(t, argon, "correct horse") {
+		t.Fatal("Hash() returned the same hash twice, want a random salt")
+	}
+
+	if err := argon.Verify("correct horse", encoded); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+	if err := argon.Verify("wrong horse", encoded); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Verify() with a wrong password = %v, want ErrMismatch", err)
+	}
+}
+
+func TestDecodeArgon2idRejectsMalformedHashes(t *testing.T) {
+	valid := mustHash(t, NewArgon2id(cheapArgon2Params), "secret")
+	parts := strings.Split(valid, "$")
+
+	tests := map[string]string{
+		"bcrypt":          "$2a$10$abcdefghijklmnopqrstuuGq7yNuhJSk1E1gfk4cRp0OUMwmAXLeu",
+		"missing part":    strings.Join(parts[:5], "$"),
+		"argon2i":         strings.Replace(valid, "$argon2id$", "$argon2i$", 1),
+		"bad parameters":  strings.Replace(valid, "m=64,t=1,p=1", "m=x,t=1,p=1", 1),
+		"zero iterations": strings.Replace(valid, "t=1", "t=0", 1),
+		"zero lanes":      strings.Replace(valid, "p=1", "p=0", 1),
+		"huge memory":     strings.Replace(valid, "m=64", "m=99999999", 1),
+		"bad salt":        strings.Replace(valid, parts[4], "!!", 1),
+	}
+	for name, encoded := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, _, _, err := decodeArgon2id(encoded); !errors.Is(err, ErrUnknownFormat) {
+				t.Fatalf("decodeArgon2id(%q) = %v, want ErrUnknownFormat", encoded, err)
+			}
+		})
+	}
+}
+
+func TestHasherVerify(t *testing.T) {
+	argon := NewArgon2id(cheapArgon2Params)
+	stronger := cheapArgon2Params
+	stronger.Iterations = 2
+	bc := NewBcrypt(bcrypt.MinCost)
+
+	tests := []struct {
+		name        string
+		hasher      *Hasher
+		encoded     string
+		password    string
+		needsRehash bool
+		err         error
+	}{
+		{"current argon2id", NewHasher(argon, bc), mustHash(t, argon, "secret"), "secret", false, nil},
+		{"wrong password", NewHasher(argon, bc), mustHash(t, argon, "secret"), "guess", false, ErrMismatch},
+		{"outdated argon2id", NewHasher(NewArgon2id(stronger), bc), mustHash(t, argon, "secret"), "secret", true, nil},
+		{"legacy bcrypt", NewHasher(argon, bc), mustHash(t, bc, "secret"), "secret", true, nil},
+		{"wrong legacy password", NewHasher(argon, bc), mustHash(t, bc, "secret"), "guess", false, ErrMismatch},
+		{"outdated bcrypt cost", NewHasher(NewBcrypt(bcrypt.MinCost+1), argon), mustHash(t, bc, "secret"), "secret", true, nil},
+		{"argon2id when bcrypt is preferred", NewHasher(bc, argon), mustHash(t, argon, "secret"), "secret", true, nil},
+		{"unregistered scheme", NewHasher(argon), mustHash(t, bc, "secret"), "secret", false, ErrUnknownFormat},
+		{"garbage", NewHasher(argon, bc), "plaintext", "plaintext", false, ErrUnknownFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsRehash, err := tt.hasher.Verify(tt.password, tt.encoded)
+			if !errors.Is(err, tt.err) || needsRehash != tt.needsRehash {
+				t.Fatalf("Verify() = %v, %v, want %v, %v", needsRehash, err, tt.needsRehash, tt.err)
+			}
+		})
+	}
+}
+
+func TestRehashMovesToPreferredScheme(t *testing.T) {
+	argon := NewArgon2id(cheapArgon2Params)
+	bc := NewBcrypt(bcrypt.MinCost)
+	hasher := NewHasher(argon, bc)
+
+	needsRehash, err := hasher.Verify("secret", mustHash(t, bc, "secret"))
+	if err != nil || !needsRehash {
+		t.Fatalf("Verify() of a bcrypt hash = %v, %v, want true, nil", needsRehash, err)
+	}
+
+	rehashed, err := hasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash() = %v", err)
+	}
+	if !argon.Recognizes(rehashed) {
+		t.Fatalf("Hash() = %q, want an argon2id hash", rehashed)
+	}
+	if needsRehash, err := hasher.Verify("secret", rehashed); err != nil || needsRehash {
+		t.Fatalf("Verify() of the new hash = %v, %v, want false, nil", needsRehash, err)
+	}
+}
+
+func TestNewHasherFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{"defaults", nil, false},
+		{"bcrypt", map[string]string{"PASSWORD_HASH_ALGORITHM": "bcrypt"}, false},
+		{"unknown algorithm", map[string]string{"PASSWORD_HASH_ALGORITHM": "md5"}, true},
+		{"zero iterations", map[string]string{"ARGON2_ITERATIONS": "0"}, true},
+		{"zero parallelism", map[string]string{"ARGON2_PARALLELISM": "0"}, true},
+		{"parallelism wrapping to zero", map[string]string{"ARGON2_PARALLELISM": "256"}, true},
+		{"negative memory", map[string]string{"ARGON2_MEMORY_KIB": "-1"}, true},
+		{"memory below 8 KiB per lane", map[string]string{"ARGON2_MEMORY_KIB": "15", "ARGON2_PARALLELISM": "2"}, true},
+		{"memory above 4 GiB", map[string]string{"ARGON2_MEMORY_KIB": "4194305"}, true},
+		{"bcrypt cost too high", map[string]string{"BCRYPT_COST": "32"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"PASSWORD_HASH_ALGORITHM", "ARGON2_MEMORY_KIB", "ARGON2_ITERATIONS", "ARGON2_PARALLELISM", "BCRYPT_COST"} {
+				t.Setenv(key, tt.env[key])
+			}
+			_, err := NewHasherFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewHasherFromEnv() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"metalcore-api/internal/config"
+)
+
+var (
+	ErrUnknownKey = errors.New("pii: value is encrypted with an unknown key")
+	ErrMalformed  = errors.New("pii: encrypted value is malformed")
+)
+
+// prefix marks encrypted values, anything else is stored plaintext from before
+// encryption was enabled and is returned as is
+const prefix = "pii:v1:"
+
+const keySize = 32
+
+// Cipher encrypts personal data with envelope encryption: every value gets a random
+// data key, the value is sealed with it using AES-256-GCM and the data key is sealed
+// with the active key encryption key. The key ID is stored with the value, so keys
+// can be rotated by adding a new active key and re-encrypting.
+//
+// Stored values look like "pii:v1:<key id>:<sealed data key>:<sealed value>", with
+// the sealed parts base64url encoded. The field name is bound as additional data so
+// that a value copied to another column does not decrypt.
+//
+// A nil *Cipher stores values in plaintext and has no blind indexes.
+type Cipher struct {
+	keys     map[string][]byte
+	activeID string
+	indexKey []byte
+}
+
+// NewCipher returns a cipher encrypting with keys[activeID]. Every key decrypts.
+func NewCipher(keys map[string][]byte, activeID string, indexKey []byte) (*Cipher, error) {
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("pii: invalid key ID %q", id)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("pii: key %q must be %d bytes", id, keySize)
+		}
+	}
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("pii: active key %q is not configured", activeID)
+	}
+	if len(indexKey) < keySize {
+		return nil, fmt.Errorf("pii: blind index key must be at least %d bytes", keySize)
+	}
+
+	return &Cipher{keys: keys, activeID: activeID, indexKey: indexKey}, nil
+}
+
+// NewCipherFromEnv builds a cipher from PII_ENCRYPTION_KEYS ("id:base64 key,..."),
+// PII_ACTIVE_KEY_ID (defaults to the first key) and PII_BLIND_INDEX_KEY (base64).
+// Without keys it returns nil, storing personal data unencrypted.
+func NewCipherFromEnv() (*Cipher, error) {
+	spec := config.GetEnv("PII_ENCRYPTION_KEYS", "")
+	if spec == "" {
+		slog.Warn("PII_ENCRYPTION_KEYS is not set, personal data is stored unencrypted")
+		return nil, nil
+	}
+
+	keys := map[string][]byte{}
+	var firstID string
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("pii: PII_ENCRYPTION_KEYS entries must be id:base64 key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("pii: key %q is not valid base64: %w", id, err)
+		}
+		if firstID == "" {
+			firstID = id
+		}
+		keys[id] = key
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(config.GetEnv("PII_BLIND_INDEX_KEY", ""))
+	if err != nil {
+		return nil, fmt.Errorf("pii: PII_BLIND_INDEX_KEY is not valid base64: %w", err)
+	}
+
+	return NewCipher(keys, config.GetEnv("PII_ACTIVE_KEY_ID", firstID), indexKey)
+}
+
+// Encrypt seals plaintext for field with the active key
+func (c *Cipher) Encrypt(field, plaintext string) (string, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	sealedKey, err := seal(c.keys[c.activeID], dataKey, []byte(c.activeID))
+	if err != nil {
+		return "", err
+	}
+	sealedValue, err := seal(dataKey, []byte(plaintext), []byte(field))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + c.activeID + ":" + base64.RawURLEncoding.EncodeToString(sealedKey) + ":" + base64.RawURLEncoding.EncodeToString(sealedValue), nil
+}
+
+// Decrypt opens a value produced by Encrypt for the same field. Plaintext values
+// are returned unchanged.
+func (c *Cipher) Decrypt(field, value string) (string, error) {
+	if !strings.HasPrefix(value, prefix) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	keyID := parts[0]
+
+	var kek []byte
+	if c != nil {
+		kek = c.keys[keyID]
+	}
+	if kek == nil {
+		return "", ErrUnknownKey
+	}
+
+	sealedKey, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	sealedValue, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dataKey, err := open(kek, sealedKey, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, sealedValue, []byte(field))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// EncryptPtr is Encrypt for optional values, nil stays nil
+func (c *Cipher) EncryptPtr(field string, plaintext *string) (*string, error) {
+	if plaintext == nil {
+		return nil, nil
+	}
+	value, err := c.Encrypt(field, *plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// DecryptPtr decrypts an optional value in place
+func (c *Cipher) DecryptPtr(field string, value *string) error {
+	if value == nil {
+		return nil
+	}
+	plaintext, err := c.Decrypt(field, *value)
+	if err != nil {
+		return err
+	}
+	*value = plaintext
+	return nil
+}
+
+// NeedsReencrypt reports whether the value is plaintext or sealed with a key other
+// than the active one
+func (c *Cipher) NeedsReencrypt(value string) bool {
+	if c == nil {
+		return false
+	}
+	return !strings.HasPrefix(value, prefix+c.activeID+":")
+}
+
+// BlindIndex returns a keyed HMAC-SHA256 of the value for exact-match lookups of
+// field, or nil without a cipher
+func (c *Cipher) BlindIndex(field, value string) []byte {
+	if c == nil {
+		return nil
+	}
+	h := hmac.New(sha256.New, c.indexKey)
+	h.Write([]byte(field))
+	h.Write([]byte{0})
+	h.Write([]byte(value))
+	return h.Sum(nil)
+}
+
+// BlindIndexPtr is BlindIndex for optional values, nil stays nil
+func (c *Cipher) BlindIndexPtr(field string, value *string) []byte {
+	if value == nil {
+		return nil
+	}
+	return c.BlindIndex(field, *value)
+}
+
+// seal encrypts with AES-256-GCM and prepends the random nonce
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
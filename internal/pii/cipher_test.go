@@ -0,0 +1,120 @@
+package pii
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testCipher(t *testing.T, activeID string, ids ...string) *Cipher {
+	t.Helper()
+	keys := map[string][]byte{}
+	for i, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, keySize)
+	}
+	c, err := NewCipher(keys, activeID, bytes.Repeat([]byte{0xAA}, keySize))
+	if err != nil {
+		t.Fatalf("NewCipher() = %v", err)
+	}
+	return c
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	c := testCipher(t, "k1", "k1")
+
+	sealed, err := c.Encrypt("Phone", "+14155550100")
+	if err != nil {
+		t.Fatalf("Encrypt() = %v", err)
+	}
+	if !strings.HasPrefix(sealed, "pii:v1:k1:") || strings.Contains(sealed, "4155550100") {
+		t.Fatalf("Encrypt() = %q, want an opaque value with the key ID", sealed)
+	}
+
+	again, _ := c.Encrypt("Phone", "+14155550100")
+	if again == sealed {
+		t.Fatal("Encrypt() is deterministic, want a fresh data key and nonce per value")
+	}
+
+	plaintext, err := c.Decrypt("Phone", sealed)
+	if err != nil || plaintext != "+14155550100" {
+		t.Fatalf("Decrypt() = %q, %v, want the original value", plaintext, err)
+	}
+}
+
+func TestDecryptRejectsOtherField(t *testing.T) {
+	c := testCipher(t, "k1", "k1")
+
+	sealed, _ := c.Encrypt("Firstname", "Ada")
+	if _, err := c.Decrypt("Lastname", sealed); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("Decrypt() with another field = %v, want ErrMalformed", err)
+	}
+}
+
+func TestDecryptPlaintextPassesThrough(t *testing.T) {
+	c := testCipher(t, "k1", "k1")
+
+	plaintext, err := c.Decrypt("Email", "ada@example.com")
+	if err != nil || plaintext != "ada@example.com" {
+		t.Fatalf("Decrypt() = %q, %v, want the plaintext value", plaintext, err)
+	}
+	if !c.NeedsReencrypt("ada@example.com") {
+		t.Fatal("NeedsReencrypt() = false for plaintext, want true")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := testCipher(t, "k1", "k1")
+	rotated := testCipher(t, "k2", "k1", "k2")
+
+	sealed, _ := old.Encrypt("Email", "ada@example.com")
+	if !rotated.NeedsReencrypt(sealed) {
+		t.Fatal("NeedsReencrypt() = false for a value sealed with a retired key")
+	}
+
+	plaintext, err := rotated.Decrypt("Email", sealed)
+	if err != nil || plaintext != "ada@example.com" {
+		t.Fatalf("Decrypt() with a retired key = %q, %v", plaintext, err)
+	}
+
+	resealed, _ := rotated.Encrypt("Email", plaintext)
+	if rotated.NeedsReencrypt(resealed) {
+		t.Fatal("NeedsReencrypt() = true for a value sealed with the active key")
+	}
+	if _, err := old.Decrypt("Email", resealed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Decrypt() without the new key = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	c := testCipher(t, "k1", "k1")
+	rotated := testCipher(t, "k2", "k1", "k2")
+
+	index := c.BlindIndex("Email", "ada@example.com")
+	if !bytes.Equal(index, rotated.BlindIndex("Email", "ada@example.com")) {
+		t.Fatal("BlindIndex() changed with the encryption key, want it stable across rotation")
+	}
+	if bytes.Equal(index, c.BlindIndex("Phone", "ada@example.com")) {
+		t.Fatal("BlindIndex() is equal across fields")
+	}
+	if bytes.Equal(index, c.BlindIndex("Email", "bob@example.com")) {
+		t.Fatal("BlindIndex() is equal for different values")
+	}
+}
+
+func TestNilCipherStoresPlaintext(t *testing.T) {
+	var c *Cipher
+
+	sealed, err := c.Encrypt("Email", "ada@example.com")
+	if err != nil || sealed != "ada@example.com" {
+		t.Fatalf("Encrypt() = %q, %v, want plaintext", sealed, err)
+	}
+	if c.BlindIndex("Email", sealed) != nil {
+		t.Fatal("BlindIndex() != nil without a cipher")
+	}
+
+	encrypted, _ := testCipher(t, "k1", "k1").Encrypt("Email", "ada@example.com")
+	if _, err := c.Decrypt("Email", encrypted); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Decrypt() = %v, want ErrUnknownKey", err)
+	}
+}
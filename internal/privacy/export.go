@@ -0,0 +1,132 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Exporter contributes the personal data a module holds about a user to an export
+type Exporter interface {
+	// Name is the section of the export, and the file name in a ZIP archive
+	Name() string
+	// Export returns the user's data as a JSON serializable value
+	Export(ctx context.Context, userID int) (any, error)
+}
+
+// Eraser is implemented by exporters whose data is deleted when users are erased,
+// rather than anonymized with the user row
+type Eraser interface {
+	// Erase deletes the users' data in tx, the transaction erasing the users
+	Erase(ctx context.Context, tx pgx.Tx, userIDs []int) error
+}
+
+// Registry collects the exporters of every module holding personal data
+type Registry struct {
+	exporters []Exporter
+}
+
+func NewRegistry(exporters ...Exporter) *Registry {
+	registry := &Registry{}
+	for _, exporter := range exporters {
+		registry.Register(exporter)
+	}
+	return registry
+}
+
+// Register adds an exporter, panicking when its name is already taken
+func (r *Registry) Register(exporter Exporter) {
+	for _, existing := range r.exporters {
+		if existing.Name() == exporter.Name() {
+			panic(fmt.Sprintf("privacy: exporter %q registered twice", exporter.Name()))
+		}
+	}
+	r.exporters = append(r.exporters, exporter)
+}
+
+// Erase runs every exporter that is an Eraser for the users. A nil registry erases nothing.
+func (r *Registry) Erase(ctx context.Context, tx pgx.Tx, userIDs []int) error {
+	if r == nil {
+		return nil
+	}
+
+	for _, exporter := range r.exporters {
+		if eraser, ok := exporter.(Eraser); ok {
+			if err := eraser.Erase(ctx, tx, userIDs); err != nil {
+				return fmt.Errorf("erase %s: %w", exporter.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Archive is the personal data of one user, by section
+type Archive struct {
+	UserID     int            `json:"user_id"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Sections   map[string]any `json:"sections"`
+}
+
+// Export runs every exporter for the user
+func (r *Registry) Export(ctx context.Context, userID int) (*Archive, error) {
+	archive := &Archive{
+		UserID:     userID,
+		ExportedAt: time.Now().UTC(),
+		Sections:   make(map[string]any, len(r.exporters)),
+	}
+
+	for _, exporter := range r.exporters {
+		data, err := exporter.Export(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", exporter.Name(), err)
+		}
+		archive.Sections[exporter.Name()] = data
+	}
+
+	return archive, nil
+}
+
+// WriteZIP writes the archive as a ZIP file with one JSON file per section
+// and a manifest.json describing the export
+func (a *Archive) WriteZIP(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	manifest := struct {
+		UserID     int       `json:"user_id"`
+		ExportedAt time.Time `json:"exported_at"`
+		Sections   []string  `json:"sections"`
+	}{UserID: a.UserID, ExportedAt: a.ExportedAt}
+	names := slices.Sorted(maps.Keys(a.Sections))
+	for _, name := range names {
+		manifest.Sections = append(manifest.Sections, name+".json")
+	}
+
+	if err := writeJSONFile(zw, "manifest.json", a.ExportedAt, manifest); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := writeJSONFile(zw, name+".json", a.ExportedAt, a.Sections[name]); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeJSONFile(zw *zip.Writer, name string, modified time.Time, data any) error {
+	file, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
@@ -0,0 +1,212 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/events"
+	"metalcore-api/internal/idempotency"
+	"metalcore-api/internal/jobs"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/metrics"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/session"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/modules/webhook"
+	"metalcore-api/internal/pii"
+	"metalcore-api/internal/privacy"
+	"metalcore-api/internal/scheduler"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Config holds the retention windows, measured back from the time a job runs
+type Config struct {
+	DryRun           bool
+	BatchSize        int
+	UserMode         string
+	UserRetention    time.Duration
+	SessionRetention time.Duration
+	AuditRetention   time.Duration
+	OutboxRetention  time.Duration
+	JobRetention     time.Duration
+	WebhookRetention time.Duration
+}
+
+// ConfigFromEnv reads the RETENTION_* variables
+func ConfigFromEnv() Config {
+	mode := config.GetEnv("RETENTION_USER_MODE", user.RetentionAnonymize)
+	if mode != user.RetentionAnonymize && mode != user.RetentionPurge {
+		logging.FromContext(context.Background()).Warn("invalid RETENTION_USER_MODE, using anonymize", "value", mode)
+		mode = user.RetentionAnonymize
+	}
+
+	batchSize := config.GetEnvInt("RETENTION_BATCH_SIZE", 500)
+	if batchSize <= 0 {
+		logging.FromContext(context.Background()).Warn("invalid RETENTION_BATCH_SIZE, using 500", "value", batchSize)
+		batchSize = 500
+	}
+
+	return Config{
+		DryRun:           config.GetEnvBool("RETENTION_DRY_RUN", false),
+		BatchSize:        batchSize,
+		UserMode:         mode,
+		UserRetention:    config.GetEnvDuration("RETENTION_DELETED_USERS", 90*24*time.Hour),
+		SessionRetention: config.GetEnvDuration("RETENTION_EXPIRED_TOKENS", 7*24*time.Hour),
+		AuditRetention:   config.GetEnvDuration("RETENTION_AUDIT_LOG", 365*24*time.Hour),
+		OutboxRetention:  config.GetEnvDuration("RETENTION_OUTBOX", 7*24*time.Hour),
+		JobRetention:     config.GetEnvDuration("RETENTION_FINISHED_JOBS", 7*24*time.Hour),
+		WebhookRetention: config.GetEnvDuration("RETENTION_WEBHOOK_DELIVERIES", 30*24*time.Hour),
+	}
+}
+
+// EraseDeletedUsersArgs anonymizes or purges users soft deleted longer than the retention window
+type EraseDeletedUsersArgs struct{}
+
+func (EraseDeletedUsersArgs) Kind() string { return "retention.erase_deleted_users" }
+
+// PurgeExpiredTokensArgs deletes expired or revoked sessions and expired email change tokens
+type PurgeExpiredTokensArgs struct{}
+
+func (PurgeExpiredTokensArgs) Kind() string { return "retention.purge_expired_tokens" }
+
+// PurgeAuditLogArgs deletes audit entries older than the retention window
+type PurgeAuditLogArgs struct{}
+
+func (PurgeAuditLogArgs) Kind() string { return "retention.purge_audit_log" }
+
+// PurgeOutboxArgs deletes outbox events dispatched or dead-lettered longer than the retention window
+type PurgeOutboxArgs struct{}
+
+func (PurgeOutboxArgs) Kind() string { return "retention.purge_outbox" }
+
+// PurgeFinishedJobsArgs deletes jobs that succeeded or were dead-lettered longer than the retention window
+type PurgeFinishedJobsArgs struct{}
+
+func (PurgeFinishedJobsArgs) Kind() string { return "retention.purge_finished_jobs" }
+
+// PurgeWebhookDeliveriesArgs deletes webhook deliveries that succeeded or were
+// dead-lettered longer than the retention window
+type PurgeWebhookDeliveriesArgs struct{}
+
+func (PurgeWebhookDeliveriesArgs) Kind() string { return "retention.purge_webhook_deliveries" }
+
+// Register adds the retention job handlers to the registry
+func Register(registry *jobs.Registry, db *pgxpool.Pool, cipher *pii.Cipher, exports *privacy.Registry, cfg Config) {
+	sessions := session.NewSessionRepository(db)
+	auditService := audit.NewService(audit.NewAuditRepository(db))
+	keys := idempotency.NewStore(db)
+	users := user.NewService(db, user.NewUserRepository(db, cipher), sessions, auditService, exports, nil)
+
+	jobs.Register(registry, func(ctx context.Context, _ EraseDeletedUsersArgs) error {
+		// Never erase an account that can still be restored by logging in
+		window := max(cfg.UserRetention, user.DeletionGracePeriod())
+		count, err := users.EraseDeleted(ctx, time.Now().Add(-window), cfg.UserMode, cfg.BatchSize, cfg.DryRun)
+		report(ctx, "deleted_users", cfg.UserMode, count, cfg.DryRun)
+		return err
+	})
+
+	jobs.Register(registry, func(ctx context.Context, _ PurgeExpiredTokensArgs) error {
+		before := time.Now().Add(-cfg.SessionRetention)
+
+		err := deleteInBatches(ctx, "sessions", cfg, func(limit int, dryRun bool) (int64, error) {
+			return sessions.DeleteExpired(ctx, before, limit, dryRun)
+		})
+		if err != nil {
+			return err
+		}
+
+		count, err := users.PurgeStaleEmailChangeRequests(ctx, before, cfg.BatchSize, cfg.DryRun)
+		report(ctx, "email_change_requests", "delete", count, cfg.DryRun)
+		if err != nil {
+			return err
+		}
+
+		// Idempotency keys carry their own TTL
+		return deleteInBatches(ctx, "idempotency_keys", cfg, func(limit int, dryRun bool) (int64, error) {
+			return keys.DeleteExpired(ctx, limit, dryRun)
+		})
+	})
+
+	jobs.Register(registry, func(ctx context.Context, _ PurgeAuditLogArgs) error {
+		count, err := auditService.Purge(ctx, db, time.Now().Add(-cfg.AuditRetention), cfg.BatchSize, cfg.DryRun)
+		report(ctx, "audit_log", "delete", count, cfg.DryRun)
+		return err
+	})
+
+	jobs.Register(registry, func(ctx context.Context, _ PurgeOutboxArgs) error {
+		before := time.Now().Add(-cfg.OutboxRetention)
+		return deleteInBatches(ctx, "outbox_events", cfg, func(limit int, dryRun bool) (int64, error) {
+			return events.DeleteFinished(ctx, db, before, limit, dryRun)
+		})
+	})
+
+	jobs.Register(registry, func(ctx context.Context, _ PurgeFinishedJobsArgs) error {
+		before := time.Now().Add(-cfg.JobRetention)
+		return deleteInBatches(ctx, "jobs", cfg, func(limit int, dryRun bool) (int64, error) {
+			return jobs.DeleteFinished(ctx, db, before, limit, dryRun)
+		})
+	})
+
+	deliveries := webhook.NewWebhookRepository(db)
+	jobs.Register(registry, func(ctx context.Context, _ PurgeWebhookDeliveriesArgs) error {
+		before := time.Now().Add(-cfg.WebhookRetention)
+		return deleteInBatches(ctx, "webhook_deliveries", cfg, func(limit int, dryRun bool) (int64, error) {
+			return deliveries.DeleteFinishedDeliveries(ctx, before, limit, dryRun)
+		})
+	})
+}
+
+// deleteInBatches calls deleteBatch until a batch deletes fewer than BatchSize rows
+// and reports the total. In dry run mode deleteBatch only counts, so it runs once.
+func deleteInBatches(ctx context.Context, target string, cfg Config, deleteBatch func(limit int, dryRun bool) (int64, error)) error {
+	var total int64
+	for {
+		deleted, err := deleteBatch(cfg.BatchSize, cfg.DryRun)
+		total += deleted
+		if err != nil || cfg.DryRun || deleted < int64(cfg.BatchSize) {
+			report(ctx, target, "delete", total, cfg.DryRun)
+			return err
+		}
+	}
+}
+
+// report logs and counts the rows affected by a retention task
+func report(ctx context.Context, target, action string, count int64, dryRun bool) {
+	metrics.RetentionRows.WithLabelValues(target, action, dryRunLabel(dryRun)).Add(float64(count))
+
+	message := "retention applied"
+	if dryRun {
+		message = "retention dry run, nothing was changed"
+	}
+	logging.FromContext(ctx).Info(message, "target", target, "action", action, "rows", count)
+}
+
+func dryRunLabel(dryRun bool) string {
+	if dryRun {
+		return "true"
+	}
+	return "false"
+}
+
+// Schedule adds the retention jobs to the scheduler with the RETENTION_*_SCHEDULE specs,
+// "off" disables a job
+func Schedule(s *scheduler.Scheduler) error {
+	if err := s.Add("retention.deleted_users", config.GetEnv("RETENTION_DELETED_USERS_SCHEDULE", "30 3 * * *"), EraseDeletedUsersArgs{}); err != nil {
+		return err
+	}
+	if err := s.Add("retention.expired_tokens", config.GetEnv("RETENTION_EXPIRED_TOKENS_SCHEDULE", "0 * * * *"), PurgeExpiredTokensArgs{}); err != nil {
+		return err
+	}
+	if err := s.Add("retention.audit_log", config.GetEnv("RETENTION_AUDIT_LOG_SCHEDULE", "0 4 * * 0"), PurgeAuditLogArgs{}); err != nil {
+		return err
+	}
+	if err := s.Add("retention.outbox", config.GetEnv("RETENTION_OUTBOX_SCHEDULE", "15 * * * *"), PurgeOutboxArgs{}); err != nil {
+		return err
+	}
+	if err := s.Add("retention.finished_jobs", config.GetEnv("RETENTION_FINISHED_JOBS_SCHEDULE", "45 * * * *"), PurgeFinishedJobsArgs{}); err != nil {
+		return err
+	}
+	return s.Add("retention.webhook_deliveries", config.GetEnv("RETENTION_WEBHOOK_DELIVERIES_SCHEDULE", "0 5 * * *"), PurgeWebhookDeliveriesArgs{})
+}
@@ -0,0 +1,13 @@
+package retry
+
+import "time"
+
+// Backoff returns the delay before retrying after the given number of failed
+// attempts: base after the first failure, doubled after every further one, capped at max
+func Backoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	return min(delay, max)
+}
@@ -0,0 +1,776 @@
+package router
+
+import (
+	"encoding/json"
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/middleware"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/auth"
+	"metalcore-api/internal/modules/invitation"
+	"metalcore-api/internal/modules/organization"
+	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/modules/webhook"
+	"metalcore-api/internal/openapi"
+	"metalcore-api/internal/privacy"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// docRoutes are served alongside the API but not described by it
+var docRoutes = map[string]bool{
+	"GET /openapi.json": true,
+	"GET /docs":         true,
+	"GET /docs/:asset":  true,
+}
+
+// BuildOpenAPI describes every API route. Keep it in sync with the routes
+// registered in SetupRouter, TestOpenAPIMatchesRoutes fails on drift.
+func BuildOpenAPI() *openapi.Document {
+	doc := openapi.New("Metalcore API", "1.0.0", "User management and authentication API.")
+
+	// Components that are part of the auth contract but not yet bound to a route
+	doc.Schema(auth.RegisterRequest{})
+	doc.Schema(auth.RefreshTokenRequest{})
+
+	userID := &openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}
+	ifNoneMatch := &openapi.Parameter{Name: "If-None-Match", In: "header", Description: "ETag of a cached copy, answered with 304 when unchanged", Schema: openapi.String()}
+	ifMatch := &openapi.Parameter{Name: "If-Match", In: "header", Required: true, Description: "ETag of the user the change is based on, a comma separated list of ETags, or *", Schema: openapi.String()}
+	ifMatchOptional := &openapi.Parameter{Name: "If-Match", In: "header", Description: "ETag of the user the change is based on, a comma separated list of ETags, or *", Schema: openapi.String()}
+	notModified := &openapi.Response{Description: "The cached copy is current"}
+
+	// Routes acting in the organization resolved from the request
+	organizationHeader := &openapi.Parameter{Name: "X-Organization", In: "header", Description: "Slug or ID of the organization, unless the access token or the subdomain names it", Schema: openapi.String()}
+	tenantErrors := func(responses map[int]*openapi.Response) map[int]*openapi.Response {
+		if _, ok := responses[http.StatusBadRequest]; !ok {
+			responses[http.StatusBadRequest] = errorResponse(doc, "Organization required")
+		}
+		if _, ok := responses[http.StatusForbidden]; !ok {
+			responses[http.StatusForbidden] = errorResponse(doc, "Token scoped to another organization")
+		}
+		if _, ok := responses[http.StatusNotFound]; !ok {
+			responses[http.StatusNotFound] = errorResponse(doc, "Organization not found")
+		}
+		responses[http.StatusUnauthorized] = errorResponse(doc, "Unauthorized")
+		responses[http.StatusInternalServerError] = errorResponse(doc, "Internal server error")
+		return responses
+	}
+
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/health-check",
+		Summary: "Health check",
+		Tags:    []string{"health"},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK: openapi.JSONResponse("Service is up", messageSchema(nil)),
+		},
+	})
+
+	// Auth
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/auth/login",
+		Summary: "Log in with email and password",
+		Tags:    []string{"auth"},
+		Body:    auth.LoginRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("Access token issued", dataSchema(doc, auth.AuthResponse{})),
+			http.StatusBadRequest:          errorResponse(doc, "Validation failed"),
+			http.StatusUnauthorized:        errorResponse(doc, "Invalid credentials"),
+			http.StatusForbidden:           errorResponse(doc, "Not a member of the organization"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+
+	// Users
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/users/",
+		Summary: "List the active users of the organization",
+		Tags:    []string{"users"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{organizationHeader},
+		Query:   common.PaginationRequest{},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSONResponse("A page of users", paginatedSchema(doc, user.UserResponse{})),
+			http.StatusBadRequest: errorResponse(doc, "Organization required or invalid pagination parameters"),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/users/:id",
+		Summary: "Get an active user of the organization by ID",
+		Tags:    []string{"users"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{userID, organizationHeader, ifNoneMatch},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusOK:          openapi.JSONResponse("The user, with its ETag", dataSchema(doc, user.UserResponse{})),
+			http.StatusNotModified: notModified,
+			http.StatusBadRequest:  errorResponse(doc, "Organization required or invalid user ID"),
+			http.StatusNotFound:    errorResponse(doc, "User or organization not found"),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/users/",
+		Summary: "Create a user",
+		Tags:    []string{"users"},
+		Body:    user.CreateUserRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("The created user", messageSchema(doc.Schema(user.UserResponse{}))),
+			http.StatusBadRequest:          errorResponse(doc, "Validation failed"),
+			http.StatusConflict:            errorResponse(doc, "Username or email already exists"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/users/email/confirm",
+		Summary: "Confirm an email change with the token sent to the new address",
+		Tags:    []string{"users"},
+		Body:    user.ConfirmEmailChangeRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK: openapi.JSONResponse("Email changed", messageSchema(openapi.Object(map[string]*openapi.Schema{
+				"user_id": openapi.Integer(),
+				"email":   openapi.String(),
+			}, "user_id", "email"))),
+			http.StatusBadRequest:          errorResponse(doc, "Invalid or expired token"),
+			http.StatusConflict:            errorResponse(doc, "Email already exists"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+
+	// Current user
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/users/me",
+		Summary: "Get the current user",
+		Tags:    []string{"me"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{ifNoneMatch},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("The current user, with its ETag", dataSchema(doc, user.UserResponse{})),
+			http.StatusNotModified:         notModified,
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusNotFound:            errorResponse(doc, "User not found"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPatch,
+		Path:    "/api/v1/users/me",
+		Summary: "Update the current user's profile",
+		Tags:    []string{"me"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{ifMatchOptional},
+		Body:    user.UpdateProfileRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("The updated user, with its new ETag", messageSchema(doc.Schema(user.UserResponse{}))),
+			http.StatusBadRequest:          errorResponse(doc, "Validation failed"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusNotFound:            errorResponse(doc, "User not found"),
+			http.StatusPreconditionFailed:  errorResponse(doc, "The user has been modified"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/v1/users/me",
+		Summary: "Delete the current user's account, restorable by logging in during the grace period",
+		Tags:    []string{"me"},
+		Auth:    true,
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("Deletion scheduled", messageSchema(doc.Schema(user.DeleteAccountResponse{}))),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusNotFound:            errorResponse(doc, "User not found"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/users/me/password",
+		Summary: "Change the current user's password and sign out other sessions",
+		Tags:    []string{"me"},
+		Auth:    true,
+		Body:    user.ChangePasswordRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("Password changed", messageSchema(nil)),
+			http.StatusBadRequest:          errorResponse(doc, "Validation failed"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Current password is incorrect"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/users/me/email",
+		Summary: "Request an email change, confirmed through a link sent to the new address",
+		Tags:    []string{"me"},
+		Auth:    true,
+		Body:    user.ChangeEmailRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusAccepted:            openapi.JSONResponse("Confirmation sent", messageSchema(nil)),
+			http.StatusBadRequest:          errorResponse(doc, "Validation failed or email unchanged"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Current password is incorrect"),
+			http.StatusConflict:            errorResponse(doc, "Email already exists"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/users/me/export",
+		Summary: "Export the current user's personal data from every module, as JSON or a ZIP archive",
+		Tags:    []string{"me"},
+		Auth:    true,
+		Query:   user.ExportRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK: {
+				Description: "The personal data, by section",
+				Content: map[string]openapi.MediaType{
+					"application/json": {Schema: dataSchema(doc, privacy.Archive{})},
+					"application/zip":  {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+				},
+			},
+			http.StatusBadRequest:          errorResponse(doc, "Invalid export parameters"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/users/me/erase",
+		Summary: "Erase the current user's account and personal data right away, this cannot be undone",
+		Tags:    []string{"me"},
+		Auth:    true,
+		Body:    user.EraseAccountRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("Account erased", messageSchema(nil)),
+			http.StatusBadRequest:          errorResponse(doc, "Validation failed"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Current password is incorrect"),
+			http.StatusNotFound:            errorResponse(doc, "User not found"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/users/import",
+		Summary: "Create users from a CSV or NDJSON file, invalid rows are skipped and reported (admin only)",
+		Tags:    []string{"users"},
+		Auth:    true,
+		Query:   user.ImportUsersRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                    openapi.JSONResponse("Import report", dataSchema(doc, user.ImportUsersResponse{})),
+			http.StatusBadRequest:            errorResponse(doc, "Invalid import parameters or file"),
+			http.StatusUnauthorized:          errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:             errorResponse(doc, "Forbidden"),
+			http.StatusConflict:              errorResponse(doc, "Users were created concurrently"),
+			http.StatusRequestEntityTooLarge: errorResponse(doc, "Body or row count too large"),
+			http.StatusUnsupportedMediaType:  errorResponse(doc, "Unsupported media type"),
+			http.StatusInternalServerError:   errorResponse(doc, "Internal server error"),
+		},
+	})
+	if op, ok := doc.Operation(http.MethodPost, "/api/v1/users/import"); ok {
+		row := doc.Schema(user.CreateUserRequest{})
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				"text/csv":             {Schema: &openapi.Schema{Type: "string", Description: "Header row with username, email, phone, password and optionally first_name, last_name"}},
+				"application/x-ndjson": {Schema: row},
+			},
+		}
+	}
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/users/batch",
+		Summary: "Deactivate, reactivate or delete many users, atomically or best effort (admin only)",
+		Tags:    []string{"users"},
+		Auth:    true,
+		Body:    user.BatchUsersRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                    openapi.JSONResponse("The result of each operation", dataSchema(doc, user.BatchUsersResponse{})),
+			http.StatusBadRequest:            errorResponse(doc, "Validation failed"),
+			http.StatusUnauthorized:          errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:             errorResponse(doc, "Forbidden"),
+			http.StatusConflict:              openapi.JSONResponse("An operation of an atomic batch failed, nothing was changed", dataSchema(doc, user.BatchUsersResponse{})),
+			http.StatusRequestEntityTooLarge: errorResponse(doc, "Too many operations"),
+			http.StatusInternalServerError:   errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/users/export",
+		Summary: "Stream every user that is not deleted as CSV or NDJSON (admin only)",
+		Tags:    []string{"users"},
+		Auth:    true,
+		Query:   user.ExportUsersRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK: {
+				Description: "The users, one per line",
+				Content: map[string]openapi.MediaType{
+					"application/x-ndjson": {Schema: doc.Schema(user.UserResponse{})},
+					"text/csv":             {Schema: openapi.String()},
+				},
+			},
+			http.StatusBadRequest:          errorResponse(doc, "Invalid export parameters"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPut,
+		Path:    "/api/v1/users/:id",
+		Summary: "Replace the editable fields of a user (admin only)",
+		Tags:    []string{"users"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{userID, ifMatch},
+		Body:    user.ReplaceUserRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                   openapi.JSONResponse("The updated user, with its new ETag", messageSchema(doc.Schema(user.UserResponse{}))),
+			http.StatusBadRequest:           errorResponse(doc, "Invalid user ID or validation failed"),
+			http.StatusUnauthorized:         errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:            errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:             errorResponse(doc, "User not found"),
+			http.StatusPreconditionFailed:   errorResponse(doc, "The user has been modified"),
+			http.StatusPreconditionRequired: errorResponse(doc, "If-Match header missing"),
+			http.StatusInternalServerError:  errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPatch,
+		Path:    "/api/v1/users/:id",
+		Summary: "Update the given fields of a user (admin only)",
+		Tags:    []string{"users"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{userID, ifMatch},
+		Body:    user.UpdateUserRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                   openapi.JSONResponse("The updated user, with its new ETag", messageSchema(doc.Schema(user.UserResponse{}))),
+			http.StatusBadRequest:           errorResponse(doc, "Invalid user ID or validation failed"),
+			http.StatusUnauthorized:         errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:            errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:             errorResponse(doc, "User not found"),
+			http.StatusPreconditionFailed:   errorResponse(doc, "The user has been modified"),
+			http.StatusPreconditionRequired: errorResponse(doc, "If-Match header missing"),
+			http.StatusInternalServerError:  errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/users/:id/erase",
+		Summary: "Erase a user's personal data on an erasure request (admin only)",
+		Tags:    []string{"users"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{userID},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("User erased", messageSchema(nil)),
+			http.StatusBadRequest:          errorResponse(doc, "Invalid user ID"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:            errorResponse(doc, "User not found or already erased"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+
+	// Organizations
+	memberID := &openapi.Parameter{Name: "id", In: "path", Required: true, Description: "User ID of the member", Schema: openapi.Integer()}
+
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/organizations",
+		Summary: "Create an organization with the current user as its owner",
+		Tags:    []string{"organizations"},
+		Auth:    true,
+		Body:    organization.CreateOrganizationRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusCreated:             openapi.JSONResponse("The created organization", messageSchema(doc.Schema(organization.OrganizationResponse{}))),
+			http.StatusBadRequest:          errorResponse(doc, "Validation failed"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusConflict:            errorResponse(doc, "Slug already exists"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/organizations",
+		Summary: "List the organizations of the current user, with their role",
+		Tags:    []string{"organizations"},
+		Auth:    true,
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("The organizations", dataSchema(doc, []organization.OrganizationResponse{})),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/org",
+		Summary: "Get the organization the request acts in",
+		Tags:    []string{"organizations"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{organizationHeader},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusOK: openapi.JSONResponse("The organization, with the caller's role", dataSchema(doc, organization.OrganizationResponse{})),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/org/members",
+		Summary: "List the members of the organization",
+		Tags:    []string{"organizations"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{organizationHeader},
+		Query:   common.PaginationRequest{},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSONResponse("A page of members", paginatedSchema(doc, organization.MemberResponse{})),
+			http.StatusBadRequest: errorResponse(doc, "Organization required or invalid pagination parameters"),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/org/members",
+		Summary: "Add an existing user to the organization (owners and admins only)",
+		Tags:    []string{"organizations"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{organizationHeader},
+		Body:    organization.AddMemberRequest{},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusCreated:    openapi.JSONResponse("The new member", messageSchema(doc.Schema(organization.MemberResponse{}))),
+			http.StatusBadRequest: errorResponse(doc, "Organization required or validation failed"),
+			http.StatusForbidden:  errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:   errorResponse(doc, "Organization or user not found"),
+			http.StatusConflict:   errorResponse(doc, "User is already a member"),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPatch,
+		Path:    "/api/v1/org/members/:id",
+		Summary: "Change the role of a member (owners and admins only, owners for the owner role)",
+		Tags:    []string{"organizations"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{memberID, organizationHeader},
+		Body:    organization.UpdateMemberRequest{},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSONResponse("The updated member", messageSchema(doc.Schema(organization.MemberResponse{}))),
+			http.StatusBadRequest: errorResponse(doc, "Organization required, invalid user ID or validation failed"),
+			http.StatusForbidden:  errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:   errorResponse(doc, "Organization or member not found"),
+			http.StatusConflict:   errorResponse(doc, "The organization would have no owner"),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/v1/org/members/:id",
+		Summary: "Remove a member from the organization (owners and admins only, owners for owners)",
+		Tags:    []string{"organizations"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{memberID, organizationHeader},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSONResponse("Member removed", messageSchema(nil)),
+			http.StatusBadRequest: errorResponse(doc, "Organization required or invalid user ID"),
+			http.StatusForbidden:  errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:   errorResponse(doc, "Organization or member not found"),
+			http.StatusConflict:   errorResponse(doc, "The organization would have no owner"),
+		}),
+	})
+
+	// Invitations
+	invitationID := &openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}
+
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/org/invitations",
+		Summary: "Invite an email to the organization and send the invitation (owners and admins only, owners for the owner role)",
+		Tags:    []string{"invitations"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{organizationHeader},
+		Body:    invitation.CreateInvitationRequest{},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusCreated:    openapi.JSONResponse("The sent invitation", messageSchema(doc.Schema(invitation.InvitationResponse{}))),
+			http.StatusBadRequest: errorResponse(doc, "Organization required or validation failed"),
+			http.StatusForbidden:  errorResponse(doc, "Forbidden"),
+			http.StatusConflict:   errorResponse(doc, "Already a member or an open invitation exists"),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/org/invitations",
+		Summary: "List the invitations of the organization, newest first (owners and admins only)",
+		Tags:    []string{"invitations"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{organizationHeader},
+		Query:   common.PaginationRequest{},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSONResponse("A page of invitations", paginatedSchema(doc, invitation.InvitationResponse{})),
+			http.StatusBadRequest: errorResponse(doc, "Organization required or invalid pagination parameters"),
+			http.StatusForbidden:  errorResponse(doc, "Forbidden"),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/org/invitations/:id/resend",
+		Summary: "Send an open invitation again with a new link, earlier links stop working (owners and admins only)",
+		Tags:    []string{"invitations"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{invitationID, organizationHeader},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSONResponse("The resent invitation", messageSchema(doc.Schema(invitation.InvitationResponse{}))),
+			http.StatusBadRequest: errorResponse(doc, "Organization required or invalid invitation ID"),
+			http.StatusForbidden:  errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:   errorResponse(doc, "Organization or invitation not found"),
+			http.StatusConflict:   errorResponse(doc, "Invitation already accepted or revoked"),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/v1/org/invitations/:id",
+		Summary: "Revoke an open invitation (owners and admins only)",
+		Tags:    []string{"invitations"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{invitationID, organizationHeader},
+		Responses: tenantErrors(map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSONResponse("Invitation revoked", messageSchema(nil)),
+			http.StatusBadRequest: errorResponse(doc, "Organization required or invalid invitation ID"),
+			http.StatusForbidden:  errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:   errorResponse(doc, "Organization or invitation not found"),
+			http.StatusConflict:   errorResponse(doc, "Invitation already accepted or revoked"),
+		}),
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/invitations/accept",
+		Summary: "Accept an invitation, joining with the account of the invited email or a new one",
+		Tags:    []string{"invitations"},
+		Body:    invitation.AcceptInvitationRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("The new membership", messageSchema(doc.Schema(invitation.AcceptInvitationResponse{}))),
+			http.StatusBadRequest:          errorResponse(doc, "Invalid or expired token, account details required or validation failed"),
+			http.StatusConflict:            errorResponse(doc, "Already a member, or the username or email is taken"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+
+	// Audit
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/audit",
+		Summary: "List audit log entries, newest first (admin only)",
+		Tags:    []string{"audit"},
+		Auth:    true,
+		Query:   audit.ListAuditRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("A page of audit entries", paginatedSchema(doc, audit.AuditEntryResponse{})),
+			http.StatusBadRequest:          errorResponse(doc, "Invalid query parameters"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+
+	// Webhooks
+	webhookID := &openapi.Parameter{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer", Format: "int64"}}
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/webhooks/endpoints",
+		Summary: "Register a webhook endpoint (admin only), the signing secret is only returned here",
+		Tags:    []string{"webhooks"},
+		Auth:    true,
+		Body:    webhook.CreateEndpointRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusCreated:             openapi.JSONResponse("The created endpoint with its secret", messageSchema(doc.Schema(webhook.EndpointResponse{}))),
+			http.StatusBadRequest:          errorResponse(doc, "Validation failed or invalid URL"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/webhooks/endpoints",
+		Summary: "List webhook endpoints (admin only)",
+		Tags:    []string{"webhooks"},
+		Auth:    true,
+		Query:   common.PaginationRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("A page of endpoints", paginatedSchema(doc, webhook.EndpointResponse{})),
+			http.StatusBadRequest:          errorResponse(doc, "Invalid pagination parameters"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/webhooks/endpoints/:id",
+		Summary: "Get a webhook endpoint (admin only)",
+		Tags:    []string{"webhooks"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{webhookID},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("The endpoint", dataSchema(doc, webhook.EndpointResponse{})),
+			http.StatusBadRequest:          errorResponse(doc, "Invalid endpoint ID"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:            errorResponse(doc, "Webhook endpoint not found"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPatch,
+		Path:    "/api/v1/webhooks/endpoints/:id",
+		Summary: "Update a webhook endpoint or rotate its secret (admin only)",
+		Tags:    []string{"webhooks"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{webhookID},
+		Body:    webhook.UpdateEndpointRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("The updated endpoint", messageSchema(doc.Schema(webhook.EndpointResponse{}))),
+			http.StatusBadRequest:          errorResponse(doc, "Validation failed or invalid URL"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:            errorResponse(doc, "Webhook endpoint not found"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/v1/webhooks/endpoints/:id",
+		Summary: "Delete a webhook endpoint, its delivery log is kept (admin only)",
+		Tags:    []string{"webhooks"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{webhookID},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("Endpoint deleted", messageSchema(nil)),
+			http.StatusBadRequest:          errorResponse(doc, "Invalid endpoint ID"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:            errorResponse(doc, "Webhook endpoint not found"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/webhooks/deliveries",
+		Summary: "List webhook deliveries, newest first (admin only)",
+		Tags:    []string{"webhooks"},
+		Auth:    true,
+		Query:   webhook.ListDeliveriesRequest{},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("A page of deliveries", paginatedSchema(doc, webhook.DeliveryResponse{})),
+			http.StatusBadRequest:          errorResponse(doc, "Invalid query parameters"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/v1/webhooks/deliveries/:id/replay",
+		Summary: "Queue a delivery again, including dead-lettered ones (admin only)",
+		Tags:    []string{"webhooks"},
+		Auth:    true,
+		Params:  []*openapi.Parameter{webhookID},
+		Responses: map[int]*openapi.Response{
+			http.StatusAccepted:            openapi.JSONResponse("Delivery queued", messageSchema(doc.Schema(webhook.DeliveryResponse{}))),
+			http.StatusBadRequest:          errorResponse(doc, "Invalid delivery ID"),
+			http.StatusUnauthorized:        errorResponse(doc, "Unauthorized"),
+			http.StatusForbidden:           errorResponse(doc, "Forbidden"),
+			http.StatusNotFound:            errorResponse(doc, "Webhook delivery not found"),
+			http.StatusInternalServerError: errorResponse(doc, "Internal server error"),
+		},
+	})
+
+	describeIdempotency(doc)
+
+	return doc
+}
+
+// idempotentOperations are the routes registered with middleware.Idempotency, the
+// ones creating resources. Keep it in sync with the module routes.
+var idempotentOperations = []string{
+	"POST /api/v1/users/",
+	"POST /api/v1/users/import",
+	"POST /api/v1/users/batch",
+	"POST /api/v1/organizations",
+	"POST /api/v1/org/members",
+	"POST /api/v1/org/invitations",
+	"POST /api/v1/webhooks/endpoints",
+}
+
+// describeIdempotency documents the Idempotency-Key header of idempotentOperations
+func describeIdempotency(doc *openapi.Document) {
+	maxLength := 255
+	header := &openapi.Parameter{
+		Name:        middleware.IdempotencyKeyHeader,
+		In:          "header",
+		Description: "Unique key making retries of the request safe, the first response is replayed for 24 hours",
+		Schema:      &openapi.Schema{Type: "string", MaxLength: &maxLength},
+	}
+
+	for _, route := range idempotentOperations {
+		method, path, _ := strings.Cut(route, " ")
+		op, ok := doc.Operation(method, path)
+		if !ok {
+			panic("idempotent operation " + route + " is not documented")
+		}
+		op.Parameters = append(op.Parameters, header)
+		if _, ok := op.Responses["409"]; !ok {
+			op.Responses["409"] = errorResponse(doc, "A request with the same Idempotency-Key is in progress")
+		}
+		op.Responses["422"] = errorResponse(doc, "Idempotency-Key reused for a different request")
+	}
+}
+
+// registerDocs serves the OpenAPI document and the docs UI
+func registerDocs(r *gin.Engine, doc *openapi.Document) {
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", spec)
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		c.Header("Content-Security-Policy", openapi.DocsContentSecurityPolicy)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsHTML)
+	})
+	r.GET("/docs/:asset", func(c *gin.Context) {
+		asset, ok := openapi.DocsAssets[c.Param("asset")]
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Data(http.StatusOK, asset.ContentType, asset.Content)
+	})
+}
+
+// dataSchema describes the {"data": ...} envelope
+func dataSchema(doc *openapi.Document, v any) *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{"data": doc.Schema(v)}, "data")
+}
+
+// messageSchema describes the {"message": ..., "data": ...} envelope, data is omitted when nil
+func messageSchema(data *openapi.Schema) *openapi.Schema {
+	if data == nil {
+		return openapi.Object(map[string]*openapi.Schema{"message": openapi.String()}, "message")
+	}
+	return openapi.Object(map[string]*openapi.Schema{"message": openapi.String(), "data": data}, "message", "data")
+}
+
+// paginatedSchema describes common.PaginatedResponse with data items of v's type
+func paginatedSchema(doc *openapi.Document, v any) *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{
+		"data":       openapi.Array(doc.Schema(v)),
+		"pagination": doc.Schema(common.PaginationMetadata{}),
+	}, "data", "pagination")
+}
+
+func errorResponse(doc *openapi.Document, description string) *openapi.Response {
+	return openapi.JSONResponse(description, doc.Schema(common.ErrorResponse{}))
+}
@@ -0,0 +1,111 @@
+package router
+
+import (
+	"encoding/json"
+	"metalcore-api/internal/openapi"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := SetupRouter(nil)
+	doc := BuildOpenAPI()
+
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		key := route.Method + " " + route.Path
+		if docRoutes[key] {
+			continue
+		}
+
+		registered[route.Method+" "+openapi.PathFromGin(route.Path)] = true
+		if !doc.Has(route.Method, route.Path) {
+			t.Errorf("route %s is registered but missing from the OpenAPI document", key)
+		}
+	}
+
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			key := strings.ToUpper(method) + " " + path
+			if !registered[key] {
+				t.Errorf("operation %s is documented but no such route is registered", key)
+			}
+		}
+	}
+}
+
+func TestOpenAPIIncludesBindingConstraints(t *testing.T) {
+	raw, err := json.Marshal(BuildOpenAPI())
+	if err != nil {
+		t.Fatalf("marshal document: %v", err)
+	}
+
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string `json:"required"`
+				Properties map[string]struct {
+					Format    string `json:"format"`
+					MinLength *int   `json:"minLength"`
+					MaxLength *int   `json:"maxLength"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("unmarshal document: %v", err)
+	}
+
+	create, ok := doc.Components.Schemas["CreateUserRequest"]
+	if !ok {
+		t.Fatal("CreateUserRequest schema is missing")
+	}
+
+	username := create.Properties["username"]
+	if username.MinLength == nil || *username.MinLength != 3 || username.MaxLength == nil || *username.MaxLength != 50 {
+		t.Errorf("username length constraints = %v..%v, want 3..50", username.MinLength, username.MaxLength)
+	}
+	if create.Properties["email"].Format != "email" {
+		t.Errorf("email format = %q, want email", create.Properties["email"].Format)
+	}
+	if strings.Join(create.Required, ",") != "username,email,phone,password" {
+		t.Errorf("required = %v, want [username email phone password]", create.Required)
+	}
+
+	for _, name := range []string{"UserResponse", "ErrorResponse", "PaginationMetadata", "LoginRequest", "AuthResponse", "RegisterRequest", "RefreshTokenRequest"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("%s schema is missing", name)
+		}
+	}
+}
+
+func TestDocsServeVendoredAssets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter(nil)
+
+	page := httptest.NewRecorder()
+	r.ServeHTTP(page, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if strings.Contains(page.Body.String(), "https://") || strings.Contains(page.Header().Get("Content-Security-Policy"), "https://") {
+		t.Fatalf("/docs loads assets from another origin:\n%s", page.Body.String())
+	}
+
+	for _, asset := range []string{"swagger-ui.css", "swagger-ui-bundle.js", "docs.js"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/"+asset, nil))
+		if w.Code != http.StatusOK || w.Body.Len() == 0 || !strings.Contains(page.Body.String(), "/docs/"+asset) {
+			t.Errorf("GET /docs/%s = %d with %d bytes, want the asset linked from /docs", asset, w.Code, w.Body.Len())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/unknown.js", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /docs/unknown.js = %d, want 404", w.Code)
+	}
+}
@@ -1,18 +1,48 @@
 package router
 
 import (
+	"log/slog"
+	"metalcore-api/internal/background"
+	"metalcore-api/internal/common"
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/idempotency"
+	"metalcore-api/internal/middleware"
+	"metalcore-api/internal/modules/audit"
+	"metalcore-api/internal/modules/auth"
+	"metalcore-api/internal/modules/invitation"
+	"metalcore-api/internal/modules/organization"
+	"metalcore-api/internal/modules/session"
 	"metalcore-api/internal/modules/user"
+	"metalcore-api/internal/modules/webhook"
+	"metalcore-api/internal/password"
+	"metalcore-api/internal/pii"
+	"metalcore-api/internal/tenant"
+	"metalcore-api/internal/token"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func SetupRouter(db *pgxpool.Pool) *gin.Engine {
-	r := gin.Default()
+	r := gin.New()
+	doc := BuildOpenAPI()
+	common.SetupValidator()
+
+	// Client IPs are only taken from forwarding headers set by trusted proxies
+	r.RemoteIPHeaders = config.GetEnvList("REMOTE_IP_HEADERS", "X-Forwarded-For,X-Real-IP")
+	if err := r.SetTrustedProxies(config.GetEnvList("TRUSTED_PROXIES", "")); err != nil {
+		slog.Error("error while configuring trusted proxies", "error", err)
+		os.Exit(1)
+	}
 
 	// Global middlewares
-	r.Use(gin.Logger(), gin.Recovery())
+	r.Use(gin.Recovery(), middleware.RequestID(), middleware.RequestLogger(), middleware.Metrics(), middleware.Tracing())
+	r.Use(middleware.SecurityHeaders(middleware.SecurityHeadersConfigFromEnv()), middleware.CORS(middleware.CORSConfigFromEnv()))
+	r.Use(middleware.AuditClient())
+	validation := middleware.ValidationConfigFromEnv()
+	r.Use(middleware.LimitRequestBody(validation))
 
 	// Health check endpoint
 	r.GET("/health-check", func(c *gin.Context) {
@@ -21,14 +51,57 @@ func SetupRouter(db *pgxpool.Pool) *gin.Engine {
 		})
 	})
 
+	// Shared dependencies
+	hasher, err := password.NewHasherFromEnv()
+	if err != nil {
+		slog.Error("error while configuring password hasher", "error", err)
+		os.Exit(1)
+	}
+	cipher, err := pii.NewCipherFromEnv()
+	if err != nil {
+		slog.Error("error while configuring personal data encryption", "error", err)
+		os.Exit(1)
+	}
+	tokens := token.NewManagerFromEnv()
+	sessions := session.NewSessionRepository(db)
+	requireAuth := middleware.RequireAuth(tokens, sessions, nil)
+	// Admin routes also accept internal services authenticated by their client certificate
+	requireCaller := middleware.RequireAuth(tokens, sessions, middleware.ServicePrincipalsFromEnv())
+
+	// Request bodies are checked against the document after authentication, so
+	// unauthenticated callers get 401 rather than details of the schema
+	validate := middleware.ValidateRequest(doc, validation)
+	// Only routes creating resources accept Idempotency-Key, see idempotentOperations
+	idempotent := middleware.Idempotency(idempotency.NewStore(db), middleware.IdempotencyConfigFromEnv())
+
 	// API versioning
 	v1 := r.Group("/api/v1")
 
 	// Public routes
-	// auth.RegisterRoutes(v1, db)
+	auth.RegisterRoutes(v1, db, hasher, cipher, tokens, validate)
+
+	// Every module holding personal data contributes to the user's data export
+	exports := background.Exports(db, cipher)
+
+	// Tenant routes act in the organization resolved from the request
+	tenants := organization.NewService(db, organization.NewOrganizationRepository(db), user.NewUserRepository(db, cipher), audit.NewService(audit.NewAuditRepository(db)))
+	requireTenant := middleware.RequireTenant(tenant.ConfigFromEnv(), tenants)
+
+	// User routes, self-service routes require authentication and users are only
+	// listed to members of the same organization
+	requireAdmin := middleware.RequireRole(user.RoleAdmin)
+	user.RegisterRoutes(v1, db, hasher, cipher, exports, validate, idempotent, requireAuth, requireTenant, requireCaller, requireAdmin)
+
+	// Organizations
+	organization.RegisterRoutes(v1, db, cipher, validate, idempotent, requireAuth, requireTenant)
+	invitation.RegisterRoutes(v1, db, hasher, cipher, tokens, validate, idempotent, requireAuth, requireTenant)
+
+	// Admin routes
+	audit.RegisterRoutes(v1, db, requireCaller, requireAdmin)
+	webhook.RegisterRoutes(v1, db, validate, idempotent, requireCaller, requireAdmin)
 
-	// User routes (no auth yet)
-	user.RegisterRoutes(v1, db)
+	// API description and docs UI
+	registerDocs(r, doc)
 
 	return r
 }
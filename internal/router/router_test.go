@@ -0,0 +1,41 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthenticationPrecedesValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter(nil)
+
+	for _, path := range []string{"/api/v1/users/batch", "/api/v1/organizations", "/api/v1/webhooks/endpoints", "/api/v1/users/me/password"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"operations":"not a list"}`))
+		req.Header.Set("Content-Type", "text/plain")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("POST %s without credentials = %d, want 401: %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestUserReadsRequireAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter(nil)
+
+	for _, path := range []string{"/api/v1/users/", "/api/v1/users/1"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("GET %s without credentials = %d, want 401: %s", path, w.Code, w.Body.String())
+		}
+	}
+}
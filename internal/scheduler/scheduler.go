@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/jobs"
+	"metalcore-api/internal/logging"
+	"metalcore-api/internal/metrics"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/robfig/cron/v3"
+)
+
+// Config controls leader election
+type Config struct {
+	// LockKey is the Postgres advisory lock held by the leader
+	LockKey int64
+	// CheckInterval is how often followers try to take the lock and the leader checks it still holds it
+	CheckInterval time.Duration
+}
+
+// ConfigFromEnv reads the SCHEDULER_* variables
+func ConfigFromEnv() Config {
+	return Config{
+		LockKey:       int64(config.GetEnvInt("SCHEDULER_LOCK_KEY", 7_340_001)),
+		CheckInterval: config.GetEnvDuration("SCHEDULER_CHECK_INTERVAL", 15*time.Second),
+	}
+}
+
+type entry struct {
+	name     string
+	schedule cron.Schedule
+	args     jobs.Args
+}
+
+// Scheduler enqueues jobs on cron schedules. Every instance runs one, but only
+// the one holding the advisory lock enqueues, the jobs then run on any worker.
+type Scheduler struct {
+	db      *pgxpool.Pool
+	config  Config
+	entries []entry
+}
+
+func New(db *pgxpool.Pool, config Config) *Scheduler {
+	return &Scheduler{db: db, config: config}
+}
+
+// Add schedules args with a standard five field cron spec or a descriptor such as "@daily".
+// The spec "off" disables the entry.
+func (s *Scheduler) Add(name, spec string, args jobs.Args) error {
+	if spec == "" || spec == "off" {
+		return nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("schedule %s: %w", name, err)
+	}
+
+	s.entries = append(s.entries, entry{name: name, schedule: schedule, args: args})
+	return nil
+}
+
+// Run competes for leadership until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	logger.Info("scheduler started", "entries", len(s.entries), "check_interval", s.config.CheckInterval)
+
+	if len(s.entries) == 0 {
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.tryLead(ctx); err != nil && ctx.Err() == nil {
+			logger.Error("error while running scheduler", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryLead takes the advisory lock and, when it succeeds, schedules until ctx is
+// cancelled or the lock is lost. The lock belongs to the session, so a dedicated
+// connection is held for as long as this instance is the leader.
+func (s *Scheduler) tryLead(ctx context.Context) error {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, s.config.LockKey).Scan(&acquired); err != nil {
+		return err
+	}
+	if !acquired {
+		return nil
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Info("scheduler became leader")
+	metrics.SchedulerLeader.Set(1)
+	defer metrics.SchedulerLeader.Set(0)
+
+	defer func() {
+		// Unlock on a fresh context since ctx is usually cancelled by now. If the
+		// connection is broken the server already released the lock with the session.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, s.config.LockKey); err != nil {
+			conn.Conn().Close(unlockCtx)
+		}
+		logger.Info("scheduler gave up leadership")
+	}()
+
+	return s.lead(ctx, func(ctx context.Context) error { return conn.Ping(ctx) })
+}
+
+// lead enqueues due entries until ctx is cancelled or check reports the lock is lost.
+// Runs missed while no instance was leader are not caught up.
+func (s *Scheduler) lead(ctx context.Context, check func(context.Context) error) error {
+	now := time.Now()
+	next := make([]time.Time, len(s.entries))
+	for i, e := range s.entries {
+		next[i] = e.schedule.Next(now)
+	}
+
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		soonest := next[0]
+		for _, at := range next[1:] {
+			if at.Before(soonest) {
+				soonest = at
+			}
+		}
+		timer := time.NewTimer(time.Until(soonest))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-ticker.C:
+			timer.Stop()
+			if err := check(ctx); err != nil {
+				return fmt.Errorf("scheduler lock lost: %w", err)
+			}
+		case now = <-timer.C:
+			for i, e := range s.entries {
+				if !next[i].After(now) {
+					s.enqueue(ctx, e)
+					next[i] = e.schedule.Next(now)
+				}
+			}
+		}
+	}
+}
+
+// enqueue adds the entry's job unless its previous run is still queued or running
+func (s *Scheduler) enqueue(ctx context.Context, e entry) {
+	logger := logging.FromContext(ctx).With("schedule", e.name, "kind", e.args.Kind())
+
+	jobID, err := jobs.Enqueue(ctx, s.db, e.args, jobs.Unique("schedule:"+e.name))
+	switch {
+	case err != nil:
+		metrics.ScheduledRuns.WithLabelValues(e.name, "failed").Inc()
+		logger.Error("error while enqueueing scheduled job", "error", err)
+	case jobID == 0:
+		metrics.ScheduledRuns.WithLabelValues(e.name, "skipped").Inc()
+		logger.Info("scheduled job skipped, previous run still pending")
+	default:
+		metrics.ScheduledRuns.WithLabelValues(e.name, "enqueued").Inc()
+		logger.Info("scheduled job enqueued", "job_id", jobID)
+	}
+}
@@ -0,0 +1,115 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"metalcore-api/internal/config"
+	"metalcore-api/internal/database"
+	"metalcore-api/internal/logging"
+)
+
+// ErrNotFound means the organization does not exist or the caller is not a member
+var ErrNotFound = errors.New("organization not found")
+
+// Sources a request's tenant can be resolved from
+const (
+	SourceClaim     = "claim"     // The tid claim of the access token
+	SourceHeader    = "header"    // The tenant header, an organization slug or ID
+	SourceSubdomain = "subdomain" // The first label of the host below the base domain
+)
+
+// Tenant is the organization a request acts in, with the caller's role in it
+type Tenant struct {
+	ID   int64
+	Slug string
+	Role string
+}
+
+type contextKey struct{}
+
+// WithTenant returns a context carrying the tenant of the request
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext returns the tenant set by WithTenant
+func FromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(contextKey{}).(*Tenant)
+	return tenant, ok
+}
+
+// Config controls how tenants are resolved
+type Config struct {
+	Sources    []string // Tried in order, the first one naming a tenant wins
+	Header     string
+	BaseDomain string // Hosts below it name the tenant in their first label
+}
+
+// ConfigFromEnv reads TENANT_SOURCES, TENANT_HEADER and TENANT_BASE_DOMAIN.
+// The subdomain source is skipped while TENANT_BASE_DOMAIN is unset.
+func ConfigFromEnv() Config {
+	var sources []string
+	for _, source := range strings.Split(config.GetEnv("TENANT_SOURCES", "claim,header,subdomain"), ",") {
+		source = strings.TrimSpace(source)
+		switch source {
+		case SourceClaim, SourceHeader, SourceSubdomain:
+			sources = append(sources, source)
+		case "":
+		default:
+			logging.FromContext(context.Background()).Warn("ignoring unknown tenant source", "source", source)
+		}
+	}
+
+	return Config{
+		Sources:    sources,
+		Header:     config.GetEnv("TENANT_HEADER", "X-Organization"),
+		BaseDomain: strings.ToLower(strings.Trim(config.GetEnv("TENANT_BASE_DOMAIN", ""), ".")),
+	}
+}
+
+// Subdomain returns the label of host directly below the base domain, so
+// "acme.api.example.com" gives "acme" with base domain "api.example.com"
+func (c Config) Subdomain(host string) string {
+	if c.BaseDomain == "" {
+		return ""
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	prefix, found := strings.CutSuffix(strings.ToLower(host), "."+c.BaseDomain)
+	if !found {
+		return ""
+	}
+	if i := strings.LastIndexByte(prefix, '.'); i >= 0 {
+		prefix = prefix[i+1:]
+	}
+	return prefix
+}
+
+// Scope sets the tenant and user that the row-level security policies compare
+// against until the end of the transaction. A zero ID leaves its setting empty,
+// which matches no rows. db must be a transaction.
+func Scope(ctx context.Context, db database.DBTX, tenantID int64, userID int) error {
+	_, err := db.Exec(
+		ctx,
+		`SELECT set_config('app.tenant_id', $1, true), set_config('app.user_id', $2, true)`,
+		formatID(tenantID),
+		formatID(int64(userID)),
+	)
+	if err != nil {
+		logging.FromContext(ctx).Error("error while scoping transaction to tenant", "error", err)
+	}
+	return err
+}
+
+func formatID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,200 @@
+// Package tlsconfig provides the server's TLS configuration from certificate files,
+// which are reloaded when they change, optionally verifying client certificates
+// against a CA bundle (mTLS).
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"metalcore-api/internal/config"
+)
+
+// Config names the certificate files of the server
+type Config struct {
+	CertFile          string        // PEM certificate chain, TLS is disabled without it
+	KeyFile           string        // PEM private key of the certificate
+	ClientCAFile      string        // PEM CA bundle verifying client certificates, mTLS is disabled without it
+	RequireClientCert bool          // Reject connections without a verified client certificate
+	ReloadInterval    time.Duration // How often the files are checked for changes, zero to only reload on demand
+}
+
+// ConfigFromEnv reads TLS_CERT_FILE, TLS_KEY_FILE, TLS_CLIENT_CA_FILE,
+// TLS_REQUIRE_CLIENT_CERT and TLS_RELOAD_INTERVAL
+func ConfigFromEnv() Config {
+	return Config{
+		CertFile:          config.GetEnv("TLS_CERT_FILE", ""),
+		KeyFile:           config.GetEnv("TLS_KEY_FILE", ""),
+		ClientCAFile:      config.GetEnv("TLS_CLIENT_CA_FILE", ""),
+		RequireClientCert: config.GetEnvBool("TLS_REQUIRE_CLIENT_CERT", false),
+		ReloadInterval:    config.GetEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second),
+	}
+}
+
+// Enabled reports whether the server should serve HTTPS
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// Reloader holds the current certificate and client CAs, and replaces them when
+// their files change. A failed reload keeps the previous ones.
+type Reloader struct {
+	cfg Config
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+// NewReloader loads the files of cfg
+func NewReloader(cfg Config) (*Reloader, error) {
+	if cfg.KeyFile == "" {
+		return nil, errors.New("TLS_KEY_FILE is required with TLS_CERT_FILE")
+	}
+	if cfg.RequireClientCert && cfg.ClientCAFile == "" {
+		return nil, errors.New("TLS_CLIENT_CA_FILE is required with TLS_REQUIRE_CLIENT_CERT")
+	}
+
+	r := &Reloader{cfg: cfg}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the certificate, key and client CAs from their files
+func (r *Reloader) Reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		bundle, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client CAs: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(bundle) {
+			return fmt.Errorf("no certificates in %s", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.mu.Unlock()
+
+	if cert.Leaf != nil {
+		slog.Info("loaded TLS certificate", "subject", cert.Leaf.Subject.String(), "not_after", cert.Leaf.NotAfter)
+	}
+	return nil
+}
+
+// Watch reloads the files when their modification times change, checked every
+// ReloadInterval, and whenever a value arrives on reload (e.g. SIGHUP), until ctx is done
+func (r *Reloader) Watch(ctx context.Context, reload <-chan os.Signal) {
+	var tick <-chan time.Time
+	if r.cfg.ReloadInterval > 0 {
+		ticker := time.NewTicker(r.cfg.ReloadInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+		case <-tick:
+			if !r.changed() {
+				continue
+			}
+		}
+
+		if err := r.Reload(); err != nil {
+			slog.Error("error while reloading TLS certificate, keeping the previous one", "error", err)
+		}
+	}
+}
+
+// changed reports whether a file's modification time differs from when it was loaded
+func (r *Reloader) changed() bool {
+	modTimes, err := r.stat()
+	if err != nil {
+		// A file is being replaced, retry on the next check
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name, modTime := range modTimes {
+		if !modTime.Equal(r.modTimes[name]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, 3)
+	for _, name := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[name] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// TLSConfig returns a server configuration offering HTTP/2 and HTTP/1.1 that uses
+// the current certificate and client CAs for every handshake. With client CAs,
+// client certificates are verified when sent, or always with RequireClientCert.
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	// Handshakes use clones of base, which only share its session ticket keys,
+	// and so resume each other's sessions, once the keys are initialized
+	_, _ = base.DecryptTicket(nil, tls.ConnectionState{})
+
+	return &tls.Config{
+		MinVersion: base.MinVersion,
+		NextProtos: base.NextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			cfg := base.Clone()
+			cfg.Certificates = []tls.Certificate{*r.cert}
+			if r.clientCAs != nil {
+				cfg.ClientCAs = r.clientCAs
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+				if r.cfg.RequireClientCert {
+					cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				}
+			}
+			return cfg, nil
+		},
+	}
+}
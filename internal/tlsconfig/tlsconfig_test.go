@@ -0,0 +1,148 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate for commonName and its key
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servedName returns the common name of the certificate a handshake would present
+func servedName(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	handshake, err := config.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("GetConfigForClient() = %v", err)
+	}
+	return handshake.Certificates[0].Leaf.Subject.CommonName
+}
+
+func TestWatchReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		CertFile:       filepath.Join(dir, "tls.crt"),
+		KeyFile:        filepath.Join(dir, "tls.key"),
+		ReloadInterval: 10 * time.Millisecond,
+	}
+	writeCertificate(t, cfg.CertFile, cfg.KeyFile, "first")
+
+	reloader, err := NewReloader(cfg)
+	if err != nil {
+		t.Fatalf("NewReloader() = %v", err)
+	}
+	config := reloader.TLSConfig()
+	if name := servedName(t, config); name != "first" {
+		t.Fatalf("served %q, want first", name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, nil)
+
+	writeCertificate(t, cfg.CertFile, cfg.KeyFile, "second")
+	later := time.Now().Add(time.Minute)
+	for _, name := range []string{cfg.CertFile, cfg.KeyFile} {
+		if err := os.Chtimes(name, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for servedName(t, config) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("the changed certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFailedReloadKeepsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+	writeCertificate(t, cfg.CertFile, cfg.KeyFile, "first")
+
+	reloader, err := NewReloader(cfg)
+	if err != nil {
+		t.Fatalf("NewReloader() = %v", err)
+	}
+
+	if err := os.WriteFile(cfg.KeyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("Reload() with an invalid key = nil, want an error")
+	}
+	if name := servedName(t, reloader.TLSConfig()); name != "first" {
+		t.Fatalf("served %q after a failed reload, want first", name)
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	writeCertificate(t, cfg.CertFile, cfg.KeyFile, "server")
+	writeCertificate(t, cfg.ClientCAFile, filepath.Join(dir, "ca.key"), "ca")
+
+	reloader, err := NewReloader(cfg)
+	if err != nil {
+		t.Fatalf("NewReloader() = %v", err)
+	}
+	handshake, _ := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if handshake.ClientAuth != tls.VerifyClientCertIfGiven || handshake.ClientCAs == nil {
+		t.Fatalf("ClientAuth = %v, want optional verification against the client CAs", handshake.ClientAuth)
+	}
+
+	cfg.RequireClientCert = true
+	reloader, err = NewReloader(cfg)
+	if err != nil {
+		t.Fatalf("NewReloader() = %v", err)
+	}
+	handshake, _ = reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if handshake.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Fatalf("ClientAuth = %v, want required verification", handshake.ClientAuth)
+	}
+}
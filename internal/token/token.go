@@ -0,0 +1,158 @@
+package token
+
+import (
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"metalcore-api/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims are the JWT claims carried by an access token
+type Claims struct {
+	UserID    int    `json:"uid"`
+	SessionID int64  `json:"sid"`
+	Role      string `json:"role"`
+	TenantID  int64  `json:"tid,omitempty"` // Organization the token is scoped to, if any
+	jwt.RegisteredClaims
+}
+
+// invitationAudience marks invitation tokens, which are never valid as access tokens
+const invitationAudience = "invitation"
+
+// InvitationClaims are the JWT claims carried by an invitation token
+type InvitationClaims struct {
+	InvitationID int64 `json:"iid"`
+	TenantID     int64 `json:"tid"` // Organization the invitation is for
+	jwt.RegisteredClaims
+}
+
+// Manager issues and parses HMAC signed access tokens
+type Manager struct {
+	secret    []byte
+	issuer    string
+	accessTTL time.Duration
+}
+
+func NewManager(secret []byte, issuer string, accessTTL time.Duration) *Manager {
+	return &Manager{
+		secret:    secret,
+		issuer:    issuer,
+		accessTTL: accessTTL,
+	}
+}
+
+// NewManagerFromEnv builds a manager from JWT_SECRET, JWT_ISSUER and JWT_ACCESS_TTL.
+// Without JWT_SECRET a random secret is generated, which invalidates tokens on restart.
+func NewManagerFromEnv() *Manager {
+	secret := []byte(config.GetEnv("JWT_SECRET", ""))
+	if len(secret) == 0 {
+		slog.Warn("JWT_SECRET is not set, using a random secret")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			slog.Error("error while generating JWT secret", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	return NewManager(
+		secret,
+		config.GetEnv("JWT_ISSUER", "metalcore-api"),
+		config.GetEnvDuration("JWT_ACCESS_TTL", 15*time.Minute),
+	)
+}
+
+// AccessTTL returns how long issued access tokens and their sessions are valid
+func (m *Manager) AccessTTL() time.Duration {
+	return m.accessTTL
+}
+
+// Issue creates a signed access token for the user's session, scoped to the
+// organization tenantID unless it is zero. The role is captured at login, a
+// role change takes effect with the next token.
+func (m *Manager) Issue(userID int, role string, tenantID, sessionID int64, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Role:      role,
+		TenantID:  tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// Parse validates the signature, issuer and expiry of an access token and returns its claims
+func (m *Manager) Parse(tokenString string) (*Claims, error) {
+	var claims Claims
+
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		&claims,
+		func(t *jwt.Token) (interface{}, error) {
+			return m.secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || len(claims.Audience) > 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// IssueInvitation creates a signed token for the invitation to the organization
+// tenantID. Every token gets a random ID, so reissuing an invitation never
+// reproduces an earlier token.
+func (m *Manager) IssueInvitation(invitationID, tenantID int64, expiresAt time.Time) (string, error) {
+	claims := InvitationClaims{
+		InvitationID: invitationID,
+		TenantID:     tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Audience:  jwt.ClaimStrings{invitationAudience},
+			ID:        rand.Text(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// ParseInvitation validates the signature, audience, issuer and expiry of an invitation token
+func (m *Manager) ParseInvitation(tokenString string) (*InvitationClaims, error) {
+	var claims InvitationClaims
+
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		&claims,
+		func(t *jwt.Token) (interface{}, error) {
+			return m.secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(invitationAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
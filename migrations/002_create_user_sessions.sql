@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS public."UserSession" (
+    "SessionId" BIGSERIAL PRIMARY KEY,
+    "UserId" INTEGER NOT NULL REFERENCES public."User" ("UserId"),
+    "UserAgent" TEXT,
+    "IpAddress" TEXT,
+    "CreatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "ExpiresAt" TIMESTAMPTZ NOT NULL,
+    "RevokedAt" TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS "IX_UserSession_UserId" ON public."UserSession" ("UserId");
+
+CREATE TABLE IF NOT EXISTS public."EmailChangeRequest" (
+    "RequestId" BIGSERIAL PRIMARY KEY,
+    "UserId" INTEGER NOT NULL REFERENCES public."User" ("UserId"),
+    "NewEmail" VARCHAR(255) NOT NULL,
+    "TokenHash" CHAR(64) NOT NULL UNIQUE,
+    "CreatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "ExpiresAt" TIMESTAMPTZ NOT NULL,
+    "ConfirmedAt" TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS "IX_EmailChangeRequest_UserId" ON public."EmailChangeRequest" ("UserId");
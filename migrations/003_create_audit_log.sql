@@ -0,0 +1,41 @@
+ALTER TABLE public."User"
+    ADD COLUMN IF NOT EXISTS "Role" VARCHAR(20) NOT NULL DEFAULT 'user';
+
+CREATE TABLE IF NOT EXISTS public."AuditLog" (
+    "AuditId" BIGSERIAL PRIMARY KEY,
+    "OccurredAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "ActorId" INTEGER,
+    "Action" VARCHAR(100) NOT NULL,
+    "TargetType" VARCHAR(50) NOT NULL,
+    "TargetId" VARCHAR(100) NOT NULL,
+    "Before" JSONB,
+    "After" JSONB,
+    "Diff" JSONB,
+    "IpAddress" TEXT,
+    "UserAgent" TEXT,
+    "RequestId" VARCHAR(128)
+);
+
+CREATE INDEX IF NOT EXISTS "IX_AuditLog_Target" ON public."AuditLog" ("TargetType", "TargetId");
+CREATE INDEX IF NOT EXISTS "IX_AuditLog_ActorId" ON public."AuditLog" ("ActorId");
+CREATE INDEX IF NOT EXISTS "IX_AuditLog_Action" ON public."AuditLog" ("Action");
+CREATE INDEX IF NOT EXISTS "IX_AuditLog_OccurredAt" ON public."AuditLog" ("OccurredAt");
+
+-- The audit log is append-only. Maintenance jobs (retention, erasure) opt in with
+-- SET LOCAL app.audit_maintenance = 'on' inside their transaction.
+CREATE OR REPLACE FUNCTION public.audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('app.audit_maintenance', true) IS DISTINCT FROM 'on' THEN
+        RAISE EXCEPTION 'AuditLog is append-only';
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "TR_AuditLog_AppendOnly" ON public."AuditLog";
+CREATE TRIGGER "TR_AuditLog_AppendOnly"
+    BEFORE UPDATE OR DELETE ON public."AuditLog"
+    FOR EACH ROW EXECUTE FUNCTION public.audit_log_append_only();
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS public."OutboxEvent" (
+    "EventId" BIGSERIAL PRIMARY KEY,
+    "AggregateType" VARCHAR(50) NOT NULL,
+    "AggregateId" VARCHAR(100) NOT NULL,
+    "EventType" VARCHAR(100) NOT NULL,
+    "Payload" JSONB NOT NULL,
+    "RequestId" VARCHAR(128),
+    "OccurredAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "Attempts" INTEGER NOT NULL DEFAULT 0,
+    "NextAttemptAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "LastError" TEXT,
+    "DispatchedAt" TIMESTAMPTZ,
+    "DeadAt" TIMESTAMPTZ
+);
+
+-- Pending events, in the order they are dispatched within an aggregate
+CREATE INDEX IF NOT EXISTS "IX_OutboxEvent_Pending"
+    ON public."OutboxEvent" ("AggregateType", "AggregateId", "EventId")
+    WHERE "DispatchedAt" IS NULL AND "DeadAt" IS NULL;
+
+CREATE INDEX IF NOT EXISTS "IX_OutboxEvent_NextAttemptAt"
+    ON public."OutboxEvent" ("NextAttemptAt")
+    WHERE "DispatchedAt" IS NULL AND "DeadAt" IS NULL;
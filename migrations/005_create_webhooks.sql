@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS public."WebhookEndpoint" (
+    "EndpointId" BIGSERIAL PRIMARY KEY,
+    "Url" VARCHAR(2048) NOT NULL,
+    "Secret" VARCHAR(128) NOT NULL,
+    "Events" TEXT[] NOT NULL DEFAULT '{}',
+    "Description" VARCHAR(255),
+    "Active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "CreatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "UpdatedAt" TIMESTAMPTZ,
+    "DeletedAt" TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS public."WebhookDelivery" (
+    "DeliveryId" BIGSERIAL PRIMARY KEY,
+    "EndpointId" BIGINT NOT NULL REFERENCES public."WebhookEndpoint" ("EndpointId"),
+    "EventId" BIGINT NOT NULL,
+    "EventType" VARCHAR(100) NOT NULL,
+    "Payload" JSONB NOT NULL,
+    "Status" VARCHAR(20) NOT NULL DEFAULT 'pending',
+    "Attempts" INTEGER NOT NULL DEFAULT 0,
+    "NextAttemptAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "LastStatusCode" INTEGER,
+    "LastError" TEXT,
+    "CreatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "UpdatedAt" TIMESTAMPTZ,
+    "DeliveredAt" TIMESTAMPTZ,
+    -- Outbox events are delivered at least once, an event is queued once per endpoint
+    CONSTRAINT "UQ_WebhookDelivery_Endpoint_Event" UNIQUE ("EndpointId", "EventId")
+);
+
+CREATE INDEX IF NOT EXISTS "IX_WebhookDelivery_Due"
+    ON public."WebhookDelivery" ("NextAttemptAt")
+    WHERE "Status" = 'pending';
+
+CREATE INDEX IF NOT EXISTS "IX_WebhookDelivery_EndpointId" ON public."WebhookDelivery" ("EndpointId", "CreatedAt");
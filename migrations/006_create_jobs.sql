@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS public."Job" (
+    "JobId" BIGSERIAL PRIMARY KEY,
+    "Kind" VARCHAR(100) NOT NULL,
+    "Payload" JSONB NOT NULL DEFAULT '{}',
+    "Priority" SMALLINT NOT NULL DEFAULT 0,
+    "RunAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "Status" VARCHAR(20) NOT NULL DEFAULT 'queued',
+    "Attempts" INTEGER NOT NULL DEFAULT 0,
+    "MaxAttempts" INTEGER NOT NULL DEFAULT 10,
+    "LastError" TEXT,
+    "UniqueKey" VARCHAR(255),
+    "LockedAt" TIMESTAMPTZ,
+    "LockedBy" VARCHAR(255),
+    "CreatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "UpdatedAt" TIMESTAMPTZ,
+    "FinishedAt" TIMESTAMPTZ
+);
+
+-- Due jobs, highest priority first
+CREATE INDEX IF NOT EXISTS "IX_Job_Queued"
+    ON public."Job" ("Priority" DESC, "RunAt", "JobId")
+    WHERE "Status" = 'queued';
+
+CREATE INDEX IF NOT EXISTS "IX_Job_Running"
+    ON public."Job" ("LockedAt")
+    WHERE "Status" = 'running';
+
+-- A unique job can only be queued or running once at a time
+CREATE UNIQUE INDEX IF NOT EXISTS "UX_Job_UniqueKey"
+    ON public."Job" ("UniqueKey")
+    WHERE "UniqueKey" IS NOT NULL AND "Status" IN ('queued', 'running');
@@ -0,0 +1,7 @@
+ALTER TABLE public."User"
+    ADD COLUMN IF NOT EXISTS "AnonymizedAt" TIMESTAMPTZ;
+
+-- Soft deleted users awaiting retention
+CREATE INDEX IF NOT EXISTS "IX_User_DeletedAt"
+    ON public."User" ("DeletedAt")
+    WHERE "DeletedAt" IS NOT NULL AND "AnonymizedAt" IS NULL;
@@ -0,0 +1,16 @@
+-- Names, email and phone hold AES-GCM ciphertexts (see internal/pii), which are
+-- longer than the plaintext. Existing rows are encrypted by cmd/reencrypt.
+ALTER TABLE public."User"
+    ALTER COLUMN "Firstname" TYPE TEXT,
+    ALTER COLUMN "Lastname" TYPE TEXT,
+    ALTER COLUMN "Email" TYPE TEXT,
+    ALTER COLUMN "Phone" TYPE TEXT;
+
+-- Keyed HMAC blind indexes for exact-match lookups of the encrypted columns.
+-- NULL until a row is written or re-encrypted with a blind index key configured.
+ALTER TABLE public."User"
+    ADD COLUMN IF NOT EXISTS "EmailIndex" BYTEA,
+    ADD COLUMN IF NOT EXISTS "PhoneIndex" BYTEA;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "UX_User_EmailIndex" ON public."User" ("EmailIndex");
+CREATE INDEX IF NOT EXISTS "IX_User_PhoneIndex" ON public."User" ("PhoneIndex");
@@ -0,0 +1,14 @@
+-- Idempotency-Key values of POST requests, with the fingerprint of the request and
+-- its response once completed. "LockedUntil" is set while the request is in flight.
+CREATE TABLE IF NOT EXISTS public."IdempotencyKey" (
+    "Key" VARCHAR(255) PRIMARY KEY,
+    "Fingerprint" BYTEA NOT NULL,
+    "Status" INTEGER,
+    "Headers" JSONB,
+    "Body" BYTEA,
+    "CreatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "LockedUntil" TIMESTAMPTZ,
+    "ExpiresAt" TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "IX_IdempotencyKey_ExpiresAt" ON public."IdempotencyKey" ("ExpiresAt");
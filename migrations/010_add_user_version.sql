@@ -0,0 +1,3 @@
+-- Version of each user row, incremented on every update and served as its ETag
+ALTER TABLE public."User"
+    ADD COLUMN IF NOT EXISTS "Version" INTEGER NOT NULL DEFAULT 1;
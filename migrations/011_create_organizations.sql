@@ -0,0 +1,40 @@
+-- Organizations are the tenants of the API. Users are global identities and join
+-- organizations through memberships, which carry their role in the organization.
+CREATE TABLE IF NOT EXISTS public."Organization" (
+    "OrganizationId" BIGSERIAL PRIMARY KEY,
+    "Slug" VARCHAR(63) NOT NULL,
+    "Name" VARCHAR(100) NOT NULL,
+    "CreatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "UpdatedAt" TIMESTAMPTZ,
+    CONSTRAINT "UQ_Organization_Slug" UNIQUE ("Slug")
+);
+
+CREATE TABLE IF NOT EXISTS public."Membership" (
+    "OrganizationId" BIGINT NOT NULL REFERENCES public."Organization" ("OrganizationId") ON DELETE CASCADE,
+    "UserId" INTEGER NOT NULL REFERENCES public."User" ("UserId") ON DELETE CASCADE,
+    "Role" VARCHAR(20) NOT NULL DEFAULT 'member',
+    "CreatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "UpdatedAt" TIMESTAMPTZ,
+    PRIMARY KEY ("OrganizationId", "UserId"),
+    CONSTRAINT "CK_Membership_Role" CHECK ("Role" IN ('owner', 'admin', 'member'))
+);
+
+CREATE INDEX IF NOT EXISTS "IX_Membership_UserId" ON public."Membership" ("UserId");
+
+-- Row-level security scopes memberships to the transaction's app.tenant_id, set with
+-- SET LOCAL (or set_config(..., true)) by the repositories. Rows of the user in
+-- app.user_id stay visible so that a user can list their own organizations.
+-- Without either setting no rows are visible. FORCE applies the policy to the
+-- table owner, which the API connects as.
+ALTER TABLE public."Membership" ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public."Membership" FORCE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS "Membership_Tenant" ON public."Membership";
+CREATE POLICY "Membership_Tenant" ON public."Membership"
+    USING (
+        "OrganizationId" = NULLIF(current_setting('app.tenant_id', true), '')::BIGINT
+        OR "UserId" = NULLIF(current_setting('app.user_id', true), '')::INTEGER
+    )
+    WITH CHECK (
+        "OrganizationId" = NULLIF(current_setting('app.tenant_id', true), '')::BIGINT
+    );
@@ -0,0 +1,33 @@
+-- Invitations to join an organization, sent by email. The token of the link is a
+-- signed JWT of which only the hash of the latest one is kept, so resending or
+-- revoking an invitation invalidates the links sent before.
+CREATE TABLE IF NOT EXISTS public."Invitation" (
+    "InvitationId" BIGSERIAL PRIMARY KEY,
+    "OrganizationId" BIGINT NOT NULL REFERENCES public."Organization" ("OrganizationId") ON DELETE CASCADE,
+    "Email" TEXT NOT NULL,
+    "Role" VARCHAR(20) NOT NULL,
+    "InvitedBy" INTEGER REFERENCES public."User" ("UserId") ON DELETE SET NULL,
+    "TokenHash" VARCHAR(64),
+    "ExpiresAt" TIMESTAMPTZ NOT NULL,
+    "SentAt" TIMESTAMPTZ,
+    "SendCount" INTEGER NOT NULL DEFAULT 0,
+    "AcceptedAt" TIMESTAMPTZ,
+    "AcceptedBy" INTEGER REFERENCES public."User" ("UserId") ON DELETE SET NULL,
+    "RevokedAt" TIMESTAMPTZ,
+    "CreatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT "CK_Invitation_Role" CHECK ("Role" IN ('owner', 'admin', 'member'))
+);
+
+-- An email has at most one open invitation per organization, expired ones are resent
+CREATE UNIQUE INDEX IF NOT EXISTS "UX_Invitation_Open" ON public."Invitation" ("OrganizationId", LOWER("Email"))
+    WHERE "AcceptedAt" IS NULL AND "RevokedAt" IS NULL;
+
+-- Invitations are only visible within their organization's app.tenant_id, the
+-- organization of an accepted token is taken from its signed claims.
+ALTER TABLE public."Invitation" ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public."Invitation" FORCE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS "Invitation_Tenant" ON public."Invitation";
+CREATE POLICY "Invitation_Tenant" ON public."Invitation"
+    USING ("OrganizationId" = NULLIF(current_setting('app.tenant_id', true), '')::BIGINT)
+    WITH CHECK ("OrganizationId" = NULLIF(current_setting('app.tenant_id', true), '')::BIGINT);
@@ -0,0 +1,8 @@
+-- Finished events, deleted by the outbox retention job
+CREATE INDEX IF NOT EXISTS "IX_OutboxEvent_DispatchedAt"
+    ON public."OutboxEvent" ("DispatchedAt")
+    WHERE "DispatchedAt" IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS "IX_OutboxEvent_DeadAt"
+    ON public."OutboxEvent" ("DeadAt")
+    WHERE "DeadAt" IS NOT NULL;
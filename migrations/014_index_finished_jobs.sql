@@ -0,0 +1,4 @@
+-- Finished jobs, deleted by the job retention task
+CREATE INDEX IF NOT EXISTS "IX_Job_Finished"
+    ON public."Job" ("FinishedAt")
+    WHERE "Status" IN ('succeeded', 'dead');
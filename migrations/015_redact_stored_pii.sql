@@ -0,0 +1,42 @@
+-- Pending email changes hold AES-GCM ciphertexts of the new address, like the
+-- user's email. Requests created before are read as plaintext until they expire.
+ALTER TABLE public."EmailChangeRequest"
+    ALTER COLUMN "NewEmail" TYPE TEXT;
+
+-- User events carry no personal data anymore, strip it from the events and webhook
+-- deliveries written before
+UPDATE public."OutboxEvent"
+SET "Payload" = "Payload" - ARRAY['username', 'first_name', 'last_name', 'email', 'phone']
+WHERE "EventType" IN ('user.created', 'user.updated')
+  AND "Payload" ?| ARRAY['username', 'first_name', 'last_name', 'email', 'phone'];
+
+UPDATE public."WebhookDelivery"
+SET "Payload" = jsonb_set("Payload", '{data}', ("Payload"->'data') - ARRAY['username', 'first_name', 'last_name', 'email', 'phone'])
+WHERE "EventType" IN ('user.created', 'user.updated')
+  AND "Payload"->'data' ?| ARRAY['username', 'first_name', 'last_name', 'email', 'phone'];
+
+-- Audit snapshots and diffs keep the names of personal fields but not their values
+CREATE FUNCTION pg_temp.redact_personal(snapshot JSONB, diff BOOLEAN) RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(key, CASE
+        WHEN key NOT IN ('username', 'first_name', 'last_name', 'email', 'phone') THEN value
+        WHEN diff THEN jsonb_build_object(
+            'from', CASE WHEN value->'from' = 'null'::JSONB THEN value->'from' ELSE '"[redacted]"'::JSONB END,
+            'to', CASE WHEN value->'to' = 'null'::JSONB THEN value->'to' ELSE '"[redacted]"'::JSONB END
+        )
+        WHEN value = 'null'::JSONB THEN value
+        ELSE '"[redacted]"'::JSONB
+    END), snapshot)
+    FROM jsonb_each(snapshot)
+$$ LANGUAGE sql;
+
+SELECT set_config('app.audit_maintenance', 'on', false);
+
+UPDATE public."AuditLog"
+SET "Before" = pg_temp.redact_personal("Before", false),
+    "After" = pg_temp.redact_personal("After", false),
+    "Diff" = pg_temp.redact_personal("Diff", true)
+WHERE "Before" ?| ARRAY['username', 'first_name', 'last_name', 'email', 'phone']
+   OR "After" ?| ARRAY['username', 'first_name', 'last_name', 'email', 'phone']
+   OR "Diff" ?| ARRAY['username', 'first_name', 'last_name', 'email', 'phone'];
+
+RESET app.audit_maintenance;
@@ -0,0 +1,13 @@
+-- The record a job is about, such as "user:7", so that erasing the record deletes
+-- the jobs holding its data
+ALTER TABLE public."Job"
+    ADD COLUMN IF NOT EXISTS "Reference" VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS "IX_Job_Reference"
+    ON public."Job" ("Reference")
+    WHERE "Reference" IS NOT NULL;
+
+-- Finished deliveries, deleted by the webhook delivery retention job
+CREATE INDEX IF NOT EXISTS "IX_WebhookDelivery_Finished"
+    ON public."WebhookDelivery" ("UpdatedAt")
+    WHERE "Status" IN ('succeeded', 'dead');
@@ -0,0 +1,21 @@
+-- Users are global identities. Transactions scoped to an organization with
+-- app.tenant_id only see its members and the user in app.user_id, so a tenant
+-- query missing its Membership join cannot return users of other organizations.
+-- Unscoped transactions (login, self-service, administration) see every user.
+-- Writes are not restricted beyond the visibility of the rows they update.
+ALTER TABLE public."User" ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public."User" FORCE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS "User_Tenant" ON public."User";
+CREATE POLICY "User_Tenant" ON public."User"
+    USING (
+        NULLIF(current_setting('app.tenant_id', true), '') IS NULL
+        OR "UserId" = NULLIF(current_setting('app.user_id', true), '')::INTEGER
+        OR EXISTS (
+            SELECT 1
+            FROM public."Membership" m
+            WHERE m."UserId" = "User"."UserId"
+              AND m."OrganizationId" = NULLIF(current_setting('app.tenant_id', true), '')::BIGINT
+        )
+    )
+    WITH CHECK (true);
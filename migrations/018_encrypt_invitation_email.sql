@@ -0,0 +1,56 @@
+-- Invited emails hold AES-GCM ciphertexts (see internal/pii), existing rows are
+-- encrypted by cmd/reencrypt. "EmailIndex" is the blind index of the normalized
+-- address, keyed like the users' "EmailIndex" so that invitations can be matched to
+-- the account of the address. NULL until a row is written or re-encrypted with a
+-- blind index key configured.
+ALTER TABLE public."Invitation"
+    ALTER COLUMN "Email" TYPE TEXT,
+    ADD COLUMN IF NOT EXISTS "EmailIndex" BYTEA;
+
+DROP INDEX IF EXISTS public."UX_Invitation_Open";
+
+-- Emails are stored trimmed and lowercased. Row-level security is lifted for the
+-- table owner while the plaintext rows are normalized.
+ALTER TABLE public."Invitation" NO FORCE ROW LEVEL SECURITY;
+UPDATE public."Invitation"
+SET "Email" = LOWER(TRIM("Email"))
+WHERE "Email" NOT LIKE 'pii:%'
+  AND "Email" <> LOWER(TRIM("Email"));
+ALTER TABLE public."Invitation" FORCE ROW LEVEL SECURITY;
+
+-- An email has at most one open invitation per organization, compared by blind
+-- index, or by address for rows stored without one
+CREATE UNIQUE INDEX IF NOT EXISTS "UX_Invitation_Open" ON public."Invitation" ("OrganizationId", "EmailIndex")
+    WHERE "AcceptedAt" IS NULL AND "RevokedAt" IS NULL AND "EmailIndex" IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS "UX_Invitation_OpenPlaintext" ON public."Invitation" ("OrganizationId", "Email")
+    WHERE "AcceptedAt" IS NULL AND "RevokedAt" IS NULL AND "EmailIndex" IS NULL;
+
+CREATE INDEX IF NOT EXISTS "IX_Invitation_EmailIndex" ON public."Invitation" ("EmailIndex");
+
+-- The user in app.user_id sees, and can delete, the invitations of every
+-- organization addressed to their email, for personal data exports and erasure
+DROP POLICY IF EXISTS "Invitation_Invitee" ON public."Invitation";
+CREATE POLICY "Invitation_Invitee" ON public."Invitation"
+    FOR SELECT
+    USING (
+        EXISTS (
+            SELECT 1
+            FROM public."User" u
+            WHERE u."UserId" = NULLIF(current_setting('app.user_id', true), '')::INTEGER
+              AND (u."EmailIndex" = "Invitation"."EmailIndex"
+               OR ("Invitation"."EmailIndex" IS NULL AND LOWER("Invitation"."Email") = LOWER(u."Email")))
+        )
+    );
+
+DROP POLICY IF EXISTS "Invitation_InviteeErase" ON public."Invitation";
+CREATE POLICY "Invitation_InviteeErase" ON public."Invitation"
+    FOR DELETE
+    USING (
+        EXISTS (
+            SELECT 1
+            FROM public."User" u
+            WHERE u."UserId" = NULLIF(current_setting('app.user_id', true), '')::INTEGER
+              AND (u."EmailIndex" = "Invitation"."EmailIndex"
+               OR ("Invitation"."EmailIndex" IS NULL AND LOWER("Invitation"."Email") = LOWER(u."Email")))
+        )
+    );
@@ -0,0 +1,7 @@
+-- Changes made by internal services authenticated with a client certificate are
+-- attributed to the certificate's common name, "ActorId" stays NULL for them
+ALTER TABLE public."AuditLog"
+    ADD COLUMN IF NOT EXISTS "ActorService" VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS "IX_AuditLog_ActorService" ON public."AuditLog" ("ActorService")
+    WHERE "ActorService" IS NOT NULL;